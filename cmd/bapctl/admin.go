@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// event is a transaction timeline entry returned by the admin API
+type event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Action     string    `json:"action"`
+	MessageID  string    `json:"message_id"`
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"duration_ms"`
+	Detail     string    `json:"detail"`
+}
+
+// pendingRequest is a pending wait returned by the admin API
+type pendingRequest struct {
+	Tenant        string `json:"tenant"`
+	Route         string `json:"route"`
+	TransactionID string `json:"transaction_id"`
+	MessageID     string `json:"message_id"`
+	CreatedAt     string `json:"created_at"`
+	TTLMs         int64  `json:"ttl_ms"`
+}
+
+// runTimeline prints a transaction's record and timeline, optionally following new events
+func runTimeline(args []string) int {
+	fs := newFlagSet("timeline", "<transaction_id> [flags]")
+	var flags commonFlags
+	flags.register(fs)
+	follow := fs.Bool("f", false, "keep polling for new events until interrupted")
+	interval := fs.Duration("interval", time.Second, "polling interval with -f")
+	tenant := fs.String("tenant", "", "tenant that owns the transaction")
+
+	positional := parseInterspersed(fs, args)
+	if len(positional) != 1 {
+		fs.Usage()
+		return 2
+	}
+	transactionID := positional[0]
+	query := url.Values{}
+	if *tenant != "" {
+		query.Set("tenant", *tenant)
+	}
+	path := "/admin/transactions/" + url.PathEscape(transactionID)
+
+	var timeline struct {
+		Transaction map[string]string `json:"transaction"`
+		Events      []event           `json:"events"`
+		Pending     []pendingRequest  `json:"pending"`
+	}
+	err := adminRequest(flags, http.MethodGet, withQuery(path, query), &timeline)
+	switch {
+	case err != nil && *follow && isNotFound(err):
+		// Follow a transaction that has not started yet
+		fmt.Fprintf(os.Stderr, "Waiting for transaction %s\n", transactionID)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Transaction %s\n", transactionID)
+	fields := make([]string, 0, len(timeline.Transaction))
+	for field := range timeline.Transaction {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fmt.Printf("  %-18s %s\n", field+":", timeline.Transaction[field])
+	}
+	fmt.Println()
+	for _, e := range timeline.Events {
+		printEvent(e)
+	}
+	for _, p := range timeline.Pending {
+		fmt.Printf("%-12s %-10s %-10s %s waiting since %s\n", "", "pending", p.Route, p.MessageID, p.CreatedAt)
+	}
+	if !*follow {
+		return 0
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	var since time.Time
+	if n := len(timeline.Events); n > 0 {
+		since = timeline.Events[n-1].Time
+	}
+	for {
+		select {
+		case <-interrupt:
+			return 0
+		case <-ticker.C:
+		}
+		if !since.IsZero() {
+			query.Set("since", since.Format(time.RFC3339Nano))
+		}
+		var update struct {
+			Events []event `json:"events"`
+		}
+		if err := adminRequest(flags, http.MethodGet, withQuery(path, query), &update); err != nil {
+			if !isNotFound(err) {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			}
+			continue
+		}
+		for _, e := range update.Events {
+			printEvent(e)
+			since = e.Time
+		}
+	}
+}
+
+// withQuery appends the query parameters, if any, to an admin API path
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// printEvent prints one timeline entry
+func printEvent(e event) {
+	var details []string
+	if e.Status != 0 {
+		details = append(details, fmt.Sprintf("status=%d", e.Status))
+	}
+	if e.DurationMs != 0 {
+		details = append(details, fmt.Sprintf("duration=%s", time.Duration(e.DurationMs*float64(time.Millisecond)).Round(time.Millisecond)))
+	}
+	if e.Detail != "" {
+		details = append(details, e.Detail)
+	}
+	fmt.Printf("%-12s %-10s %-10s %s %s\n", e.Time.Local().Format("15:04:05.000"), e.Type, e.Action, e.MessageID, strings.Join(details, " "))
+}
+
+// runPending lists the requests waiting for a callback
+func runPending(args []string) int {
+	fs := newFlagSet("pending", "[flags]")
+	var flags commonFlags
+	flags.register(fs)
+	transactionID := fs.String("txn", "", "only list this transaction")
+	fs.Parse(args)
+
+	path := "/admin/pending"
+	if *transactionID != "" {
+		path += "?transaction_id=" + url.QueryEscape(*transactionID)
+	}
+	var response struct {
+		Pending []pendingRequest `json:"pending"`
+	}
+	if err := adminRequest(flags, http.MethodGet, path, &response); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if len(response.Pending) == 0 {
+		fmt.Println("No pending requests")
+		return 0
+	}
+
+	sort.Slice(response.Pending, func(i, j int) bool {
+		return response.Pending[i].CreatedAt < response.Pending[j].CreatedAt
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TENANT\tROUTE\tTRANSACTION\tMESSAGE\tCREATED\tEXPIRES IN")
+	for _, p := range response.Pending {
+		tenant := p.Tenant
+		if tenant == "" {
+			tenant = "-"
+		}
+		expires := (time.Duration(p.TTLMs) * time.Millisecond).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", tenant, p.Route, p.TransactionID, p.MessageID, p.CreatedAt, expires)
+	}
+	w.Flush()
+	return 0
+}
+
+// runCancel cancels the pending waits of a transaction or one of its messages
+func runCancel(args []string) int {
+	fs := newFlagSet("cancel", "<transaction_id> [message_id] [flags]")
+	var flags commonFlags
+	flags.register(fs)
+	route := fs.String("route", "", "only cancel waits for this route")
+
+	positional := parseInterspersed(fs, args)
+	if len(positional) < 1 || len(positional) > 2 {
+		fs.Usage()
+		return 2
+	}
+	path := "/admin/pending/" + url.PathEscape(positional[0])
+	if len(positional) == 2 {
+		path += "/" + url.PathEscape(positional[1])
+	}
+	if *route != "" {
+		path += "?route=" + url.QueryEscape(*route)
+	}
+
+	var response struct {
+		Cancelled []struct {
+			Request pendingRequest `json:"request"`
+			Waiting bool           `json:"waiting"`
+		} `json:"cancelled"`
+	}
+	if err := adminRequest(flags, http.MethodDelete, path, &response); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	for _, c := range response.Cancelled {
+		state := "client notified"
+		if !c.Waiting {
+			state = "no client was waiting"
+		}
+		fmt.Printf("Cancelled %s %s %s (%s)\n", c.Request.Route, c.Request.TransactionID, c.Request.MessageID, state)
+	}
+	return 0
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `bapctl exercises a running BAP adapter.
+
+Usage:
+  bapctl send <action> [flags]            Build a Beckn request, send it to /api/{action} and print the response
+  bapctl timeline <transaction_id> [-f]   Show a transaction's timeline, following new events with -f
+  bapctl pending [-txn id]                List requests waiting for a callback
+  bapctl cancel <transaction_id> [message_id]
+                                          Cancel pending waits; their clients receive a NACK
+
+Run "bapctl <command> -h" for the flags of a command.
+The adapter URL and keys default to BAPCTL_URL, BAPCTL_API_KEY and BAPCTL_ADMIN_KEY (or ADMIN_API_KEY).
+`
+
+// commonFlags are shared by every command
+type commonFlags struct {
+	url      string
+	adminKey string
+	timeout  time.Duration
+}
+
+func (f *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.url, "url", envOr("BAPCTL_URL", "http://localhost:3000"), "adapter base URL")
+	fs.StringVar(&f.adminKey, "admin-key", envOr("BAPCTL_ADMIN_KEY", os.Getenv("ADMIN_API_KEY")), "X-Admin-Key for the admin endpoints")
+	fs.DurationVar(&f.timeout, "timeout", 60*time.Second, "request timeout")
+}
+
+// headerFlags collects repeated -H "Key: Value" flags
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if _, _, ok := strings.Cut(value, ":"); !ok {
+		return fmt.Errorf("header %q is not Key: Value", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// apply sets the headers on a request
+func (h headerFlags) apply(req *http.Request) {
+	for _, header := range h {
+		key, value, _ := strings.Cut(header, ":")
+		req.Header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+}
+
+func main() {
+	// Pick up ADMIN_API_KEY and friends from the adapter's .env
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var code int
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "send":
+		code = runSend(args)
+	case "timeline":
+		code = runTimeline(args)
+	case "pending":
+		code = runPending(args)
+	case "cancel":
+		code = runCancel(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		code = 2
+	}
+	os.Exit(code)
+}
+
+// newFlagSet creates the flag set of a command with its usage line
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: bapctl %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseInterspersed parses flags that may appear before or after the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// do sends a request and returns the response status and body
+func do(client *http.Client, req *http.Request) (int, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// adminRequest calls an admin endpoint and decodes the JSON response into out
+func adminRequest(flags commonFlags, method, path string, out interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimRight(flags.url, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if flags.adminKey != "" {
+		req.Header.Set("X-Admin-Key", flags.adminKey)
+	}
+
+	status, body, err := do(&http.Client{Timeout: flags.timeout}, req)
+	if err != nil {
+		return err
+	}
+	if status >= http.StatusBadRequest {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) != nil || failure.Error == "" {
+			failure.Error = string(bytes.TrimSpace(body))
+		}
+		return &apiError{Status: status, Message: failure.Error}
+	}
+	return json.Unmarshal(body, out)
+}
+
+// apiError is an error response from the adapter
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// isNotFound reports whether err is a 404 from the adapter
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// prettyJSON indents a JSON body, returning other bodies unchanged
+func prettyJSON(body []byte) []byte {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return body
+	}
+	return indented.Bytes()
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// timestampFormat is the Beckn context timestamp format
+const timestampFormat = "2006-01-02T15:04:05.000Z"
+
+// setFlags collects repeated -set path=value flags
+type setFlags []string
+
+func (s *setFlags) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *setFlags) Set(value string) error {
+	if _, _, ok := strings.Cut(value, "="); !ok {
+		return fmt.Errorf("%q is not path=value", value)
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+// sendFlags are the flags of the send command
+type sendFlags struct {
+	commonFlags
+	template     string
+	message      string
+	sets         setFlags
+	headers      headerFlags
+	apiKey       string
+	apiKeyHeader string
+	token        string
+	tenant       string
+	context      map[string]*string
+	dryRun       bool
+	raw          bool
+}
+
+// contextFlags are the context fields that can be set from flags, with their environment defaults
+var contextFlags = []struct {
+	field, flag, env string
+}{
+	{"transaction_id", "txn", ""},
+	{"message_id", "msg", ""},
+	{"domain", "domain", "BAPCTL_DOMAIN"},
+	{"version", "version", "BAPCTL_VERSION"},
+	{"bap_id", "bap-id", "BAPCTL_BAP_ID"},
+	{"bap_uri", "bap-uri", "BAPCTL_BAP_URI"},
+	{"bpp_id", "bpp-id", "BAPCTL_BPP_ID"},
+	{"bpp_uri", "bpp-uri", "BAPCTL_BPP_URI"},
+}
+
+// runSend builds a Beckn request, sends it to /api/{action} and prints the response
+func runSend(args []string) int {
+	fs := newFlagSet("send", "<action> [flags]")
+	flags := sendFlags{context: map[string]*string{}}
+	flags.register(fs)
+	fs.StringVar(&flags.template, "f", "", "request template file (JSON); context flags override its context, and action and timestamp are always set")
+	fs.StringVar(&flags.message, "message", "", "message as JSON, or @file to read it from a file")
+	fs.Var(&flags.sets, "set", "set a field as path=value, e.g. message.intent.item.descriptor.name=laptop; JSON values are parsed (repeatable)")
+	fs.Var(&flags.headers, "H", "extra request header as \"Key: Value\" (repeatable)")
+	fs.StringVar(&flags.apiKey, "api-key", os.Getenv("BAPCTL_API_KEY"), "client API key")
+	fs.StringVar(&flags.apiKeyHeader, "api-key-header", "X-API-Key", "header carrying the API key")
+	fs.StringVar(&flags.token, "token", os.Getenv("BAPCTL_TOKEN"), "client JWT, sent as a bearer token")
+	fs.StringVar(&flags.tenant, "tenant", "", "send to /api/{tenant}/{action}")
+	fs.BoolVar(&flags.dryRun, "dry-run", false, "print the request instead of sending it")
+	fs.BoolVar(&flags.raw, "raw", false, "print the response body as received")
+	for _, field := range contextFlags {
+		usage := "context." + field.field
+		if field.field == "transaction_id" || field.field == "message_id" {
+			usage += " (default: a new UUID)"
+		}
+		flags.context[field.field] = fs.String(field.flag, envOr(field.env, ""), usage)
+	}
+
+	positional := parseInterspersed(fs, args)
+	if len(positional) != 1 {
+		fs.Usage()
+		return 2
+	}
+	action := positional[0]
+
+	request, err := buildRequest(action, flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+
+	path := "/api/" + action
+	if flags.tenant != "" {
+		path = "/api/" + flags.tenant + "/" + action
+	}
+	target := strings.TrimRight(flags.url, "/") + path
+	if flags.dryRun {
+		fmt.Fprintf(os.Stderr, "POST %s\n", target)
+		fmt.Printf("%s\n", prettyJSON(body))
+		return 0
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 2
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if flags.apiKey != "" {
+		req.Header.Set(flags.apiKeyHeader, flags.apiKey)
+	}
+	if flags.token != "" {
+		req.Header.Set("Authorization", "Bearer "+flags.token)
+	}
+	flags.headers.apply(req)
+
+	context := request["context"].(map[string]interface{})
+	fmt.Fprintf(os.Stderr, "POST %s (transaction %v, message %v)\n", target, context["transaction_id"], context["message_id"])
+	start := time.Now()
+	status, respBody, err := do(&http.Client{Timeout: flags.timeout}, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "HTTP %d %s in %s\n", status, http.StatusText(status), time.Since(start).Round(time.Millisecond))
+
+	if flags.raw {
+		os.Stdout.Write(respBody)
+	} else {
+		fmt.Printf("%s\n", prettyJSON(respBody))
+	}
+	if status >= http.StatusBadRequest || isNack(respBody) {
+		return 1
+	}
+	return 0
+}
+
+// buildRequest assembles the request from the template, the message and the flags
+// context.action is always set to the action sent, so it matches the route, and
+// context.timestamp to the current time. Context flags that are given override the
+// template, and transaction and message IDs missing from both get new UUIDs.
+func buildRequest(action string, flags sendFlags) (map[string]interface{}, error) {
+	request := map[string]interface{}{}
+	if flags.template != "" {
+		data, err := os.ReadFile(flags.template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		if err := json.Unmarshal(data, &request); err != nil {
+			return nil, fmt.Errorf("template %s is not a JSON object: %w", flags.template, err)
+		}
+	}
+
+	if flags.message != "" {
+		data := []byte(flags.message)
+		if path, ok := strings.CutPrefix(flags.message, "@"); ok {
+			var err error
+			if data, err = os.ReadFile(path); err != nil {
+				return nil, fmt.Errorf("failed to read message: %w", err)
+			}
+		}
+		var message interface{}
+		if err := json.Unmarshal(data, &message); err != nil {
+			return nil, fmt.Errorf("message is not valid JSON: %w", err)
+		}
+		request["message"] = message
+	}
+	if _, ok := request["message"]; !ok {
+		request["message"] = map[string]interface{}{}
+	}
+
+	context, ok := request["context"].(map[string]interface{})
+	if !ok {
+		context = map[string]interface{}{}
+		request["context"] = context
+	}
+	context["action"] = action
+	context["timestamp"] = time.Now().UTC().Format(timestampFormat)
+	for field, value := range flags.context {
+		if *value != "" {
+			context[field] = *value
+		}
+	}
+	for _, field := range []string{"transaction_id", "message_id"} {
+		if id, _ := context[field].(string); id == "" {
+			context[field] = utils.UUIDv4()
+		}
+	}
+
+	for _, set := range flags.sets {
+		path, value, _ := strings.Cut(set, "=")
+		if err := setPath(request, strings.Split(path, "."), parseValue(value)); err != nil {
+			return nil, fmt.Errorf("-set %s: %w", path, err)
+		}
+	}
+	return request, nil
+}
+
+// setPath sets a dotted path in a JSON document, creating missing objects
+// Numeric segments index into existing arrays.
+func setPath(node interface{}, path []string, value interface{}) error {
+	key := path[0]
+	switch current := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			current[key] = value
+			return nil
+		}
+		child, ok := current[key]
+		if !ok || child == nil {
+			child = map[string]interface{}{}
+			current[key] = child
+		}
+		return setPath(child, path[1:], value)
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(current) {
+			return fmt.Errorf("%q is not an index of an array of %d", key, len(current))
+		}
+		if len(path) == 1 {
+			current[index] = value
+			return nil
+		}
+		return setPath(current[index], path[1:], value)
+	default:
+		return fmt.Errorf("cannot set %q inside a %T", key, node)
+	}
+}
+
+// parseValue returns value as JSON if it parses, or as a string
+func parseValue(value string) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		return parsed
+	}
+	return value
+}
+
+// isNack reports whether a response body is a Beckn NACK
+func isNack(body []byte) bool {
+	var response struct {
+		Message struct {
+			Ack struct {
+				Status string `json:"status"`
+			} `json:"ack"`
+		} `json:"message"`
+	}
+	return json.Unmarshal(body, &response) == nil && response.Message.Ack.Status == "NACK"
+}
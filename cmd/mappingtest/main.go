@@ -0,0 +1,278 @@
+package main
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/transformers"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	inputSuffix  = ".input.json"
+	goldenSuffix = ".golden.json"
+)
+
+// fixtureCase is a single input/golden pair for a route and direction
+type fixtureCase struct {
+	Route      string
+	Direction  transformers.TransformDirection
+	Name       string
+	InputPath  string
+	GoldenPath string
+}
+
+// caseResult captures the outcome of running a fixture case
+type caseResult struct {
+	Case    fixtureCase
+	Passed  bool
+	Updated bool
+	Message string
+}
+
+func main() {
+	mappingsPath := flag.String("mappings", filepath.Join("config", "mappings.yaml"), "path to the mappings YAML file")
+	fixturesDir := flag.String("fixtures", filepath.Join("config", "fixtures"), "directory containing {route}/{direction}/{case}.input.json fixtures")
+	routeFilter := flag.String("route", "", "only run fixtures for this route")
+	update := flag.Bool("update", false, "write transformation output to the golden files instead of comparing")
+	verbose := flag.Bool("v", false, "show transformer logs")
+	flag.Parse()
+
+	level := "error"
+	if *verbose {
+		level = "debug"
+	}
+	if err := logging.Init(logging.Options{Level: level, Format: logging.FormatText}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+
+	os.Exit(run(os.Stdout, *mappingsPath, *fixturesDir, *routeFilter, *update))
+}
+
+// run loads the mappings, executes every fixture and returns the process exit code
+func run(out io.Writer, mappingsPath, fixturesDir, routeFilter string, update bool) int {
+	loader := transformers.NewLoader(mappingsPath)
+	if err := loader.Load(); err != nil {
+		fmt.Fprintf(out, "ERROR: %v\n", err)
+		return 2
+	}
+	transformer := transformers.NewTransformer(loader)
+
+	cases, err := discoverCases(fixturesDir, routeFilter)
+	if err != nil {
+		fmt.Fprintf(out, "ERROR: %v\n", err)
+		return 2
+	}
+	if len(cases) == 0 {
+		fmt.Fprintf(out, "No fixtures found in %s\n", fixturesDir)
+		return 2
+	}
+
+	failed := 0
+	for _, fc := range cases {
+		result := runCase(transformer, fc, update)
+		status := "PASS"
+		switch {
+		case result.Updated:
+			status = "UPDATED"
+		case !result.Passed:
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(out, "%-7s %s/%s/%s\n", status, fc.Route, fc.Direction, fc.Name)
+		if result.Message != "" {
+			fmt.Fprintln(out, indent(result.Message, "        "))
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d case(s), %d failed\n", len(cases), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// discoverCases walks the fixtures directory and collects all input files
+// Layout: {fixturesDir}/{route}/{forward|reverse}/{case}.input.json
+func discoverCases(fixturesDir, routeFilter string) ([]fixtureCase, error) {
+	var cases []fixtureCase
+
+	err := filepath.WalkDir(fixturesDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), inputSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(fixturesDir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) != 3 {
+			return fmt.Errorf("fixture %s must be located at {route}/{direction}/{case}%s", rel, inputSuffix)
+		}
+
+		direction := transformers.TransformDirection(parts[1])
+		if direction != transformers.DirectionForward && direction != transformers.DirectionReverse {
+			return fmt.Errorf("fixture %s has invalid direction %q (expected forward or reverse)", rel, parts[1])
+		}
+		if routeFilter != "" && parts[0] != routeFilter {
+			return nil
+		}
+
+		name := strings.TrimSuffix(parts[2], inputSuffix)
+		cases = append(cases, fixtureCase{
+			Route:      parts[0],
+			Direction:  direction,
+			Name:       name,
+			InputPath:  path,
+			GoldenPath: filepath.Join(filepath.Dir(path), name+goldenSuffix),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	sort.Slice(cases, func(i, j int) bool {
+		return cases[i].InputPath < cases[j].InputPath
+	})
+	return cases, nil
+}
+
+// runCase transforms the fixture input and compares or updates the golden file
+func runCase(transformer *transformers.Transformer, fc fixtureCase, update bool) caseResult {
+	result := caseResult{Case: fc}
+
+	input, err := os.ReadFile(fc.InputPath)
+	if err != nil {
+		result.Message = fmt.Sprintf("failed to read input: %v", err)
+		return result
+	}
+
+	output, err := transformer.Transform(fc.Route, fc.Direction, input)
+	if err != nil {
+		result.Message = describeError(err)
+		return result
+	}
+
+	actual, err := canonicalJSON(output)
+	if err != nil {
+		result.Message = fmt.Sprintf("transformation produced invalid JSON: %v", err)
+		return result
+	}
+
+	if update {
+		if err := os.WriteFile(fc.GoldenPath, actual, 0o644); err != nil {
+			result.Message = fmt.Sprintf("failed to write golden file: %v", err)
+			return result
+		}
+		result.Passed = true
+		result.Updated = true
+		return result
+	}
+
+	goldenRaw, err := os.ReadFile(fc.GoldenPath)
+	if err != nil {
+		result.Message = fmt.Sprintf("failed to read golden file (run with -update to create it): %v", err)
+		return result
+	}
+	expected, err := canonicalJSON(goldenRaw)
+	if err != nil {
+		result.Message = fmt.Sprintf("golden file is not valid JSON: %v", err)
+		return result
+	}
+
+	if bytes.Equal(expected, actual) {
+		result.Passed = true
+		return result
+	}
+
+	result.Message = lineDiff(string(expected), string(actual))
+	return result
+}
+
+// describeError formats a transformation error including the underlying cause
+func describeError(err error) string {
+	if transformErr, ok := err.(*transformers.TransformError); ok && transformErr.Err != nil {
+		return fmt.Sprintf("%s: %v", transformErr.Error(), transformErr.Err)
+	}
+	return err.Error()
+}
+
+// canonicalJSON re-encodes JSON with sorted keys and stable indentation
+func canonicalJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lineDiff renders a unified-style line diff between the expected and actual output
+func lineDiff(expected, actual string) string {
+	a := strings.Split(strings.TrimRight(expected, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(actual, "\n"), "\n")
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- golden\n+++ actual\n")
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			fmt.Fprintf(&sb, "-%s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&sb, "+%s\n", b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		fmt.Fprintf(&sb, "-%s\n", a[i])
+	}
+	for ; j < len(b); j++ {
+		fmt.Fprintf(&sb, "+%s\n", b[j])
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// indent prefixes every line of s with the given prefix
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
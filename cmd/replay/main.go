@@ -0,0 +1,312 @@
+package main
+
+import (
+	"BAP_Sandbox/internal/capture"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Replay modes
+const (
+	// modeAPI re-sends captured client requests to the adapter's /api
+	modeAPI = "api"
+	// modeOnix re-sends the captured ONIX requests directly to an ONIX instance
+	modeOnix = "onix"
+	// modeWebhook re-sends captured callbacks to the adapter's /webhook
+	modeWebhook = "webhook"
+)
+
+// Comparisons against the captured outcome
+const (
+	compareStatus = "status"
+	compareBody   = "body"
+	compareNone   = "none"
+)
+
+// skippedHeaders are recomputed for the replayed request instead of copied from the capture
+var skippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Host":              true,
+	"Connection":        true,
+	"Accept-Encoding":   true,
+	"Transfer-Encoding": true,
+}
+
+// headerFlags collects repeated -H "Key: Value" flags
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if _, _, ok := strings.Cut(value, ":"); !ok {
+		return fmt.Errorf("header %q is not Key: Value", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// options are the parsed command-line flags
+type options struct {
+	mode       string
+	target     string
+	txn        string
+	route      string
+	headers    headerFlags
+	hmacSecret string
+	hmacHeader string
+	compare    string
+	interval   time.Duration
+	timeout    time.Duration
+}
+
+// result is the outcome of replaying one record
+type result struct {
+	Record  capture.Record
+	Status  int
+	Passed  bool
+	Message string
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.mode, "mode", modeAPI, "what to replay: api (client requests to the adapter), onix (ONIX requests to ONIX) or webhook (callbacks to the adapter)")
+	flag.StringVar(&opts.target, "target", "http://localhost:3000", "base URL the records are sent to")
+	flag.StringVar(&opts.txn, "txn", "", "only replay this transaction ID")
+	flag.StringVar(&opts.route, "route", "", "only replay this route")
+	flag.Var(&opts.headers, "H", "extra request header as \"Key: Value\", e.g. credentials removed from the capture (repeatable)")
+	flag.StringVar(&opts.hmacSecret, "hmac-secret", "", "re-sign webhook callbacks with this HMAC-SHA256 secret")
+	flag.StringVar(&opts.hmacHeader, "hmac-header", "X-Signature", "header carrying the webhook signature")
+	flag.StringVar(&opts.compare, "compare", compareStatus, "compare each response with the capture: status, body or none")
+	flag.DurationVar(&opts.interval, "interval", 0, "pause between requests")
+	flag.DurationVar(&opts.timeout, "timeout", 60*time.Second, "timeout for each request")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] capture.jsonl...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch opts.mode {
+	case modeAPI, modeOnix, modeWebhook:
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: unknown mode %q\n", opts.mode)
+		os.Exit(2)
+	}
+	switch opts.compare {
+	case compareStatus, compareBody, compareNone:
+	default:
+		fmt.Fprintf(os.Stderr, "ERROR: unknown comparison %q\n", opts.compare)
+		os.Exit(2)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	os.Exit(run(os.Stdout, opts, flag.Args()))
+}
+
+// run replays the records of the capture files and returns the process exit code
+func run(out io.Writer, opts options, files []string) int {
+	var records []capture.Record
+	for _, path := range files {
+		loaded, err := readFile(path)
+		if err != nil {
+			fmt.Fprintf(out, "ERROR: %s: %v\n", path, err)
+			return 2
+		}
+		records = append(records, loaded...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	sendKind, expectKind := kindsFor(opts.mode)
+	expected := map[string]capture.Record{}
+	var selected []capture.Record
+	for _, rec := range records {
+		if (opts.txn != "" && rec.TransactionID != opts.txn) || (opts.route != "" && rec.Route != opts.route) {
+			continue
+		}
+		switch rec.Kind {
+		case sendKind:
+			selected = append(selected, rec)
+		case expectKind:
+			expected[correlationKey(rec)] = rec
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Fprintf(out, "No %s records to replay\n", sendKind)
+		return 2
+	}
+
+	client := &http.Client{Timeout: opts.timeout}
+	failed := 0
+	for i, rec := range selected {
+		if i > 0 && opts.interval > 0 {
+			time.Sleep(opts.interval)
+		}
+		want, ok := expected[correlationKey(rec)]
+		if opts.mode == modeWebhook {
+			// Callbacks carry the adapter's response status themselves
+			want, ok = rec, true
+		}
+		res := replay(client, opts, rec, want, ok)
+		if !res.Passed {
+			failed++
+		}
+		printResult(out, res)
+	}
+
+	fmt.Fprintf(out, "\n%d replayed, %d passed, %d failed\n", len(selected), len(selected)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readFile decodes the records in one capture file
+func readFile(path string) ([]capture.Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return capture.Read(file)
+}
+
+// kindsFor returns the record kind replayed in a mode and the kind holding its expected outcome
+func kindsFor(mode string) (send, expect string) {
+	switch mode {
+	case modeOnix:
+		return capture.KindOnixRequest, capture.KindOnixResponse
+	case modeWebhook:
+		return capture.KindCallback, capture.KindCallback
+	default:
+		return capture.KindClientRequest, capture.KindClientResponse
+	}
+}
+
+// correlationKey pairs a request record with its response record
+func correlationKey(rec capture.Record) string {
+	return rec.TransactionID + "|" + rec.MessageID + "|" + rec.Route
+}
+
+// replay sends one record to the target and compares the response with the capture
+func replay(client *http.Client, opts options, rec, want capture.Record, haveWant bool) result {
+	res := result{Record: rec}
+
+	target, err := targetURL(opts, rec)
+	if err != nil {
+		res.Message = err.Error()
+		return res
+	}
+	body := []byte(rec.Body)
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		res.Message = err.Error()
+		return res
+	}
+	for key, value := range rec.Headers {
+		if !skippedHeaders[http.CanonicalHeaderKey(key)] {
+			req.Header.Set(key, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range opts.headers {
+		key, value, _ := strings.Cut(header, ":")
+		req.Header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	if opts.mode == modeWebhook && opts.hmacSecret != "" {
+		mac := hmac.New(sha256.New, []byte(opts.hmacSecret))
+		mac.Write(body)
+		req.Header.Set(opts.hmacHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		res.Message = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		res.Message = fmt.Sprintf("failed to read response: %v", err)
+		return res
+	}
+	res.Status = resp.StatusCode
+
+	if opts.compare == compareNone {
+		res.Passed = true
+		return res
+	}
+	if !haveWant {
+		res.Message = "no captured response to compare with"
+		return res
+	}
+	if want.Status != 0 && resp.StatusCode != want.Status {
+		res.Message = fmt.Sprintf("status %d, captured %d", resp.StatusCode, want.Status)
+		return res
+	}
+	if opts.compare == compareBody && opts.mode != modeWebhook && !sameJSON(respBody, want.Body) {
+		res.Message = "body differs from the capture"
+		return res
+	}
+	res.Passed = true
+	return res
+}
+
+// targetURL resolves where a record is sent
+// ONIX records keep the path of the captured ONIX URL so tenant-specific paths are preserved.
+func targetURL(opts options, rec capture.Record) (string, error) {
+	path := rec.URL
+	if opts.mode == modeOnix {
+		parsed, err := url.Parse(rec.URL)
+		if err != nil || parsed.Path == "" {
+			return strings.TrimRight(opts.target, "/") + "/" + rec.Route, nil
+		}
+		path = parsed.Path
+	}
+	if path == "" {
+		return "", fmt.Errorf("record has no URL")
+	}
+	return strings.TrimRight(opts.target, "/") + path, nil
+}
+
+// sameJSON compares two JSON documents semantically, ignoring formatting and key order
+func sameJSON(a, b []byte) bool {
+	var left, right interface{}
+	if json.Unmarshal(a, &left) != nil || json.Unmarshal(b, &right) != nil {
+		return bytes.Equal(bytes.TrimSpace(a), bytes.TrimSpace(b))
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func printResult(out io.Writer, res result) {
+	status := "PASS"
+	if !res.Passed {
+		status = "FAIL"
+	}
+	line := fmt.Sprintf("%s  %-10s txn=%s msg=%s", status, res.Record.Route, res.Record.TransactionID, res.Record.MessageID)
+	if res.Status != 0 {
+		line += fmt.Sprintf(" status=%d", res.Status)
+	}
+	if res.Message != "" {
+		line += ": " + res.Message
+	}
+	fmt.Fprintln(out, line)
+}
@@ -2,73 +2,272 @@ package main
 
 import (
 	"BAP_Sandbox/config"
+	"BAP_Sandbox/internal/auth"
+	"BAP_Sandbox/internal/cache"
+	"BAP_Sandbox/internal/capture"
+	"BAP_Sandbox/internal/catalog"
+	"BAP_Sandbox/internal/drain"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/ratelimit"
 	"BAP_Sandbox/internal/routes"
 	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"BAP_Sandbox/internal/tracing"
 	"BAP_Sandbox/internal/transformers"
-	"log"
+	"BAP_Sandbox/internal/upstream"
+	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
 )
 
+var serverLog = logging.For("server")
+
 func main() {
 	// Load .env file (ignore error if file doesn't exist - useful for production with real env vars)
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	envErr := godotenv.Load()
+
+	configPath := flag.String("config", defaultConfigPath(), "configuration file (YAML); environment variables override its values")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets masked and exit")
+	flag.Parse()
+
+	// Load and validate configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		os.Exit(1)
+	}
+	if *printConfig {
+		data, err := cfg.YAML()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("# source: %s\n%s", configSource(cfg), data)
+		return
 	}
 
-	// Load configuration
-	cfg := config.Load()
+	// Initialize structured logging
+	if err := logging.Init(logging.Options{
+		Level:        cfg.Logging.Level,
+		Format:       cfg.Logging.Format,
+		ModuleLevels: cfg.Logging.ModuleLevels,
+		RedactPaths:  cfg.Logging.RedactPaths,
+	}); err != nil {
+		fatal("Failed to initialize logging", err)
+	}
+	if envErr != nil {
+		serverLog.Info("No .env file found, using system environment variables")
+	}
+	serverLog.Info("Loaded configuration", "source", configSource(cfg))
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Initialize Redis
-	if err := storage.InitRedis(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	if err := storage.InitRedis(storage.RedisOptions{
+		URL:                   cfg.Redis.URL,
+		Addrs:                 cfg.Redis.Addrs,
+		Mode:                  cfg.Redis.Mode,
+		Username:              cfg.Redis.Username,
+		Password:              cfg.Redis.Password,
+		DB:                    cfg.Redis.DB,
+		MasterName:            cfg.Redis.MasterName,
+		SentinelUsername:      cfg.Redis.SentinelUsername,
+		SentinelPassword:      cfg.Redis.SentinelPassword,
+		TLS:                   cfg.Redis.TLS.Enabled,
+		TLSCAFile:             cfg.Redis.TLS.CAFile,
+		TLSCertFile:           cfg.Redis.TLS.CertFile,
+		TLSKeyFile:            cfg.Redis.TLS.KeyFile,
+		TLSServerName:         cfg.Redis.TLS.ServerName,
+		TLSInsecureSkipVerify: cfg.Redis.TLS.InsecureSkipVerify,
+		PoolSize:              cfg.Redis.PoolSize,
+		MinIdleConns:          cfg.Redis.MinIdleConns,
+		DialTimeout:           cfg.Redis.DialTimeout,
+		ReadTimeout:           cfg.Redis.ReadTimeout,
+		WriteTimeout:          cfg.Redis.WriteTimeout,
+		PoolTimeout:           cfg.Redis.PoolTimeout,
+	}); err != nil {
+		fatal("Failed to connect to Redis", err)
 	}
 	defer storage.CloseRedis()
 
-	log.Println("Successfully connected to Redis")
-
 	// Initialize Transformer
-	// Get the path to mappings.yaml relative to the project root
-	mappingsPath := filepath.Join("config", "mappings.yaml")
-	if err := transformers.InitTransformer(mappingsPath); err != nil {
-		log.Printf("WARNING: Failed to initialize transformer: %v", err)
-		log.Println("Application will continue without transformation capabilities")
-	} else {
-		log.Println("Successfully initialized transformer with mappings")
+	if err := transformers.InitTransformer(cfg.Mappings.Path); err != nil {
+		serverLog.Warn("Failed to initialize transformer, continuing without transformation capabilities", "error", err)
 	}
 
+	// Load error code catalog used for Beckn error responses
+	if err := transformers.InitErrorResponses(cfg.Mappings.ErrorCatalog, cfg.Server.Debug); err != nil {
+		fatal("Failed to load error catalog", err)
+	}
+
+	// Configure circuit breakers for upstream calls
+	upstream.InitBreakers(upstream.BreakerSettings{
+		FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+		OpenTimeout:      cfg.CircuitBreaker.OpenTimeout,
+		HalfOpenProbes:   cfg.CircuitBreaker.HalfOpenProbes,
+	})
+
+	// Load per-route retry policies for upstream calls
+	if err := upstream.InitRetryPolicies(cfg.Policies.RetryPath); err != nil {
+		fatal("Failed to load retry policies", err)
+	}
+
+	// Configure client authentication for /api
+	if err := auth.Init(auth.Options{
+		Methods:        cfg.Auth.Methods,
+		APIKeyHeader:   cfg.Auth.APIKeyHeader,
+		APIKeys:        cfg.Auth.APIKeys,
+		JWKSPath:       cfg.Auth.JWKSPath,
+		JWTIssuer:      cfg.Auth.JWTIssuer,
+		JWTAudience:    cfg.Auth.JWTAudience,
+		JWTClientClaim: cfg.Auth.JWTClientClaim,
+	}); err != nil {
+		fatal("Failed to configure authentication", err)
+	}
+
+	// Load the tenants and their ONIX instances; without tenants every request goes to ONIX_URL(S)
+	if err := tenants.Init(tenants.Options{
+		Path:             cfg.Policies.TenantsPath,
+		DefaultOnixURLs:  cfg.Onix.Replicas(),
+		DefaultBalancing: cfg.Onix.Balancing,
+	}); err != nil {
+		fatal("Failed to load tenants", err)
+	}
+
+	// Probe every ONIX replica in the background so requests avoid unhealthy replicas
+	healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+	upstream.StartHealthChecks(healthCtx, upstream.HealthCheckSettings{
+		Interval: cfg.Onix.HealthInterval,
+		Path:     cfg.Onix.ProbePath,
+		Timeout:  cfg.Health.Timeout,
+	})
+
+	// Load per-client rate limits and concurrency quotas
+	if err := ratelimit.Init(ratelimit.Options{
+		Path:        cfg.Policies.RateLimitPath,
+		WaitTimeout: cfg.Callbacks.WaitTimeout,
+	}); err != nil {
+		fatal("Failed to load rate limits", err)
+	}
+
+	// Capture traffic for replay when enabled
+	if err := capture.Init(capture.Options{
+		Enabled:          cfg.Capture.Enabled,
+		Dir:              cfg.Capture.Dir,
+		Redact:           cfg.Capture.Redact,
+		SensitiveHeaders: []string{cfg.Auth.APIKeyHeader},
+	}); err != nil {
+		fatal("Failed to start traffic capture", err)
+	}
+
+	// Cache search catalogs for /api/catalog and select validation when enabled
+	catalog.Init(catalog.Options{
+		Enabled:          cfg.Catalog.Enabled,
+		TTL:              cfg.Catalog.TTL,
+		SelectValidation: cfg.Catalog.SelectValidation,
+	})
+
+	// Answer repeated sync requests from the response cache when enabled
+	cache.Init(cache.Options{
+		Enabled:      cfg.ResponseCache.Enabled,
+		Routes:       cfg.ResponseCache.Routes,
+		BypassHeader: cfg.ResponseCache.BypassHeader,
+	})
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		AppName: "BAP Sandbox",
+		AppName:      "BAP Sandbox",
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	})
 
 	// Middleware
 	app.Use(recover.New())
-	app.Use(logger.New())
-	app.Use(cors.New())
+	app.Use(logging.Middleware())
+	app.Use(cors.New(cors.Config{
+		AllowOrigins:     strings.Join(cfg.CORS.AllowOrigins, ","),
+		AllowMethods:     strings.Join(cfg.CORS.AllowMethods, ","),
+		AllowHeaders:     strings.Join(cfg.CORS.AllowHeaders, ","),
+		ExposeHeaders:    strings.Join(cfg.CORS.ExposeHeaders, ","),
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           int(cfg.CORS.MaxAge.Seconds()),
+	}))
 
 	// Setup routes
-	routes.SetupRoutes(app)
+	if err := routes.SetupRoutes(app, cfg); err != nil {
+		fatal("Failed to set up routes", err)
+	}
 
-	// Graceful shutdown
+	// Graceful shutdown: drain in-flight requests while /webhook keeps serving their callbacks,
+	// then close connections, and only then Redis
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 		<-sigChan
-		log.Println("Shutting down gracefully...")
-		app.Shutdown()
+		serverLog.Info("Shutting down gracefully", "drain_timeout", cfg.Server.DrainTimeout)
+
+		drain.Start()
+		if !drain.Wait(cfg.Server.DrainTimeout) {
+			drain.Abort()
+		}
+		if err := app.ShutdownWithTimeout(cfg.Server.ShutdownTimeout); err != nil {
+			serverLog.Warn("Connections did not close in time", "error", err)
+		}
+		stopHealthChecks()
+		capture.Close()
 		storage.CloseRedis()
+		shutdownTracing(context.Background())
 	}()
 
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Port)
-	log.Fatal(app.Listen(":" + cfg.Port))
+	serverLog.Info("Server starting", "port", cfg.Server.Port)
+	if err := app.Listen(":" + cfg.Server.Port); err != nil {
+		fatal("Server stopped", err)
+	}
+	<-shutdownDone
+	serverLog.Info("Server stopped")
+}
+
+// defaultConfigPath returns CONFIG_PATH, or the default configuration file
+func defaultConfigPath() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	return config.DefaultPath
+}
+
+// configSource describes where the configuration was read from
+func configSource(cfg *config.Config) string {
+	if cfg.Source() == "" {
+		return "defaults and environment"
+	}
+	return cfg.Source()
+}
+
+// fatal logs an error and exits the process
+func fatal(msg string, err error) {
+	serverLog.Error(msg, "error", err)
+	os.Exit(1)
 }
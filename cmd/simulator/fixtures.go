@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// timestampFormat is the Beckn context timestamp format
+const timestampFormat = "2006-01-02T15:04:05.000Z"
+
+// fixtureData is what callback templates can refer to
+type fixtureData struct {
+	// Context and Message are the forwarded request's context and message
+	Context  map[string]interface{}
+	Message  map[string]interface{}
+	Route    string
+	Callback string
+}
+
+// templateFuncs are the helpers available in callback templates
+var templateFuncs = template.FuncMap{
+	"now": func() string {
+		return time.Now().UTC().Format(timestampFormat)
+	},
+	"uuid": utils.UUIDv4,
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// fixtures renders callback bodies from the templates in a directory
+// Templates are read on every request, so edits apply without a restart.
+type fixtures struct {
+	dir string
+}
+
+// render builds the callback body for a forwarded request
+// The callback context is the request context with the callback action and a new
+// timestamp; fields in the template's context override it. A missing template
+// yields an empty message.
+func (f fixtures) render(name, route, callback string, request map[string]interface{}) (map[string]interface{}, error) {
+	requestContext, _ := request["context"].(map[string]interface{})
+	message, _ := request["message"].(map[string]interface{})
+
+	body := map[string]interface{}{"message": map[string]interface{}{}}
+	path := filepath.Join(f.dir, name+".json")
+	source, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		simLog.Debug("No fixture for callback, sending an empty message", "path", path)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	default:
+		tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		var rendered bytes.Buffer
+		data := fixtureData{Context: requestContext, Message: message, Route: route, Callback: callback}
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			return nil, fmt.Errorf("failed to render fixture %s: %w", path, err)
+		}
+		body = map[string]interface{}{}
+		if err := json.Unmarshal(rendered.Bytes(), &body); err != nil {
+			return nil, fmt.Errorf("fixture %s did not render valid JSON: %w", path, err)
+		}
+	}
+
+	callbackContext := make(map[string]interface{}, len(requestContext)+2)
+	for key, value := range requestContext {
+		callbackContext[key] = value
+	}
+	callbackContext["action"] = callback
+	callbackContext["timestamp"] = time.Now().UTC().Format(timestampFormat)
+	if overrides, ok := body["context"].(map[string]interface{}); ok {
+		for key, value := range overrides {
+			callbackContext[key] = value
+		}
+	}
+	body["context"] = callbackContext
+	return body, nil
+}
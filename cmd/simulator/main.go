@@ -0,0 +1,314 @@
+package main
+
+import (
+	"BAP_Sandbox/config"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/tenants"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/joho/godotenv"
+)
+
+var simLog = logging.For("simulator")
+
+// simulator stands in for ONIX and the BPPs behind it
+type simulator struct {
+	scenarios *Scenarios
+	fixtures  fixtures
+	// callbacks and sync come from the adapter's route table
+	callbacks map[string]string
+	sync      []string
+
+	// forced overrides the scenario of every route
+	forced string
+
+	webhookURL  string
+	hmacSecret  []byte
+	hmacHeader  string
+	bearerToken string
+	client      *http.Client
+}
+
+func main() {
+	// Share the adapter's .env, so the simulator signs callbacks with the same webhook secret
+	_ = godotenv.Load()
+
+	configPath := flag.String("config", defaultConfigPath(), "adapter configuration file, for the route table, port and webhook authentication")
+	addr := flag.String("addr", ":8080", "address to listen on; point ONIX_URL here")
+	webhook := flag.String("webhook", "", "base URL callbacks are posted to (default: http://localhost:{adapter port}/webhook)")
+	scenariosPath := flag.String("scenarios", "config/simulator.yaml", "scenario file")
+	fixturesDir := flag.String("fixtures", "config/simulator", "directory of {callback}.json templates")
+	scenario := flag.String("scenario", "", "play this scenario for every route, overriding the scenario file")
+	verbose := flag.Bool("v", false, "log callback bodies")
+	flag.Parse()
+
+	level := "info"
+	if *verbose {
+		level = "debug"
+	}
+	if err := logging.Init(logging.Options{Level: level, Format: logging.FormatText}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		os.Exit(2)
+	}
+	// Tenants keep their path segment on callbacks, so they reach tenant-namespaced waits
+	if err := tenants.Init(tenants.Options{
+		Path:             cfg.Policies.TenantsPath,
+		DefaultOnixURLs:  cfg.Onix.Replicas(),
+		DefaultBalancing: cfg.Onix.Balancing,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	scenarios, err := loadScenarios(*scenariosPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(2)
+	}
+	if *scenario != "" {
+		forced := Scenario{Scenario: *scenario}
+		if err := forced.mergeOnto(scenarios.Default).validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -scenario: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	sim := &simulator{
+		scenarios:  scenarios,
+		fixtures:   fixtures{dir: *fixturesDir},
+		callbacks:  cfg.Routes.Callbacks,
+		sync:       cfg.Routes.Sync,
+		webhookURL: strings.TrimRight(*webhook, "/"),
+		hmacSecret: []byte(cfg.Webhook.HMACSecret),
+		hmacHeader: cfg.Webhook.HMACHeader,
+		forced:     *scenario,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+	if sim.webhookURL == "" {
+		sim.webhookURL = fmt.Sprintf("http://localhost:%s/webhook", cfg.Server.Port)
+	}
+	if len(cfg.Webhook.BearerTokens) > 0 {
+		sim.bearerToken = cfg.Webhook.BearerTokens[0]
+	}
+
+	app := fiber.New(fiber.Config{
+		AppName:               "BAP Adapter Simulator",
+		DisableStartupMessage: true,
+	})
+	// GET answers ONIX health probes on any path
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok"})
+	})
+	app.Post("/*", sim.handle)
+
+	simLog.Info("Simulator listening",
+		"addr", *addr,
+		"webhook", sim.webhookURL,
+		"scenarios", *scenariosPath,
+		"fixtures", *fixturesDir,
+		"signed", len(sim.hmacSecret) > 0,
+	)
+	if err := app.Listen(*addr); err != nil {
+		simLog.Error("Simulator stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// handle answers a forwarded Beckn request according to the route's scenario
+// The route is the last path segment, so tenant-specific ONIX paths work too.
+// When the segment before it names a tenant, callbacks go to /webhook/{tenant}/{callback}.
+func (s *simulator) handle(c *fiber.Ctx) error {
+	requestPath := utils.CopyString(c.Path())
+	route := path.Base(requestPath)
+	tenant := path.Base(path.Dir(requestPath))
+	if _, known := tenants.Lookup(tenant); !known {
+		tenant = ""
+	}
+	callback, known := s.callbacks[route]
+	if !known {
+		return nack(c, fiber.StatusNotFound, "CONTEXT-ERROR", "10002", fmt.Sprintf("Unknown action %q", route))
+	}
+
+	var request map[string]interface{}
+	if err := json.Unmarshal(c.Body(), &request); err != nil {
+		return nack(c, fiber.StatusBadRequest, "JSON-SCHEMA-ERROR", "10000", "Request body is not valid JSON")
+	}
+
+	scenario := s.scenarios.For(route)
+	if s.forced != "" {
+		scenario.Scenario = s.forced
+	}
+	scenario, err := scenario.withOverrides(c.Get(scenarioHeader), c.Get(delayHeader))
+	if err != nil {
+		return nack(c, fiber.StatusBadRequest, "CONTEXT-ERROR", "10000", err.Error())
+	}
+	if scenario.Fixture == "" {
+		scenario.Fixture = callback
+	}
+
+	requestContext, _ := request["context"].(map[string]interface{})
+	log := simLog.With("route", route, "tenant", tenant, "scenario", scenario.Scenario,
+		"transaction_id", requestContext["transaction_id"], "message_id", requestContext["message_id"])
+	log.Info("Request received")
+
+	switch scenario.Scenario {
+	case scenarioNack:
+		return nack(c, statusOr(scenario.Status, fiber.StatusBadRequest), "DOMAIN-ERROR", scenario.ErrorCode, scenario.ErrorMessage)
+	case scenarioHTTPError:
+		return c.Status(statusOr(scenario.Status, fiber.StatusServiceUnavailable)).SendString(scenario.ErrorMessage)
+	}
+
+	if slices.Contains(s.sync, route) {
+		// Sync routes answer with the callback body instead of calling back
+		if scenario.Scenario == scenarioNone {
+			return ack(c)
+		}
+		body, err := s.callbackBody(scenario, route, callback, request)
+		if err != nil {
+			log.Error("Failed to build response", "error", err)
+			return nack(c, fiber.StatusInternalServerError, "INTERNAL-ERROR", "20000", err.Error())
+		}
+		time.Sleep(scenario.Delay)
+		return c.JSON(body)
+	}
+
+	if scenario.Scenario != scenarioNone {
+		body, err := s.callbackBody(scenario, route, callback, request)
+		if err != nil {
+			log.Error("Failed to build callback", "error", err)
+			return nack(c, fiber.StatusInternalServerError, "INTERNAL-ERROR", "20000", err.Error())
+		}
+		count := 1
+		if scenario.Scenario == scenarioDuplicate {
+			count = scenario.Count
+		}
+		go s.sendCallbacks(log, path.Join(tenant, callback), body, scenario.Delay, scenario.Interval, count)
+	}
+	return ack(c)
+}
+
+// callbackBody renders the callback fixture, adding the scenario's error if any
+func (s *simulator) callbackBody(scenario Scenario, route, callback string, request map[string]interface{}) (map[string]interface{}, error) {
+	body, err := s.fixtures.render(scenario.Fixture, route, callback, request)
+	if err != nil {
+		return nil, err
+	}
+	if scenario.Scenario == scenarioError {
+		body["error"] = fiber.Map{
+			"type":    "DOMAIN-ERROR",
+			"code":    scenario.ErrorCode,
+			"message": scenario.ErrorMessage,
+		}
+	}
+	return body, nil
+}
+
+// sendCallbacks posts the callback to the adapter's webhook count times
+// The callback may be prefixed with the tenant, as in "{tenant}/{callback}".
+func (s *simulator) sendCallbacks(log *slog.Logger, callback string, body map[string]interface{}, delay, interval time.Duration, count int) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		log.Warn("Failed to encode callback", "error", err)
+		return
+	}
+	time.Sleep(delay)
+
+	for i := 1; i <= count; i++ {
+		if i > 1 {
+			time.Sleep(interval)
+		}
+		status, err := s.post(callback, payload)
+		if err != nil {
+			log.Warn("Callback failed", "callback", callback, "attempt", i, "error", err)
+			continue
+		}
+		log.Info("Callback sent", "callback", callback, "attempt", i, "status", status)
+		log.Debug("Callback body", "body", json.RawMessage(payload))
+	}
+}
+
+// post sends one callback, signed the way the adapter's webhook expects
+func (s *simulator) post(callback string, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, s.webhookURL+"/"+callback, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.hmacSecret) > 0 {
+		mac := hmac.New(sha256.New, s.hmacSecret)
+		mac.Write(payload)
+		req.Header.Set(s.hmacHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+	if s.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// ack writes the Beckn ACK
+func ack(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "ACK",
+			},
+		},
+	})
+}
+
+// nack writes a Beckn NACK with an error
+func nack(c *fiber.Ctx, status int, errorType, code, message string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    errorType,
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+func statusOr(status, fallback int) int {
+	if status != 0 {
+		return status
+	}
+	return fallback
+}
+
+// defaultConfigPath returns CONFIG_PATH, or the default configuration file
+func defaultConfigPath() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	return config.DefaultPath
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenarios the simulator can play for a forwarded request
+const (
+	// scenarioCallback ACKs and posts one callback
+	scenarioCallback = "callback"
+	// scenarioDuplicate ACKs and posts the same callback Count times
+	scenarioDuplicate = "duplicate"
+	// scenarioError ACKs and posts a callback carrying a Beckn error
+	scenarioError = "error"
+	// scenarioNack rejects the request with a Beckn NACK
+	scenarioNack = "nack"
+	// scenarioHTTPError fails the request with a non-Beckn HTTP error
+	scenarioHTTPError = "http_error"
+	// scenarioNone ACKs and never calls back
+	scenarioNone = "none"
+)
+
+// Headers a client can send through the adapter to override the scenario of one request
+const (
+	scenarioHeader = "X-Simulator-Scenario"
+	delayHeader    = "X-Simulator-Delay"
+)
+
+// Scenario describes how the simulator answers a route
+type Scenario struct {
+	Scenario string `yaml:"scenario"`
+	// Delay is the wait before the callback, or before the response on sync routes
+	Delay time.Duration `yaml:"delay"`
+	// Count is the number of callbacks sent by the duplicate scenario
+	Count int `yaml:"count"`
+	// Interval separates duplicate callbacks
+	Interval time.Duration `yaml:"interval"`
+	// Fixture is the callback template name in the fixtures directory; defaults to the callback route
+	Fixture string `yaml:"fixture"`
+	// Status is the HTTP status of nack and http_error responses
+	Status int `yaml:"status"`
+	// ErrorCode and ErrorMessage fill the error of the error and nack scenarios
+	ErrorCode    string `yaml:"error_code"`
+	ErrorMessage string `yaml:"error_message"`
+}
+
+// Scenarios is the simulator scenario file format
+type Scenarios struct {
+	Default Scenario            `yaml:"default"`
+	Routes  map[string]Scenario `yaml:"routes"`
+}
+
+// defaultScenarios returns the built-in scenarios used when no scenario file overrides them
+func defaultScenarios() *Scenarios {
+	return &Scenarios{
+		Default: Scenario{
+			Scenario:     scenarioCallback,
+			Delay:        500 * time.Millisecond,
+			Count:        2,
+			Interval:     100 * time.Millisecond,
+			ErrorCode:    "30000",
+			ErrorMessage: "Simulated error",
+		},
+		Routes: map[string]Scenario{},
+	}
+}
+
+// loadScenarios reads the scenario file
+// Values in the file override the built-in defaults; a missing file keeps the defaults.
+func loadScenarios(path string) (*Scenarios, error) {
+	scenarios := defaultScenarios()
+	if path == "" {
+		return scenarios, nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		simLog.Info("Scenario file not found, using built-in scenarios", "path", path)
+		return scenarios, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read scenarios: %w", err)
+	}
+
+	var file Scenarios
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse scenarios YAML: %w", err)
+	}
+	scenarios.Default = file.Default.mergeOnto(scenarios.Default)
+	for route, scenario := range file.Routes {
+		scenarios.Routes[route] = scenario
+	}
+	for route, scenario := range scenarios.Routes {
+		if err := scenario.mergeOnto(scenarios.Default).validate(); err != nil {
+			return nil, fmt.Errorf("route %s: %w", route, err)
+		}
+	}
+	if err := scenarios.Default.validate(); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	return scenarios, nil
+}
+
+// For returns the effective scenario for a route
+func (s *Scenarios) For(route string) Scenario {
+	scenario := s.Default
+	if routeScenario, ok := s.Routes[route]; ok {
+		scenario = routeScenario.mergeOnto(scenario)
+	}
+	return scenario
+}
+
+// withOverrides applies the per-request scenario headers
+func (s Scenario) withOverrides(scenario, delay string) (Scenario, error) {
+	if scenario != "" {
+		s.Scenario = scenario
+	}
+	if delay != "" {
+		parsed, err := time.ParseDuration(delay)
+		if err != nil {
+			return s, fmt.Errorf("%s %q is not a duration", delayHeader, delay)
+		}
+		s.Delay = parsed
+	}
+	return s, s.validate()
+}
+
+// validate checks the scenario name and its settings
+func (s Scenario) validate() error {
+	switch s.Scenario {
+	case scenarioCallback, scenarioDuplicate, scenarioError, scenarioNack, scenarioHTTPError, scenarioNone:
+	default:
+		return fmt.Errorf("unknown scenario %q", s.Scenario)
+	}
+	if s.Delay < 0 || s.Interval < 0 {
+		return errors.New("delay and interval must not be negative")
+	}
+	if s.Scenario == scenarioDuplicate && s.Count < 1 {
+		return errors.New("count must be at least 1")
+	}
+	if s.Status != 0 && (s.Status < 100 || s.Status > 599) {
+		return fmt.Errorf("status %d is not an HTTP status", s.Status)
+	}
+	return nil
+}
+
+// mergeOnto returns base with every field set in s overriding it
+func (s Scenario) mergeOnto(base Scenario) Scenario {
+	if s.Scenario != "" {
+		base.Scenario = s.Scenario
+	}
+	if s.Delay != 0 {
+		base.Delay = s.Delay
+	}
+	if s.Count != 0 {
+		base.Count = s.Count
+	}
+	if s.Interval != 0 {
+		base.Interval = s.Interval
+	}
+	if s.Fixture != "" {
+		base.Fixture = s.Fixture
+	}
+	if s.Status != 0 {
+		base.Status = s.Status
+	}
+	if s.ErrorCode != "" {
+		base.ErrorCode = s.ErrorCode
+	}
+	if s.ErrorMessage != "" {
+		base.ErrorMessage = s.ErrorMessage
+	}
+	return base
+}
@@ -1,29 +1,393 @@
 package config
 
-import "os"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
 
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the configuration file read when no other path is given
+const DefaultPath = "config/config.yaml"
+
+// Config is the adapter configuration
+// Values are read from the configuration file and then overridden by environment variables.
 type Config struct {
-	Port          string
-	AppEnv        string
-	OnixURL       string
-	RedisURL      string
-	RedisPassword string
+	Server         ServerConfig        `yaml:"server"`
+	Onix           OnixConfig          `yaml:"onix"`
+	Callbacks      CallbackConfig      `yaml:"callbacks"`
+	Routes         RouteConfig         `yaml:"routes"`
+	Redis          RedisConfig         `yaml:"redis"`
+	Mappings       MappingsConfig      `yaml:"mappings"`
+	CORS           CORSConfig          `yaml:"cors"`
+	Logging        LoggingConfig       `yaml:"logging"`
+	Tracing        TracingConfig       `yaml:"tracing"`
+	Health         HealthConfig        `yaml:"health"`
+	CircuitBreaker CircuitConfig       `yaml:"circuit_breaker"`
+	Policies       PolicyConfig        `yaml:"policies"`
+	Auth           AuthConfig          `yaml:"auth"`
+	Webhook        WebhookConfig       `yaml:"webhook"`
+	Admin          AdminConfig         `yaml:"admin"`
+	Capture        CaptureConfig       `yaml:"capture"`
+	Batch          BatchConfig         `yaml:"batch"`
+	Lifecycle      LifecycleConfig     `yaml:"lifecycle"`
+	Catalog        CatalogConfig       `yaml:"catalog"`
+	ResponseCache  ResponseCacheConfig `yaml:"response_cache"`
+
+	// source is the file the configuration was read from, or "" if none was found
+	source string
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port   string `yaml:"port"`
+	AppEnv string `yaml:"app_env"`
+	// Debug includes internal transformation details in error responses
+	Debug bool `yaml:"debug"`
+	// ReadTimeout, WriteTimeout and IdleTimeout bound client connections; 0 disables each
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// DrainTimeout is how long shutdown waits for in-flight requests before aborting them
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+	// ShutdownTimeout bounds closing client connections once draining is over
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// OnixConfig configures the default ONIX upstream
+type OnixConfig struct {
+	URL string `yaml:"url"`
+	// URLs lists ONIX replicas and takes precedence over URL
+	URLs      []string `yaml:"urls"`
+	Balancing string   `yaml:"balancing"`
+	// Timeout bounds each sync request to ONIX
+	Timeout        time.Duration `yaml:"timeout"`
+	ProbePath      string        `yaml:"probe_path"`
+	HealthInterval time.Duration `yaml:"health_interval"`
+}
+
+// Replicas returns the ONIX replica URLs, falling back to URL
+func (o OnixConfig) Replicas() []string {
+	if len(o.URLs) > 0 {
+		return o.URLs
+	}
+	return []string{o.URL}
+}
+
+// CallbackConfig configures how async requests wait for their callback
+type CallbackConfig struct {
+	WaitTimeout time.Duration `yaml:"wait_timeout"`
+	// PendingTTL is how long a pending request is kept in Redis; it must outlast WaitTimeout
+	PendingTTL time.Duration `yaml:"pending_ttl"`
+}
+
+// RouteConfig is the Beckn route table
+type RouteConfig struct {
+	// Sync routes return the ONIX response directly instead of waiting for a callback
+	Sync []string `yaml:"sync"`
+	// Callbacks maps each forward route to its callback route
+	Callbacks map[string]string `yaml:"callbacks"`
+}
+
+// RedisConfig configures the Redis connection
+type RedisConfig struct {
+	// URL is host:port or a redis:// or rediss:// URL carrying credentials, DB and TLS
+	URL string `yaml:"url"`
+	// Addrs lists Sentinel or Cluster seed addresses; URL's address is used when empty
+	Addrs []string `yaml:"addrs"`
+	// Mode is standalone, sentinel or cluster
+	Mode     string `yaml:"mode"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// DB overrides the URL's database when non-zero
+	DB               int            `yaml:"db"`
+	MasterName       string         `yaml:"master_name"`
+	SentinelUsername string         `yaml:"sentinel_username"`
+	SentinelPassword string         `yaml:"sentinel_password"`
+	TLS              RedisTLSConfig `yaml:"tls"`
+	PoolSize         int            `yaml:"pool_size"`
+	MinIdleConns     int            `yaml:"min_idle_conns"`
+	// Timeouts of 0 use the go-redis defaults
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	PoolTimeout  time.Duration `yaml:"pool_timeout"`
+}
+
+// RedisTLSConfig configures TLS to Redis; a rediss:// URL enables it as well
+type RedisTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// MappingsConfig locates the transformation mappings and the error catalog
+type MappingsConfig struct {
+	Path         string `yaml:"path"`
+	ErrorCatalog string `yaml:"error_catalog"`
+}
+
+// CORSConfig configures cross-origin requests
+type CORSConfig struct {
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowMethods     []string      `yaml:"allow_methods"`
+	AllowHeaders     []string      `yaml:"allow_headers"`
+	ExposeHeaders    []string      `yaml:"expose_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// LoggingConfig configures structured logging
+type LoggingConfig struct {
+	Level        string            `yaml:"level"`
+	Format       string            `yaml:"format"`
+	ModuleLevels map[string]string `yaml:"module_levels"`
+	RedactPaths  []string          `yaml:"redact_paths"`
+}
+
+// TracingConfig configures OpenTelemetry tracing
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// HealthConfig configures the readiness checks
+type HealthConfig struct {
+	Timeout         time.Duration `yaml:"timeout"`
+	MaxPendingWaits int           `yaml:"max_pending_waits"`
+}
+
+// CircuitConfig configures the ONIX circuit breakers
+type CircuitConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"`
+	OpenTimeout      time.Duration `yaml:"open_timeout"`
+	HalfOpenProbes   int           `yaml:"half_open_probes"`
+}
+
+// PolicyConfig locates the policy files
+type PolicyConfig struct {
+	RetryPath     string `yaml:"retry"`
+	RateLimitPath string `yaml:"rate_limits"`
+	TenantsPath   string `yaml:"tenants"`
+}
+
+// AuthConfig configures client authentication for /api
+type AuthConfig struct {
+	Methods      []string `yaml:"methods"`
+	APIKeyHeader string   `yaml:"api_key_header"`
+	// APIKeys maps client IDs to their static API keys
+	APIKeys        map[string]string `yaml:"api_keys"`
+	JWKSPath       string            `yaml:"jwks_path"`
+	JWTIssuer      string            `yaml:"jwt_issuer"`
+	JWTAudience    string            `yaml:"jwt_audience"`
+	JWTClientClaim string            `yaml:"jwt_client_claim"`
 }
 
-func Load() *Config {
+// WebhookConfig configures callback authentication for /webhook
+type WebhookConfig struct {
+	HMACSecret   string   `yaml:"hmac_secret"`
+	HMACHeader   string   `yaml:"hmac_header"`
+	BearerTokens []string `yaml:"bearer_tokens"`
+	AllowedCIDRs []string `yaml:"allowed_cidrs"`
+}
+
+// AdminConfig configures the /admin endpoints
+type AdminConfig struct {
+	APIKey string `yaml:"api_key"`
+}
+
+// CaptureConfig configures traffic capture for later replay
+type CaptureConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+	// Redact masks the logging redaction paths in captured bodies
+	Redact bool `yaml:"redact"`
+}
+
+// BatchConfig configures /api/batch
+type BatchConfig struct {
+	// MaxItems caps the number of requests in one batch
+	MaxItems int `yaml:"max_items"`
+	// Parallelism is how many items of a batch are forwarded at once
+	Parallelism int `yaml:"parallelism"`
+	// Timeout bounds a whole batch; items that could not finish before it fail without being forwarded
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// LifecycleConfig configures the transaction lifecycle checks
+type LifecycleConfig struct {
+	// Enforcement handles requests invalid in the transaction's state: off, warn or reject
+	Enforcement string `yaml:"enforcement"`
+}
+
+// CatalogConfig configures the catalog cache built from search responses
+type CatalogConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long a transaction's catalog is kept after its last search response
+	TTL time.Duration `yaml:"ttl"`
+	// SelectValidation handles select requests for items not in the catalog: off, warn or reject
+	SelectValidation string `yaml:"select_validation"`
+	// MaxPageSize caps page_size on /api/catalog
+	MaxPageSize int `yaml:"max_page_size"`
+}
+
+// ResponseCacheConfig configures the cache of ONIX responses to sync requests
+type ResponseCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Routes lists the cached sync routes and their TTLs; other routes are never cached
+	Routes map[string]time.Duration `yaml:"routes"`
+	// BypassHeader makes a request skip the cached response when present
+	BypassHeader string `yaml:"bypass_header"`
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
 	return &Config{
-		Port:          getEnv("PORT", "3000"),
-		AppEnv:        getEnv("APP_ENV", "development"),
-		OnixURL:       getEnv("ONIX_URL", "http://localhost:8080"),
-		RedisURL:      getEnv("REDIS_URL", "localhost:6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
+		Server: ServerConfig{
+			Port:            "3000",
+			AppEnv:          "development",
+			DrainTimeout:    35 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Onix: OnixConfig{
+			URL:            "http://localhost:8080",
+			Balancing:      "round_robin",
+			Timeout:        30 * time.Second,
+			HealthInterval: 10 * time.Second,
+		},
+		Callbacks: CallbackConfig{
+			WaitTimeout: 30 * time.Second,
+			PendingTTL:  35 * time.Second,
+		},
+		Routes: RouteConfig{
+			Sync: []string{"search", "discover"},
+			Callbacks: map[string]string{
+				"discover": "on_discover",
+				"search":   "on_search",
+				"select":   "on_select",
+				"init":     "on_init",
+				"confirm":  "on_confirm",
+				"update":   "on_update",
+				"track":    "on_track",
+				"rating":   "on_rating",
+				"support":  "on_support",
+				"cancel":   "on_cancel",
+				"status":   "on_status",
+			},
+		},
+		Redis: RedisConfig{
+			URL:  "localhost:6379",
+			Mode: "standalone",
+		},
+		Mappings: MappingsConfig{
+			Path:         "config/mappings.yaml",
+			ErrorCatalog: "config/error_codes.yaml",
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"*"},
+			AllowMethods: []string{"GET", "POST", "HEAD", "PUT", "DELETE", "PATCH"},
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "bap-sync-adapter",
+			SampleRatio: 1.0,
+		},
+		Health: HealthConfig{
+			Timeout:         2 * time.Second,
+			MaxPendingWaits: 1000,
+		},
+		CircuitBreaker: CircuitConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      30 * time.Second,
+			HalfOpenProbes:   1,
+		},
+		Policies: PolicyConfig{
+			RetryPath:     "config/retry_policies.yaml",
+			RateLimitPath: "config/rate_limits.yaml",
+			TenantsPath:   "config/tenants.yaml",
+		},
+		Auth: AuthConfig{
+			APIKeyHeader:   "X-API-Key",
+			JWTClientClaim: "sub",
+		},
+		Webhook: WebhookConfig{
+			HMACHeader: "X-Signature",
+		},
+		Capture: CaptureConfig{
+			Dir:    "captures",
+			Redact: true,
+		},
+		Batch: BatchConfig{
+			MaxItems:    100,
+			Parallelism: 10,
+			Timeout:     90 * time.Second,
+		},
+		Lifecycle: LifecycleConfig{
+			Enforcement: "warn",
+		},
+		Catalog: CatalogConfig{
+			TTL:              30 * time.Minute,
+			SelectValidation: "warn",
+			MaxPageSize:      100,
+		},
+		ResponseCache: ResponseCacheConfig{
+			BypassHeader: "X-Cache-Bypass",
+		},
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// Load reads the YAML configuration file, applies environment overrides and validates the result
+// Only a missing DefaultPath is tolerated, falling back to the built-in defaults and the
+// environment; any other path must exist, so a mistyped --config or CONFIG_PATH fails.
+// Keys set in the file replace the defaults; maps such as routes.callbacks are replaced
+// as a whole rather than merged, so the file can drop default entries.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && path == DefaultPath:
+		case err != nil:
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		default:
+			// Decoding merges into existing maps, so clear the default map and restore it
+			// only if the file does not set its own
+			callbacks := cfg.Routes.Callbacks
+			cfg.Routes.Callbacks = nil
+
+			decoder := yaml.NewDecoder(bytes.NewReader(data))
+			decoder.KnownFields(true)
+			if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+			}
+			if cfg.Routes.Callbacks == nil {
+				cfg.Routes.Callbacks = callbacks
+			}
+			cfg.source = path
+		}
+	}
+
+	// Report malformed environment variables together with the validation errors
+	if err := errors.Join(cfg.applyEnv(), cfg.Validate()); err != nil {
+		return nil, err
 	}
-	return value
+	return cfg, nil
+}
+
+// Source returns the file the configuration was read from, or "" if none was found
+func (c *Config) Source() string {
+	return c.source
 }
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("Default().Validate() = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// field is the field path expected in the error, or "" for a valid configuration
+		field string
+	}{
+		{"invalid port", func(c *Config) { c.Server.Port = "http" }, "server.port"},
+		{"port out of range", func(c *Config) { c.Server.Port = "70000" }, "server.port"},
+		{"write timeout below the callback wait", func(c *Config) { c.Server.WriteTimeout = c.Callbacks.WaitTimeout }, "server.write_timeout"},
+		{"write timeout disabled", func(c *Config) { c.Server.WriteTimeout = 0 }, ""},
+		{"onix url without scheme", func(c *Config) { c.Onix.URL = "localhost:8081" }, "onix.url"},
+		{"invalid replica", func(c *Config) { c.Onix.URLs = []string{"http://a:1", "ftp://b"} }, "onix.urls[1]"},
+		{"unknown balancing", func(c *Config) { c.Onix.Balancing = "random" }, "onix.balancing"},
+		{"pending ttl below the callback wait", func(c *Config) { c.Callbacks.PendingTTL = c.Callbacks.WaitTimeout }, "callbacks.pending_ttl"},
+		{"sync route without callback", func(c *Config) { c.Routes.Sync = append(c.Routes.Sync, "on_search") }, "routes.sync"},
+		{"wildcard origin with credentials", func(c *Config) {
+			c.CORS.AllowOrigins = []string{"*"}
+			c.CORS.AllowCredentials = true
+		}, "cors.allow_credentials"},
+		{"unknown log level", func(c *Config) { c.Logging.Level = "verbose" }, "logging.level"},
+		{"unknown module log level", func(c *Config) { c.Logging.ModuleLevels = map[string]string{"redis": "loud"} }, "logging.module_levels.redis"},
+		{"sample ratio above 1", func(c *Config) { c.Tracing.SampleRatio = 1.5 }, "tracing.sample_ratio"},
+		{"api key method without keys", func(c *Config) { c.Auth.Methods = []string{"api_key"} }, "auth.api_keys"},
+		{"unknown auth method", func(c *Config) { c.Auth.Methods = []string{"basic"} }, "auth.methods"},
+		{"invalid webhook CIDR", func(c *Config) { c.Webhook.AllowedCIDRs = []string{"10.0.0.0/8", "nope"} }, "webhook.allowed_cidrs[1]"},
+		{"bare webhook IP", func(c *Config) { c.Webhook.AllowedCIDRs = []string{"10.0.0.1"} }, ""},
+		{"production without admin key", func(c *Config) { c.Server.AppEnv = "production" }, "admin.api_key"},
+		{"production with admin key", func(c *Config) {
+			c.Server.AppEnv = "production"
+			c.Admin.APIKey = "secret"
+		}, ""},
+		{"capture without dir", func(c *Config) {
+			c.Capture.Enabled = true
+			c.Capture.Dir = ""
+		}, "capture.dir"},
+		{"batch timeout below the callback wait", func(c *Config) { c.Batch.Timeout = c.Callbacks.WaitTimeout - time.Second }, "batch.timeout"},
+		{"batch timeout beyond the write timeout", func(c *Config) {
+			c.Server.WriteTimeout = time.Minute
+			c.Batch.MaxItems = 10
+		}, "batch.timeout"},
+		{"batch rounds beyond the write timeout", func(c *Config) {
+			c.Server.WriteTimeout = 2 * time.Minute
+			c.Batch.Timeout = time.Minute
+		}, "batch.parallelism"},
+		{"batch within the write timeout", func(c *Config) {
+			c.Server.WriteTimeout = 2 * time.Minute
+			c.Batch.Timeout = time.Minute
+			c.Batch.MaxItems = 30
+		}, ""},
+		{"unknown lifecycle enforcement", func(c *Config) { c.Lifecycle.Enforcement = "strict" }, "lifecycle.enforcement"},
+		{"unknown select validation", func(c *Config) { c.Catalog.SelectValidation = "strict" }, "catalog.select_validation"},
+		{"response cache without routes", func(c *Config) { c.ResponseCache.Enabled = true }, "response_cache.routes"},
+		{"response cache for an async route", func(c *Config) {
+			c.ResponseCache.Routes = map[string]time.Duration{"select": time.Minute}
+		}, "response_cache.routes"},
+		{"response cache with zero TTL", func(c *Config) {
+			c.ResponseCache.Routes = map[string]time.Duration{"search": 0}
+		}, "response_cache.routes.search"},
+		{"response cache for search", func(c *Config) {
+			c.ResponseCache.Enabled = true
+			c.ResponseCache.Routes = map[string]time.Duration{"search": time.Minute}
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(c)
+			err := c.Validate()
+			switch {
+			case tt.field == "" && err != nil:
+				t.Fatalf("Validate() = %v, want no error", err)
+			case tt.field != "" && err == nil:
+				t.Fatalf("Validate() = nil, want an error for %s", tt.field)
+			case tt.field != "" && !strings.Contains(err.Error(), tt.field+":"):
+				t.Fatalf("Validate() = %v, want an error for %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(c *Config) any
+		want  any
+	}{
+		{"string", map[string]string{"PORT": "8080"}, func(c *Config) any { return c.Server.Port }, "8080"},
+		{"string is trimmed", map[string]string{"LOG_LEVEL": " debug "}, func(c *Config) any { return c.Logging.Level }, "debug"},
+		{"empty keeps the default", map[string]string{"PORT": ""}, func(c *Config) any { return c.Server.Port }, "3000"},
+		{"bool", map[string]string{"DEBUG": "true"}, func(c *Config) any { return c.Server.Debug }, true},
+		{"int", map[string]string{"REDIS_DB": "3"}, func(c *Config) any { return c.Redis.DB }, 3},
+		{"float", map[string]string{"TRACING_SAMPLE_RATIO": "0.25"}, func(c *Config) any { return c.Tracing.SampleRatio }, 0.25},
+		{"duration", map[string]string{"ONIX_TIMEOUT": "5s"}, func(c *Config) any { return c.Onix.Timeout }, 5 * time.Second},
+		{"list", map[string]string{"ONIX_URLS": "http://a:1, http://b:2,,"}, func(c *Config) any { return c.Onix.URLs }, []string{"http://a:1", "http://b:2"}},
+		{"mapping", map[string]string{"AUTH_API_KEYS": "a=k1, b = k2"}, func(c *Config) any { return c.Auth.APIKeys }, map[string]string{"a": "k1", "b": "k2"}},
+		{"durations", map[string]string{"RESPONSE_CACHE_ROUTES": "search=30s,discover=1m"}, func(c *Config) any { return c.ResponseCache.Routes },
+			map[string]time.Duration{"search": 30 * time.Second, "discover": time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			c := Default()
+			if err := c.applyEnv(); err != nil {
+				t.Fatalf("applyEnv() = %v", err)
+			}
+			if got := tt.check(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	tests := []struct {
+		key, value string
+		// hidden is a secret that must not appear in the error
+		hidden string
+	}{
+		{"DEBUG", "yes please", ""},
+		{"REDIS_DB", "one", ""},
+		{"TRACING_SAMPLE_RATIO", "half", ""},
+		{"ONIX_TIMEOUT", "5", ""},
+		{"AUTH_API_KEYS", "a=super-secret-key,b", "super-secret-key"},
+		{"RESPONSE_CACHE_ROUTES", "search=soon", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			err := Default().applyEnv()
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Fatalf("applyEnv() = %v, want an error for %s", err, tt.key)
+			}
+			if tt.hidden != "" && strings.Contains(err.Error(), tt.hidden) {
+				t.Errorf("applyEnv() = %v, leaks the value of %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("shipped config", func(t *testing.T) {
+		if _, err := Load("config.yaml"); err != nil {
+			t.Fatalf("Load(config.yaml) = %v", err)
+		}
+	})
+
+	t.Run("missing default file uses defaults", func(t *testing.T) {
+		t.Setenv("PORT", "")
+		c, err := Load(DefaultPath)
+		if err != nil {
+			t.Fatalf("Load = %v", err)
+		}
+		if c.Source() != "" || c.Server.Port != "3000" {
+			t.Errorf("source = %q, port = %q, want defaults", c.Source(), c.Server.Port)
+		}
+	})
+
+	t.Run("missing explicit file", func(t *testing.T) {
+		if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+			t.Fatal("Load accepted a missing file")
+		}
+	})
+
+	t.Run("maps replace the defaults", func(t *testing.T) {
+		path := write("callbacks.yaml", "routes:\n  sync: [search]\n  callbacks:\n    search: on_search\n    select: on_select\n")
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load = %v", err)
+		}
+		want := map[string]string{"search": "on_search", "select": "on_select"}
+		if !reflect.DeepEqual(c.Routes.Callbacks, want) {
+			t.Errorf("routes.callbacks = %v, want %v", c.Routes.Callbacks, want)
+		}
+	})
+
+	t.Run("maps keep the defaults when not set", func(t *testing.T) {
+		path := write("nocallbacks.yaml", "server:\n  port: \"4000\"\n")
+		t.Setenv("PORT", "")
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load = %v", err)
+		}
+		if !reflect.DeepEqual(c.Routes.Callbacks, Default().Routes.Callbacks) {
+			t.Errorf("routes.callbacks = %v, want the defaults", c.Routes.Callbacks)
+		}
+	})
+
+	t.Run("environment overrides the file", func(t *testing.T) {
+		path := write("port.yaml", "server:\n  port: \"4000\"\n")
+		t.Setenv("PORT", "5000")
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load = %v", err)
+		}
+		if c.Server.Port != "5000" || c.Source() != path {
+			t.Errorf("port = %q, source = %q, want 5000 from %s", c.Server.Port, c.Source(), path)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		path := write("unknown.yaml", "server:\n  prot: \"4000\"\n")
+		if _, err := Load(path); err == nil {
+			t.Fatal("Load accepted an unknown key")
+		}
+	})
+
+	t.Run("env and validation errors are reported together", func(t *testing.T) {
+		path := write("invalid.yaml", "server:\n  port: \"0\"\n")
+		t.Setenv("REDIS_DB", "one")
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), "server.port") || !strings.Contains(err.Error(), "REDIS_DB=") {
+			t.Fatalf("Load = %v, want errors for server.port and REDIS_DB", err)
+		}
+	})
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides configuration values with environment variables
+// Only variables that are set override the file; malformed values are reported together.
+func (c *Config) applyEnv() error {
+	env := &envOverrides{}
+
+	env.str("PORT", &c.Server.Port)
+	env.str("APP_ENV", &c.Server.AppEnv)
+	env.bool("DEBUG", &c.Server.Debug)
+	env.duration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout)
+	env.duration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	env.duration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	env.duration("SHUTDOWN_DRAIN_TIMEOUT", &c.Server.DrainTimeout)
+	env.duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	env.str("ONIX_URL", &c.Onix.URL)
+	env.list("ONIX_URLS", &c.Onix.URLs)
+	env.str("ONIX_BALANCING", &c.Onix.Balancing)
+	env.duration("ONIX_TIMEOUT", &c.Onix.Timeout)
+	env.str("ONIX_PROBE_PATH", &c.Onix.ProbePath)
+	env.duration("ONIX_HEALTH_INTERVAL", &c.Onix.HealthInterval)
+
+	env.duration("CALLBACK_WAIT_TIMEOUT", &c.Callbacks.WaitTimeout)
+	env.duration("PENDING_REQUEST_TTL", &c.Callbacks.PendingTTL)
+	env.list("SYNC_ROUTES", &c.Routes.Sync)
+
+	env.str("REDIS_URL", &c.Redis.URL)
+	env.list("REDIS_ADDRS", &c.Redis.Addrs)
+	env.str("REDIS_MODE", &c.Redis.Mode)
+	env.str("REDIS_USERNAME", &c.Redis.Username)
+	env.str("REDIS_PASSWORD", &c.Redis.Password)
+	env.int("REDIS_DB", &c.Redis.DB)
+	env.str("REDIS_MASTER_NAME", &c.Redis.MasterName)
+	env.str("REDIS_SENTINEL_USERNAME", &c.Redis.SentinelUsername)
+	env.str("REDIS_SENTINEL_PASSWORD", &c.Redis.SentinelPassword)
+	env.bool("REDIS_TLS", &c.Redis.TLS.Enabled)
+	env.str("REDIS_TLS_CA_FILE", &c.Redis.TLS.CAFile)
+	env.str("REDIS_TLS_CERT_FILE", &c.Redis.TLS.CertFile)
+	env.str("REDIS_TLS_KEY_FILE", &c.Redis.TLS.KeyFile)
+	env.str("REDIS_TLS_SERVER_NAME", &c.Redis.TLS.ServerName)
+	env.bool("REDIS_TLS_INSECURE_SKIP_VERIFY", &c.Redis.TLS.InsecureSkipVerify)
+	env.int("REDIS_POOL_SIZE", &c.Redis.PoolSize)
+	env.int("REDIS_MIN_IDLE_CONNS", &c.Redis.MinIdleConns)
+	env.duration("REDIS_DIAL_TIMEOUT", &c.Redis.DialTimeout)
+	env.duration("REDIS_READ_TIMEOUT", &c.Redis.ReadTimeout)
+	env.duration("REDIS_WRITE_TIMEOUT", &c.Redis.WriteTimeout)
+	env.duration("REDIS_POOL_TIMEOUT", &c.Redis.PoolTimeout)
+
+	env.str("MAPPINGS_PATH", &c.Mappings.Path)
+	env.str("ERROR_CATALOG_PATH", &c.Mappings.ErrorCatalog)
+
+	env.list("CORS_ALLOW_ORIGINS", &c.CORS.AllowOrigins)
+	env.list("CORS_ALLOW_METHODS", &c.CORS.AllowMethods)
+	env.list("CORS_ALLOW_HEADERS", &c.CORS.AllowHeaders)
+	env.list("CORS_EXPOSE_HEADERS", &c.CORS.ExposeHeaders)
+	env.bool("CORS_ALLOW_CREDENTIALS", &c.CORS.AllowCredentials)
+	env.duration("CORS_MAX_AGE", &c.CORS.MaxAge)
+
+	env.str("LOG_LEVEL", &c.Logging.Level)
+	env.str("LOG_FORMAT", &c.Logging.Format)
+	env.mapping("LOG_MODULE_LEVELS", &c.Logging.ModuleLevels)
+	env.list("LOG_REDACT_PATHS", &c.Logging.RedactPaths)
+
+	env.str("TRACING_EXPORTER", &c.Tracing.Exporter)
+	env.str("TRACING_SERVICE_NAME", &c.Tracing.ServiceName)
+	env.float("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+
+	env.duration("HEALTH_CHECK_TIMEOUT", &c.Health.Timeout)
+	env.int("MAX_PENDING_WAITS", &c.Health.MaxPendingWaits)
+
+	env.int("CIRCUIT_FAILURE_THRESHOLD", &c.CircuitBreaker.FailureThreshold)
+	env.duration("CIRCUIT_OPEN_TIMEOUT", &c.CircuitBreaker.OpenTimeout)
+	env.int("CIRCUIT_HALF_OPEN_PROBES", &c.CircuitBreaker.HalfOpenProbes)
+
+	env.str("RETRY_POLICY_PATH", &c.Policies.RetryPath)
+	env.str("RATE_LIMIT_PATH", &c.Policies.RateLimitPath)
+	env.str("TENANTS_PATH", &c.Policies.TenantsPath)
+
+	env.list("AUTH_METHODS", &c.Auth.Methods)
+	env.str("AUTH_API_KEY_HEADER", &c.Auth.APIKeyHeader)
+	env.mapping("AUTH_API_KEYS", &c.Auth.APIKeys)
+	env.str("AUTH_JWKS_PATH", &c.Auth.JWKSPath)
+	env.str("AUTH_JWT_ISSUER", &c.Auth.JWTIssuer)
+	env.str("AUTH_JWT_AUDIENCE", &c.Auth.JWTAudience)
+	env.str("AUTH_JWT_CLIENT_CLAIM", &c.Auth.JWTClientClaim)
+
+	env.str("WEBHOOK_HMAC_SECRET", &c.Webhook.HMACSecret)
+	env.str("WEBHOOK_HMAC_HEADER", &c.Webhook.HMACHeader)
+	env.list("WEBHOOK_BEARER_TOKENS", &c.Webhook.BearerTokens)
+	env.list("WEBHOOK_ALLOWED_CIDRS", &c.Webhook.AllowedCIDRs)
+
+	env.str("ADMIN_API_KEY", &c.Admin.APIKey)
+
+	env.bool("CAPTURE_ENABLED", &c.Capture.Enabled)
+	env.str("CAPTURE_DIR", &c.Capture.Dir)
+	env.bool("CAPTURE_REDACT", &c.Capture.Redact)
+
+	env.int("BATCH_MAX_ITEMS", &c.Batch.MaxItems)
+	env.int("BATCH_PARALLELISM", &c.Batch.Parallelism)
+	env.duration("BATCH_TIMEOUT", &c.Batch.Timeout)
+
+	env.str("LIFECYCLE_ENFORCEMENT", &c.Lifecycle.Enforcement)
+
+	env.bool("CATALOG_ENABLED", &c.Catalog.Enabled)
+	env.duration("CATALOG_TTL", &c.Catalog.TTL)
+	env.str("CATALOG_SELECT_VALIDATION", &c.Catalog.SelectValidation)
+	env.int("CATALOG_MAX_PAGE_SIZE", &c.Catalog.MaxPageSize)
+
+	env.bool("RESPONSE_CACHE_ENABLED", &c.ResponseCache.Enabled)
+	env.durations("RESPONSE_CACHE_ROUTES", &c.ResponseCache.Routes)
+	env.str("RESPONSE_CACHE_BYPASS_HEADER", &c.ResponseCache.BypassHeader)
+
+	return errors.Join(env.errs...)
+}
+
+// envOverrides applies environment variables to configuration fields, collecting parse errors
+type envOverrides struct {
+	errs []error
+}
+
+// lookup returns the variable's value if it is set and not empty
+func (e *envOverrides) lookup(key string) (string, bool) {
+	value := strings.TrimSpace(os.Getenv(key))
+	return value, value != ""
+}
+
+// secretEnv lists variables whose values must never appear in errors
+var secretEnv = map[string]bool{
+	"AUTH_API_KEYS": true,
+}
+
+// fail records a parse error, echoing the value unless the variable is secret
+func (e *envOverrides) fail(key, value string, err error) {
+	if secretEnv[key] {
+		e.errs = append(e.errs, fmt.Errorf("%s: %w", key, err))
+		return
+	}
+	e.errs = append(e.errs, fmt.Errorf("%s=%q: %w", key, value, err))
+}
+
+func (e *envOverrides) str(key string, target *string) {
+	if value, ok := e.lookup(key); ok {
+		*target = value
+	}
+}
+
+func (e *envOverrides) bool(key string, target *bool) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		e.fail(key, value, errors.New("expected true or false"))
+		return
+	}
+	*target = parsed
+}
+
+func (e *envOverrides) int(key string, target *int) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		e.fail(key, value, errors.New("expected an integer"))
+		return
+	}
+	*target = parsed
+}
+
+func (e *envOverrides) float(key string, target *float64) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.fail(key, value, errors.New("expected a number"))
+		return
+	}
+	*target = parsed
+}
+
+func (e *envOverrides) duration(key string, target *time.Duration) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		e.fail(key, value, errors.New("expected a duration such as 500ms or 30s"))
+		return
+	}
+	*target = parsed
+}
+
+// list parses a comma-separated list
+// A variable that is set but empty is ignored, so it cannot clear a list from the file.
+func (e *envOverrides) list(key string, target *[]string) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*target = items
+}
+
+// mapping parses comma-separated key=value pairs
+func (e *envOverrides) mapping(key string, target *map[string]string) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return
+	}
+	result := map[string]string{}
+	for i, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, val, found := strings.Cut(item, "=")
+		if !found {
+			e.fail(key, value, fmt.Errorf("entry %d is not key=value", i))
+			return
+		}
+		result[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	}
+	*target = result
+}
+
+// durations parses comma-separated key=duration pairs
+func (e *envOverrides) durations(key string, target *map[string]time.Duration) {
+	var pairs map[string]string
+	e.mapping(key, &pairs)
+	if pairs == nil {
+		return
+	}
+	result := make(map[string]time.Duration, len(pairs))
+	for name, value := range pairs {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			e.fail(key, value, fmt.Errorf("expected a duration such as 30s for %q", name))
+			return
+		}
+		result[name] = parsed
+	}
+	*target = result
+}
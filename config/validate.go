@@ -0,0 +1,316 @@
+package config
+
+import (
+	"BAP_Sandbox/internal/auth"
+	"BAP_Sandbox/internal/lifecycle"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tracing"
+	"BAP_Sandbox/internal/upstream"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mask replaces secret values in printed configuration
+const mask = "****"
+
+// Validate checks the configuration, reporting every problem by its field path
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		v.fail("server.port", "must be a port number between 1 and 65535, got %q", c.Server.Port)
+	}
+	v.nonNegative("server.read_timeout", c.Server.ReadTimeout.Seconds())
+	v.nonNegative("server.write_timeout", c.Server.WriteTimeout.Seconds())
+	v.nonNegative("server.idle_timeout", c.Server.IdleTimeout.Seconds())
+	v.nonNegative("server.drain_timeout", c.Server.DrainTimeout.Seconds())
+	v.positive("server.shutdown_timeout", c.Server.ShutdownTimeout.Seconds())
+	if c.Server.WriteTimeout > 0 && c.Server.WriteTimeout <= c.Callbacks.WaitTimeout {
+		v.fail("server.write_timeout", "must exceed callbacks.wait_timeout (%s) or be 0, got %s", c.Callbacks.WaitTimeout, c.Server.WriteTimeout)
+	}
+
+	for i, replica := range c.Onix.Replicas() {
+		field := "onix.url"
+		if len(c.Onix.URLs) > 0 {
+			field = fmt.Sprintf("onix.urls[%d]", i)
+		}
+		v.httpURL(field, replica)
+	}
+	switch c.Onix.Balancing {
+	case "", upstream.BalanceRoundRobin, upstream.BalanceLeastPending:
+	default:
+		v.fail("onix.balancing", "must be %s or %s, got %q", upstream.BalanceRoundRobin, upstream.BalanceLeastPending, c.Onix.Balancing)
+	}
+	v.positive("onix.timeout", c.Onix.Timeout.Seconds())
+	v.nonNegative("onix.health_interval", c.Onix.HealthInterval.Seconds())
+
+	v.positive("callbacks.wait_timeout", c.Callbacks.WaitTimeout.Seconds())
+	if c.Callbacks.PendingTTL <= c.Callbacks.WaitTimeout {
+		v.fail("callbacks.pending_ttl", "must exceed callbacks.wait_timeout (%s), got %s", c.Callbacks.WaitTimeout, c.Callbacks.PendingTTL)
+	}
+
+	if len(c.Routes.Callbacks) == 0 {
+		v.fail("routes.callbacks", "must map at least one route")
+	}
+	for route, callback := range c.Routes.Callbacks {
+		if route == "" || callback == "" {
+			v.fail("routes.callbacks", "routes and callbacks must not be empty, got %q: %q", route, callback)
+		}
+	}
+	for _, route := range c.Routes.Sync {
+		if _, ok := c.Routes.Callbacks[route]; !ok {
+			v.fail("routes.sync", "route %q is not in routes.callbacks", route)
+		}
+	}
+
+	c.validateRedis(v)
+
+	if c.Mappings.Path == "" {
+		v.fail("mappings.path", "is required")
+	}
+	if c.Mappings.ErrorCatalog == "" {
+		v.fail("mappings.error_catalog", "is required")
+	}
+
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowOrigins, "*") {
+		v.fail("cors.allow_credentials", "cannot be combined with the wildcard origin \"*\"")
+	}
+	v.nonNegative("cors.max_age", c.CORS.MaxAge.Seconds())
+
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		v.fail("logging.level", "%v", err)
+	}
+	for module, level := range c.Logging.ModuleLevels {
+		if _, err := logging.ParseLevel(level); err != nil {
+			v.fail("logging.module_levels."+module, "%v", err)
+		}
+	}
+	switch c.Logging.Format {
+	case "", logging.FormatJSON, logging.FormatText:
+	default:
+		v.fail("logging.format", "must be %s or %s, got %q", logging.FormatJSON, logging.FormatText, c.Logging.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		v.fail("tracing.exporter", "must be %s, %s or %s, got %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.fail("tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	v.positive("health.timeout", c.Health.Timeout.Seconds())
+	v.nonNegative("health.max_pending_waits", float64(c.Health.MaxPendingWaits))
+
+	v.positive("circuit_breaker.failure_threshold", float64(c.CircuitBreaker.FailureThreshold))
+	v.positive("circuit_breaker.open_timeout", c.CircuitBreaker.OpenTimeout.Seconds())
+	v.positive("circuit_breaker.half_open_probes", float64(c.CircuitBreaker.HalfOpenProbes))
+
+	for _, method := range c.Auth.Methods {
+		switch method {
+		case auth.MethodAPIKey:
+			if len(c.Auth.APIKeys) == 0 {
+				v.fail("auth.api_keys", "is required when the %s method is enabled", auth.MethodAPIKey)
+			}
+		case auth.MethodJWT:
+			if c.Auth.JWKSPath == "" {
+				v.fail("auth.jwks_path", "is required when the %s method is enabled", auth.MethodJWT)
+			}
+		default:
+			v.fail("auth.methods", "unknown method %q (supported: %s, %s)", method, auth.MethodAPIKey, auth.MethodJWT)
+		}
+	}
+
+	for i, cidr := range c.Webhook.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+			v.fail(fmt.Sprintf("webhook.allowed_cidrs[%d]", i), "%q is not a CIDR or IP address", cidr)
+		}
+	}
+
+	if c.Admin.APIKey == "" && c.Server.AppEnv != "development" {
+		v.fail("admin.api_key", "is required unless server.app_env is development")
+	}
+
+	if c.Capture.Enabled && c.Capture.Dir == "" {
+		v.fail("capture.dir", "is required when capture is enabled")
+	}
+
+	v.positive("batch.max_items", float64(c.Batch.MaxItems))
+	v.positive("batch.parallelism", float64(c.Batch.Parallelism))
+	v.positive("batch.timeout", c.Batch.Timeout.Seconds())
+	if c.Batch.Timeout < c.Callbacks.WaitTimeout {
+		v.fail("batch.timeout", "must be at least callbacks.wait_timeout (%s), got %s", c.Callbacks.WaitTimeout, c.Batch.Timeout)
+	}
+	if c.Server.WriteTimeout > 0 && c.Batch.Parallelism > 0 {
+		if c.Batch.Timeout >= c.Server.WriteTimeout {
+			v.fail("batch.timeout", "must be below server.write_timeout (%s), got %s", c.Server.WriteTimeout, c.Batch.Timeout)
+		}
+		// Every round of parallel items can wait for its callbacks
+		rounds := (c.Batch.MaxItems + c.Batch.Parallelism - 1) / c.Batch.Parallelism
+		if worst := time.Duration(rounds) * c.Callbacks.WaitTimeout; worst >= c.Server.WriteTimeout {
+			v.fail("batch.parallelism", "a batch of %d items can take %s (%d rounds of callbacks.wait_timeout), beyond server.write_timeout (%s); raise batch.parallelism or lower batch.max_items",
+				c.Batch.MaxItems, worst, rounds, c.Server.WriteTimeout)
+		}
+	}
+
+	switch c.Lifecycle.Enforcement {
+	case lifecycle.EnforceOff, lifecycle.EnforceWarn, lifecycle.EnforceReject:
+	default:
+		v.fail("lifecycle.enforcement", "must be %s, %s or %s, got %q", lifecycle.EnforceOff, lifecycle.EnforceWarn, lifecycle.EnforceReject, c.Lifecycle.Enforcement)
+	}
+
+	v.positive("catalog.ttl", c.Catalog.TTL.Seconds())
+	switch c.Catalog.SelectValidation {
+	case lifecycle.EnforceOff, lifecycle.EnforceWarn, lifecycle.EnforceReject:
+	default:
+		v.fail("catalog.select_validation", "must be %s, %s or %s, got %q", lifecycle.EnforceOff, lifecycle.EnforceWarn, lifecycle.EnforceReject, c.Catalog.SelectValidation)
+	}
+	v.positive("catalog.max_page_size", float64(c.Catalog.MaxPageSize))
+
+	if c.ResponseCache.Enabled && len(c.ResponseCache.Routes) == 0 {
+		v.fail("response_cache.routes", "list the sync routes to cache when the response cache is enabled")
+	}
+	for route, ttl := range c.ResponseCache.Routes {
+		if !slices.Contains(c.Routes.Sync, route) {
+			v.fail("response_cache.routes", "route %q is not in routes.sync", route)
+		}
+		v.positive("response_cache.routes."+route, ttl.Seconds())
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// validateRedis checks the Redis connection settings for the selected mode
+func (c *Config) validateRedis(v *validator) {
+	r := c.Redis
+	if r.URL == "" && len(r.Addrs) == 0 {
+		v.fail("redis.url", "is required unless redis.addrs is set")
+	}
+	if scheme, _, ok := strings.Cut(r.URL, "://"); ok && scheme != "redis" && scheme != "rediss" {
+		v.fail("redis.url", "must be host:port or a redis:// or rediss:// URL, got scheme %q", scheme)
+	}
+	if r.DB < 0 {
+		v.fail("redis.db", "must not be negative, got %d", r.DB)
+	}
+
+	switch r.Mode {
+	case "", storage.ModeStandalone:
+		if len(r.Addrs) > 1 {
+			v.fail("redis.addrs", "standalone mode takes one address, got %d; set redis.mode to sentinel or cluster", len(r.Addrs))
+		}
+	case storage.ModeSentinel:
+		if r.MasterName == "" {
+			v.fail("redis.master_name", "is required in sentinel mode")
+		}
+	case storage.ModeCluster:
+		if r.DB != 0 {
+			v.fail("redis.db", "must be 0 in cluster mode, got %d", r.DB)
+		}
+	default:
+		v.fail("redis.mode", "must be %s, %s or %s, got %q", storage.ModeStandalone, storage.ModeSentinel, storage.ModeCluster, r.Mode)
+	}
+
+	if (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+		v.fail("redis.tls", "cert_file and key_file must be set together")
+	}
+	v.nonNegative("redis.pool_size", float64(r.PoolSize))
+	v.nonNegative("redis.min_idle_conns", float64(r.MinIdleConns))
+	v.nonNegative("redis.dial_timeout", r.DialTimeout.Seconds())
+	v.nonNegative("redis.read_timeout", r.ReadTimeout.Seconds())
+	v.nonNegative("redis.write_timeout", r.WriteTimeout.Seconds())
+	v.nonNegative("redis.pool_timeout", r.PoolTimeout.Seconds())
+}
+
+// validator collects validation errors
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) positive(field string, value float64) {
+	if value <= 0 {
+		v.fail(field, "must be greater than 0")
+	}
+}
+
+func (v *validator) nonNegative(field string, value float64) {
+	if value < 0 {
+		v.fail(field, "must not be negative")
+	}
+}
+
+// httpURL checks that value is an absolute http or https URL
+func (v *validator) httpURL(field, value string) {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		v.fail(field, "must be an absolute http(s) URL, got %q", value)
+	}
+}
+
+// Masked returns a copy of the configuration with secrets replaced
+func (c *Config) Masked() *Config {
+	masked := *c
+
+	if masked.Redis.Password != "" {
+		masked.Redis.Password = mask
+	}
+	if masked.Redis.SentinelPassword != "" {
+		masked.Redis.SentinelPassword = mask
+	}
+	masked.Redis.URL = maskURL(masked.Redis.URL)
+	masked.Onix.URL = maskURL(masked.Onix.URL)
+	masked.Onix.URLs = make([]string, len(c.Onix.URLs))
+	for i, replica := range c.Onix.URLs {
+		masked.Onix.URLs[i] = maskURL(replica)
+	}
+
+	if masked.Admin.APIKey != "" {
+		masked.Admin.APIKey = mask
+	}
+	if c.Auth.APIKeys != nil {
+		masked.Auth.APIKeys = make(map[string]string, len(c.Auth.APIKeys))
+		for client := range c.Auth.APIKeys {
+			masked.Auth.APIKeys[client] = mask
+		}
+	}
+	if masked.Webhook.HMACSecret != "" {
+		masked.Webhook.HMACSecret = mask
+	}
+	if c.Webhook.BearerTokens != nil {
+		masked.Webhook.BearerTokens = make([]string, len(c.Webhook.BearerTokens))
+		for i := range c.Webhook.BearerTokens {
+			masked.Webhook.BearerTokens[i] = mask
+		}
+	}
+	return &masked
+}
+
+// maskURL hides the password in a URL's userinfo
+func maskURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), mask)
+	}
+	return strings.Replace(parsed.String(), url.QueryEscape(mask), mask, 1)
+}
+
+// YAML renders the configuration with secrets masked
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c.Masked())
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyAuthenticator authenticates clients by a static API key header
+type APIKeyAuthenticator struct {
+	header string
+	keys   map[string]string
+}
+
+// NewAPIKeyAuthenticator creates an authenticator for the given client ID to API key map
+func NewAPIKeyAuthenticator(header string, keys map[string]string) (*APIKeyAuthenticator, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("api_key authentication requires at least one key in AUTH_API_KEYS")
+	}
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return &APIKeyAuthenticator{header: header, keys: keys}, nil
+}
+
+// Name returns the method name
+func (a *APIKeyAuthenticator) Name() string {
+	return MethodAPIKey
+}
+
+// Authenticate matches the API key header against the configured keys
+// Every key is compared in constant time so the match position does not leak.
+func (a *APIKeyAuthenticator) Authenticate(c *fiber.Ctx) (*Identity, error) {
+	provided := c.Get(a.header)
+	if provided == "" {
+		return nil, ErrNoCredentials
+	}
+
+	clientID := ""
+	for id, key := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+			clientID = id
+		}
+	}
+	if clientID == "" {
+		return nil, errors.New("unknown API key")
+	}
+	return &Identity{ClientID: clientID, Method: MethodAPIKey}, nil
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var authLog = logging.For("auth")
+
+// Supported authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries no credentials for it
+var ErrNoCredentials = errors.New("no credentials")
+
+// Identity is an authenticated client
+type Identity struct {
+	ClientID string
+	Method   string
+	Claims   map[string]interface{}
+}
+
+// Authenticator authenticates a request with one method
+type Authenticator interface {
+	// Name returns the method name used in logs and metrics
+	Name() string
+	// Authenticate returns the client identity, ErrNoCredentials if the request
+	// does not use this method, or an error if the credentials are invalid
+	Authenticate(c *fiber.Ctx) (*Identity, error)
+}
+
+// Options configures client authentication
+type Options struct {
+	// Methods lists the enabled methods in the order they are tried; empty disables authentication
+	Methods []string
+
+	APIKeyHeader string
+	// APIKeys maps client IDs to their static API keys
+	APIKeys map[string]string
+
+	JWKSPath       string
+	JWTIssuer      string
+	JWTAudience    string
+	JWTClientClaim string
+}
+
+var (
+	authenticators []Authenticator
+	// credentialHeaders carry client credentials and are never forwarded upstream
+	credentialHeaders = []string{fiber.HeaderAuthorization, "X-API-Key"}
+	authMu            sync.RWMutex
+)
+
+// Init builds the authenticators for the configured methods
+func Init(opts Options) error {
+	built := make([]Authenticator, 0, len(opts.Methods))
+	for _, method := range opts.Methods {
+		switch method {
+		case MethodAPIKey:
+			authenticator, err := NewAPIKeyAuthenticator(opts.APIKeyHeader, opts.APIKeys)
+			if err != nil {
+				return err
+			}
+			built = append(built, authenticator)
+		case MethodJWT:
+			authenticator, err := NewJWTAuthenticator(opts.JWKSPath, opts.JWTIssuer, opts.JWTAudience, opts.JWTClientClaim)
+			if err != nil {
+				return err
+			}
+			built = append(built, authenticator)
+		default:
+			return fmt.Errorf("unknown authentication method %q (supported: %s, %s)", method, MethodAPIKey, MethodJWT)
+		}
+	}
+
+	apiKeyHeader := opts.APIKeyHeader
+	if apiKeyHeader == "" {
+		apiKeyHeader = "X-API-Key"
+	}
+
+	authMu.Lock()
+	defer authMu.Unlock()
+	authenticators = built
+	credentialHeaders = []string{fiber.HeaderAuthorization, apiKeyHeader}
+	if len(built) == 0 {
+		authLog.Warn("Client authentication disabled, /api is open to all callers")
+	} else {
+		authLog.Info("Client authentication enabled", "methods", opts.Methods)
+	}
+	return nil
+}
+
+// CredentialHeaders returns the request headers carrying client credentials
+// They are stripped from requests forwarded to ONIX whether or not authentication is enabled.
+func CredentialHeaders() []string {
+	authMu.RLock()
+	defer authMu.RUnlock()
+	return credentialHeaders
+}
+
+// Middleware rejects requests that no configured authenticator accepts
+// The identity of accepted requests is attached to the request context.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authMu.RLock()
+		active := authenticators
+		authMu.RUnlock()
+
+		if len(active) == 0 {
+			return c.Next()
+		}
+
+		for _, authenticator := range active {
+			identity, err := authenticator.Authenticate(c)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+			if err != nil {
+				authLog.WarnContext(c.UserContext(), "Authentication failed", "method", authenticator.Name(), "path", c.Path(), "ip", c.IP(), "error", err)
+				metrics.AuthFailures.WithLabelValues(authenticator.Name(), "invalid").Inc()
+				return unauthorized(c, "Invalid credentials")
+			}
+
+			ctx := WithIdentity(c.UserContext(), identity)
+			ctx = logging.WithFields(ctx, "client_id", identity.ClientID, "auth_method", identity.Method)
+			c.SetUserContext(ctx)
+			return c.Next()
+		}
+
+		authLog.WarnContext(c.UserContext(), "Missing credentials", "path", c.Path(), "ip", c.IP())
+		metrics.AuthFailures.WithLabelValues("none", "missing").Inc()
+		return unauthorized(c, "Authentication required")
+	}
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the client identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the client identity, or nil for unauthenticated requests
+func FromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// ClientID returns the authenticated client ID, or "" for unauthenticated requests
+func ClientID(ctx context.Context) string {
+	if identity := FromContext(ctx); identity != nil {
+		return identity.ClientID
+	}
+	return ""
+}
+
+// unauthorized writes the Beckn NACK returned for failed authentication
+func unauthorized(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    "POLICY-ERROR",
+			"code":    "UNAUTHORIZED",
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serve initialises authentication and returns an app answering with the authenticated client ID
+func serve(t *testing.T, opts Options) *fiber.App {
+	t.Helper()
+	if err := Init(opts); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { Init(Options{}) })
+
+	app := fiber.New()
+	app.Get("/", Middleware(), func(c *fiber.Ctx) error {
+		return c.SendString(ClientID(c.UserContext()))
+	})
+	return app
+}
+
+// call sends a request with the given headers and returns the status and body
+func call(t *testing.T, app *fiber.App, headers map[string]string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAPIKey(t *testing.T) {
+	app := serve(t, Options{
+		Methods:      []string{MethodAPIKey},
+		APIKeyHeader: "X-Client-Key",
+		APIKeys:      map[string]string{"alpha": "key-a", "beta": "key-b"},
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		client  string
+	}{
+		{"first client", map[string]string{"X-Client-Key": "key-a"}, http.StatusOK, "alpha"},
+		{"second client", map[string]string{"X-Client-Key": "key-b"}, http.StatusOK, "beta"},
+		{"unknown key", map[string]string{"X-Client-Key": "key-c"}, http.StatusUnauthorized, ""},
+		{"prefix of a key", map[string]string{"X-Client-Key": "key-"}, http.StatusUnauthorized, ""},
+		{"key with a suffix", map[string]string{"X-Client-Key": "key-aa"}, http.StatusUnauthorized, ""},
+		{"default header is not used", map[string]string{"X-API-Key": "key-a"}, http.StatusUnauthorized, ""},
+		{"no credentials", nil, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, app, tt.headers)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", status, tt.status, body)
+			}
+			if status == http.StatusOK && body != tt.client {
+				t.Errorf("client = %q, want %q", body, tt.client)
+			}
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	app := serve(t, Options{})
+	if status, body := call(t, app, nil); status != http.StatusOK || body != "" {
+		t.Errorf("status = %d, client = %q, want an anonymous request", status, body)
+	}
+}
+
+func TestMethodsInOrder(t *testing.T) {
+	key := newRSAKey(t)
+	app := serve(t, Options{
+		Methods:  []string{MethodAPIKey, MethodJWT},
+		APIKeys:  map[string]string{"alpha": "key-a"},
+		JWKSPath: writeJWKS(t, rsaJWK("r1", &key.PublicKey)),
+	})
+	token := sign(t, "RS256", key, "r1", validClaims("beta"))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		client  string
+	}{
+		{"api key", map[string]string{"X-API-Key": "key-a"}, http.StatusOK, "alpha"},
+		{"jwt when no api key is sent", map[string]string{"Authorization": "Bearer " + token}, http.StatusOK, "beta"},
+		// An invalid credential is rejected rather than trying the next method
+		{"invalid api key with a valid jwt", map[string]string{"X-API-Key": "wrong", "Authorization": "Bearer " + token}, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, app, tt.headers)
+			if status != tt.status || (status == http.StatusOK && body != tt.client) {
+				t.Errorf("status = %d, body = %q, want %d %q", status, body, tt.status, tt.client)
+			}
+		})
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	t.Cleanup(func() { Init(Options{}) })
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"api key without keys", Options{Methods: []string{MethodAPIKey}}},
+		{"jwt without jwks", Options{Methods: []string{MethodJWT}}},
+		{"missing jwks file", Options{Methods: []string{MethodJWT}, JWKSPath: "missing.json"}},
+		{"unknown method", Options{Methods: []string{"basic"}}},
+	}
+	for _, tt := range tests {
+		if err := Init(tt.opts); err == nil {
+			t.Errorf("%s: Init accepted the options", tt.name)
+		}
+	}
+}
+
+func TestCredentialHeaders(t *testing.T) {
+	t.Cleanup(func() { Init(Options{}) })
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"disabled", Options{}, []string{"Authorization", "X-API-Key"}},
+		{"custom api key header", Options{
+			Methods:      []string{MethodAPIKey},
+			APIKeyHeader: "X-Client-Key",
+			APIKeys:      map[string]string{"alpha": "key-a"},
+		}, []string{"Authorization", "X-Client-Key"}},
+	}
+	for _, tt := range tests {
+		if err := Init(tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := CredentialHeaders(); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: CredentialHeaders = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTAuthenticator validates bearer tokens against keys from a local JWKS file
+type JWTAuthenticator struct {
+	keys        map[string]interface{}
+	parser      *jwt.Parser
+	clientClaim string
+}
+
+// jwk is a single JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWTAuthenticator loads the JWKS file and configures issuer and audience checks
+// The client ID is read from clientClaim (default "sub").
+func NewJWTAuthenticator(jwksPath, issuer, audience, clientClaim string) (*JWTAuthenticator, error) {
+	if jwksPath == "" {
+		return nil, fmt.Errorf("jwt authentication requires AUTH_JWKS_PATH")
+	}
+	keys, err := loadJWKS(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	if clientClaim == "" {
+		clientClaim = "sub"
+	}
+
+	authLog.Info("Loaded JWKS", "path", jwksPath, "keys", len(keys))
+	return &JWTAuthenticator{
+		keys:        keys,
+		parser:      jwt.NewParser(options...),
+		clientClaim: clientClaim,
+	}, nil
+}
+
+// Name returns the method name
+func (a *JWTAuthenticator) Name() string {
+	return MethodJWT
+}
+
+// Authenticate validates the bearer token in the Authorization header
+func (a *JWTAuthenticator) Authenticate(c *fiber.Ctx) (*Identity, error) {
+	header := c.Get(fiber.HeaderAuthorization)
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keyFor); err != nil {
+		return nil, err
+	}
+
+	clientID, _ := claims[a.clientClaim].(string)
+	if clientID == "" {
+		return nil, fmt.Errorf("token has no %q claim", a.clientClaim)
+	}
+	return &Identity{ClientID: clientID, Method: MethodJWT, Claims: claims}, nil
+}
+
+// keyFor selects the verification key by the token's kid header
+// Tokens without a kid are accepted only when the JWKS holds a single key.
+func (a *JWTAuthenticator) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if len(a.keys) == 1 {
+			for _, key := range a.keys {
+				return key, nil
+			}
+		}
+		return nil, errors.New("token has no kid header")
+	}
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// loadJWKS reads RSA and EC public keys from a JWKS file, keyed by kid
+func loadJWKS(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for i, key := range set.Keys {
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS key %d (kid %q): %w", i, key.Kid, err)
+		}
+		// A repeated kid would silently replace the earlier key
+		if _, ok := keys[key.Kid]; ok {
+			return nil, fmt.Errorf("JWKS key %d repeats kid %q", i, key.Kid)
+		}
+		keys[key.Kid] = publicKey
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s contains no keys", path)
+	}
+	// Tokens are matched to keys by kid, so only a single key may go without one
+	if _, ok := keys[""]; ok && len(keys) > 1 {
+		return nil, fmt.Errorf("JWKS %s holds several keys, so every key needs a kid", path)
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key material
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// encode base64url-encodes a big-endian integer
+func encode(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{Kty: "RSA", Kid: kid, N: encode(key.N), E: encode(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) jwk {
+	return jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: encode(key.X), Y: encode(key.Y)}
+}
+
+// writeJWKS writes the keys to a JWKS file and returns its path
+func writeJWKS(t *testing.T, keys ...jwk) string {
+	t.Helper()
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// validClaims returns claims accepted by the test authenticator for the client
+func validClaims(client string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": client,
+		"iss": "https://issuer.example",
+		"aud": "bap-adapter",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// sign returns a token signed with the given algorithm and key, with kid when not empty
+func sign(t *testing.T, alg string, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestLoadJWKS(t *testing.T) {
+	rsaKey, ecKey := newRSAKey(t), newECKey(t)
+
+	tests := []struct {
+		name    string
+		keys    []jwk
+		wantErr string
+	}{
+		{"rsa and ec keys", []jwk{rsaJWK("r1", &rsaKey.PublicKey), ecJWK("e1", &ecKey.PublicKey)}, ""},
+		{"single key without kid", []jwk{rsaJWK("", &rsaKey.PublicKey)}, ""},
+		{"duplicate kid", []jwk{rsaJWK("k", &rsaKey.PublicKey), ecJWK("k", &ecKey.PublicKey)}, `repeats kid "k"`},
+		{"key without kid among several", []jwk{rsaJWK("r1", &rsaKey.PublicKey), ecJWK("", &ecKey.PublicKey)}, "every key needs a kid"},
+		{"unsupported key type", []jwk{{Kty: "oct", Kid: "s"}}, `unsupported key type "oct"`},
+		{"unsupported curve", []jwk{{Kty: "EC", Kid: "e", Crv: "P-192"}}, `unsupported curve "P-192"`},
+		{"invalid modulus", []jwk{{Kty: "RSA", Kid: "r", N: "!", E: "AQAB"}}, "invalid modulus"},
+		{"no keys", nil, "contains no keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadJWKS(writeJWKS(t, tt.keys...))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("loadJWKS = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("loadJWKS = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWT(t *testing.T) {
+	rsaKey, ecKey, otherKey := newRSAKey(t), newECKey(t), newRSAKey(t)
+	app := serve(t, Options{
+		Methods:     []string{MethodJWT},
+		JWKSPath:    writeJWKS(t, rsaJWK("r1", &rsaKey.PublicKey), ecJWK("e1", &ecKey.PublicKey)),
+		JWTIssuer:   "https://issuer.example",
+		JWTAudience: "bap-adapter",
+	})
+
+	withClaim := func(key string, value interface{}) jwt.MapClaims {
+		claims := validClaims("alpha")
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	noneToken, err := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims("alpha")).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"rsa key", sign(t, "RS256", rsaKey, "r1", validClaims("alpha")), http.StatusOK},
+		{"rsa-pss", sign(t, "PS384", rsaKey, "r1", validClaims("alpha")), http.StatusOK},
+		{"ec key", sign(t, "ES256", ecKey, "e1", validClaims("alpha")), http.StatusOK},
+		{"signed by another key", sign(t, "RS256", otherKey, "r1", validClaims("alpha")), http.StatusUnauthorized},
+		{"kid of another key type", sign(t, "RS256", rsaKey, "e1", validClaims("alpha")), http.StatusUnauthorized},
+		{"unknown kid", sign(t, "RS256", rsaKey, "r2", validClaims("alpha")), http.StatusUnauthorized},
+		{"no kid with several keys", sign(t, "RS256", rsaKey, "", validClaims("alpha")), http.StatusUnauthorized},
+		{"hmac is not allowed", sign(t, "HS256", []byte("secret"), "r1", validClaims("alpha")), http.StatusUnauthorized},
+		{"none is not allowed", noneToken, http.StatusUnauthorized},
+		{"expired", sign(t, "RS256", rsaKey, "r1", withClaim("exp", time.Now().Add(-time.Minute).Unix())), http.StatusUnauthorized},
+		{"no expiry", sign(t, "RS256", rsaKey, "r1", withClaim("exp", nil)), http.StatusUnauthorized},
+		{"other issuer", sign(t, "RS256", rsaKey, "r1", withClaim("iss", "https://other.example")), http.StatusUnauthorized},
+		{"no issuer", sign(t, "RS256", rsaKey, "r1", withClaim("iss", nil)), http.StatusUnauthorized},
+		{"other audience", sign(t, "RS256", rsaKey, "r1", withClaim("aud", "other")), http.StatusUnauthorized},
+		{"audience in a list", sign(t, "RS256", rsaKey, "r1", withClaim("aud", []string{"other", "bap-adapter"})), http.StatusOK},
+		{"no client claim", sign(t, "RS256", rsaKey, "r1", withClaim("sub", nil)), http.StatusUnauthorized},
+		{"malformed token", "not.a.token", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, app, map[string]string{"Authorization": "Bearer " + tt.token})
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", status, tt.status, body)
+			}
+			if status == http.StatusOK && body != "alpha" {
+				t.Errorf("client = %q, want alpha", body)
+			}
+		})
+	}
+
+	if status, _ := call(t, app, map[string]string{"Authorization": "Basic YTpi"}); status != http.StatusUnauthorized {
+		t.Errorf("basic credentials: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestJWTSingleKey(t *testing.T) {
+	key := newECKey(t)
+	app := serve(t, Options{
+		Methods:        []string{MethodJWT},
+		JWKSPath:       writeJWKS(t, ecJWK("", &key.PublicKey)),
+		JWTClientClaim: "client_id",
+	})
+
+	tests := []struct {
+		name   string
+		kid    string
+		claims jwt.MapClaims
+		status int
+	}{
+		{"no kid", "", jwt.MapClaims{"client_id": "alpha", "exp": time.Now().Add(time.Hour).Unix()}, http.StatusOK},
+		{"unknown kid", "e1", jwt.MapClaims{"client_id": "alpha", "exp": time.Now().Add(time.Hour).Unix()}, http.StatusUnauthorized},
+		{"client in sub only", "", jwt.MapClaims{"sub": "alpha", "exp": time.Now().Add(time.Hour).Unix()}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := call(t, app, map[string]string{"Authorization": "Bearer " + sign(t, "ES256", key, tt.kid, tt.claims)})
+			if status != tt.status || (status == http.StatusOK && body != "alpha") {
+				t.Errorf("status = %d, body = %q, want %d", status, body, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"BAP_Sandbox/internal/storage"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache results reported in the X-Cache header and metrics
+const (
+	Hit    = "HIT"
+	Miss   = "MISS"
+	Bypass = "BYPASS"
+)
+
+// HeaderCache reports whether a response was served from the cache
+const HeaderCache = "X-Cache"
+
+// timestampFormat is the Beckn context timestamp format
+const timestampFormat = "2006-01-02T15:04:05.000Z"
+
+// volatileContextFields differ between otherwise identical requests and are left out of the key
+var volatileContextFields = []string{"transaction_id", "message_id", "timestamp"}
+
+// Options configures the response cache
+type Options struct {
+	Enabled bool
+	// Routes lists the cached routes and their TTLs; other routes are never cached
+	Routes map[string]time.Duration
+	// BypassHeader makes a request skip the cached response when present
+	BypassHeader string
+}
+
+var options Options
+
+// Init configures the response cache
+func Init(opts Options) {
+	options = opts
+}
+
+// TTLFor returns how long responses for route are cached, or 0 if they are not
+func TTLFor(route string) time.Duration {
+	if !options.Enabled {
+		return 0
+	}
+	return options.Routes[route]
+}
+
+// Directive is how a request uses the cache
+type Directive struct {
+	// Lookup serves a cached response if one exists
+	Lookup bool
+	// Store caches the response to the request
+	Store bool
+}
+
+// DirectiveFor reads the bypass header and Cache-Control of a request
+// The bypass header and no-cache skip the lookup but refresh the cached response;
+// no-store neither looks up nor stores.
+func DirectiveFor(header func(key string) string) Directive {
+	directive := Directive{Lookup: true, Store: true}
+	if options.BypassHeader != "" && header(options.BypassHeader) != "" {
+		directive.Lookup = false
+	}
+	for _, value := range strings.Split(strings.ToLower(header("Cache-Control")), ",") {
+		switch strings.TrimSpace(value) {
+		case "no-cache":
+			directive.Lookup = false
+		case "no-store":
+			directive.Lookup = false
+			directive.Store = false
+		}
+	}
+	return directive
+}
+
+// Key returns the cache key for a request body sent to route for a tenant
+// The body is canonicalised, with object keys sorted and the context's transaction ID,
+// message ID and timestamp removed, so repeats of the same search share a key.
+func Key(tenant, route string, body []byte) (string, error) {
+	var request map[string]interface{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return "", err
+	}
+	if context, ok := request["context"].(map[string]interface{}); ok {
+		for _, field := range volatileContextFields {
+			delete(context, field)
+		}
+	}
+	canonical, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return fmt.Sprintf("RespCache#%s#%s#%s", tenant, route, hex.EncodeToString(sum[:])), nil
+}
+
+// Entry is a cached ONIX response
+type Entry struct {
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body"`
+	StoredAt time.Time         `json:"stored_at"`
+	TTL      time.Duration     `json:"ttl"`
+}
+
+// Age returns how long ago the entry was stored
+func (e *Entry) Age() time.Duration {
+	return time.Since(e.StoredAt)
+}
+
+// Remaining returns how long the entry stays fresh
+func (e *Entry) Remaining() time.Duration {
+	return max(e.TTL-e.Age(), 0)
+}
+
+// Get returns the cached entry for key, or nil if there is none
+func Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := storage.RedisClient.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// cachedHeaders are the ONIX response headers stored and replayed with a cached response
+// Everything else belongs to the original exchange and must not reach the tenant's other
+// clients, notably Set-Cookie, trace and request IDs, rate-limit and transfer headers.
+var cachedHeaders = []string{"Content-Type"}
+
+// Header returns the entry's replayable headers
+// Filtering again keeps headers stored by older instances from being replayed.
+func (e *Entry) Header() http.Header {
+	header := make(http.Header, len(cachedHeaders))
+	for _, key := range cachedHeaders {
+		if value := e.Headers[key]; value != "" {
+			header.Set(key, value)
+		}
+	}
+	return header
+}
+
+// Put caches a response for ttl and returns the stored entry
+func Put(ctx context.Context, key string, status int, headers http.Header, body []byte, ttl time.Duration) (*Entry, error) {
+	entry := &Entry{
+		Status:   status,
+		Headers:  map[string]string{},
+		Body:     body,
+		StoredAt: time.Now().UTC(),
+		TTL:      ttl,
+	}
+	for _, key := range cachedHeaders {
+		if value := headers.Get(key); value != "" {
+			entry.Headers[key] = value
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return entry, storage.RedisClient.Set(ctx, key, data, ttl).Err()
+}
+
+// Rewrite returns a cached body with the context IDs of the request it now answers
+// and the time it is served as context.timestamp. Bodies without a context object are
+// returned unchanged.
+func Rewrite(body []byte, transactionID, messageID string, now time.Time) []byte {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	var context map[string]json.RawMessage
+	if err := json.Unmarshal(response["context"], &context); err != nil || context == nil {
+		return body
+	}
+	context["transaction_id"], _ = json.Marshal(transactionID)
+	context["message_id"], _ = json.Marshal(messageID)
+	context["timestamp"], _ = json.Marshal(now.UTC().Format(timestampFormat))
+
+	var err error
+	if response["context"], err = json.Marshal(context); err != nil {
+		return body
+	}
+	rewritten, err := json.Marshal(response)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"BAP_Sandbox/internal/storage/storagetest"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	base := `{"context":{"action":"search","transaction_id":"t1","message_id":"m1","timestamp":"2026-01-01T00:00:00.000Z","bap_id":"bap"},"message":{"intent":{"item":{"descriptor":{"name":"laptop"}}}}}`
+	baseKey, err := Key("", "search", []byte(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(baseKey, "RespCache##search#") {
+		t.Errorf("Key = %q, want the RespCache#{tenant}#{route}# prefix", baseKey)
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		route  string
+		body   string
+		same   bool
+	}{
+		{"other IDs and timestamp", "", "search",
+			`{"context":{"action":"search","transaction_id":"t2","message_id":"m2","timestamp":"2026-02-02T00:00:00.000Z","bap_id":"bap"},"message":{"intent":{"item":{"descriptor":{"name":"laptop"}}}}}`, true},
+		{"key order and whitespace", "", "search",
+			`{ "message": {"intent": {"item": {"descriptor": {"name": "laptop"}}}}, "context": {"bap_id": "bap", "action": "search"} }`, true},
+		{"other intent", "", "search",
+			`{"context":{"action":"search","bap_id":"bap"},"message":{"intent":{"item":{"descriptor":{"name":"phone"}}}}}`, false},
+		{"other context field", "", "search",
+			`{"context":{"action":"search","bap_id":"other"},"message":{"intent":{"item":{"descriptor":{"name":"laptop"}}}}}`, false},
+		{"message IDs are kept outside the context", "", "search",
+			`{"context":{"action":"search","bap_id":"bap"},"message":{"intent":{"item":{"descriptor":{"name":"laptop"}}},"message_id":"m1"}}`, false},
+		{"other tenant", "acme", "search", base, false},
+		{"other route", "", "discover", base, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := Key(tt.tenant, tt.route, []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (key == baseKey) != tt.same {
+				t.Errorf("Key = %q, base key %q, want same %v", key, baseKey, tt.same)
+			}
+		})
+	}
+
+	if _, err := Key("", "search", []byte(`{"context":`)); err == nil {
+		t.Error("Key accepted invalid JSON")
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	now := time.Date(2026, 3, 4, 5, 6, 7, 890_000_000, time.FixedZone("IST", 19800))
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "context is refreshed",
+			body: `{"context":{"action":"on_search","transaction_id":"t1","message_id":"m1","timestamp":"2026-01-01T00:00:00.000Z"},"message":{"catalog":{}}}`,
+			want: `{"context":{"action":"on_search","message_id":"m2","timestamp":"2026-03-03T23:36:07.890Z","transaction_id":"t2"},"message":{"catalog":{}}}`,
+		},
+		{
+			name: "missing fields are added",
+			body: `{"context":{"action":"on_search"}}`,
+			want: `{"context":{"action":"on_search","message_id":"m2","timestamp":"2026-03-03T23:36:07.890Z","transaction_id":"t2"}}`,
+		},
+		{name: "no context", body: `{"message":{"ack":{"status":"ACK"}}}`, want: `{"message":{"ack":{"status":"ACK"}}}`},
+		{name: "null context", body: `{"context":null}`, want: `{"context":null}`},
+		{name: "not JSON", body: `<html>`, want: `<html>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Rewrite([]byte(tt.body), "t2", "m2", now)); got != tt.want {
+				t.Errorf("Rewrite = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTTLFor(t *testing.T) {
+	routes := map[string]time.Duration{"search": time.Minute}
+
+	tests := []struct {
+		name    string
+		options Options
+		route   string
+		want    time.Duration
+	}{
+		{"listed route", Options{Enabled: true, Routes: routes}, "search", time.Minute},
+		{"unlisted route is not cached", Options{Enabled: true, Routes: routes}, "discover", 0},
+		{"disabled cache", Options{Routes: routes}, "search", 0},
+	}
+	for _, tt := range tests {
+		Init(tt.options)
+		if got := TTLFor(tt.route); got != tt.want {
+			t.Errorf("%s: TTLFor(%q) = %s, want %s", tt.name, tt.route, got, tt.want)
+		}
+	}
+	Init(Options{})
+}
+
+func TestDirectiveFor(t *testing.T) {
+	Init(Options{Enabled: true, BypassHeader: "X-Cache-Bypass"})
+	t.Cleanup(func() { Init(Options{}) })
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    Directive
+	}{
+		{"no headers", nil, Directive{Lookup: true, Store: true}},
+		{"bypass header", map[string]string{"X-Cache-Bypass": "1"}, Directive{Lookup: false, Store: true}},
+		{"no-cache", map[string]string{"Cache-Control": "No-Cache"}, Directive{Lookup: false, Store: true}},
+		{"no-store among directives", map[string]string{"Cache-Control": "max-age=0, no-store"}, Directive{Lookup: false, Store: false}},
+		{"max-age alone", map[string]string{"Cache-Control": "max-age=60"}, Directive{Lookup: true, Store: true}},
+	}
+	for _, tt := range tests {
+		header := func(key string) string { return tt.headers[key] }
+		if got := DirectiveFor(header); got != tt.want {
+			t.Errorf("%s: DirectiveFor = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPutAndGet(t *testing.T) {
+	server := storagetest.UseRedis(t)
+	ctx := context.Background()
+
+	if entry, err := Get(ctx, "RespCache##search#missing"); entry != nil || err != nil {
+		t.Fatalf("Get for a missing key = %v, %v, want nil", entry, err)
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Content-Length", "17")
+	headers.Set("X-Onix-Trace", "abc")
+	headers.Set("Set-Cookie", "session=1")
+	headers.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	body := []byte(`{"context":{}}`)
+	if _, err := Put(ctx, "RespCache##search#k", http.StatusOK, headers, body, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("RespCache##search#k"); ttl != time.Minute {
+		t.Errorf("TTL = %s, want %s", ttl, time.Minute)
+	}
+
+	entry, err := Get(ctx, "RespCache##search#k")
+	if err != nil || entry == nil {
+		t.Fatalf("Get = %v, %v", entry, err)
+	}
+	wantHeaders := map[string]string{"Content-Type": "application/json"}
+	if entry.Status != http.StatusOK || string(entry.Body) != string(body) || len(entry.Headers) != len(wantHeaders) {
+		t.Fatalf("entry = %+v", entry)
+	}
+	for key, value := range wantHeaders {
+		if entry.Headers[key] != value {
+			t.Errorf("header %s = %q, want %q", key, entry.Headers[key], value)
+		}
+	}
+	// Entries stored by older instances may hold other headers; they are not replayed
+	entry.Headers["Set-Cookie"] = "session=1"
+	if header := entry.Header(); len(header) != 1 || header.Get("Content-Type") != "application/json" {
+		t.Errorf("Header = %v, want only Content-Type", header)
+	}
+	if remaining := entry.Remaining(); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("Remaining = %s, want within the TTL", remaining)
+	}
+
+	expired := Entry{StoredAt: time.Now().Add(-2 * time.Minute), TTL: time.Minute, Body: json.RawMessage(`{}`)}
+	if remaining := expired.Remaining(); remaining != 0 {
+		t.Errorf("Remaining for an expired entry = %s, want 0", remaining)
+	}
+}
@@ -0,0 +1,256 @@
+package capture
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var captureLog = logging.For("capture")
+
+// Record kinds, in the order they occur for a request
+const (
+	KindClientRequest  = "client_request"
+	KindOnixRequest    = "onix_request"
+	KindOnixResponse   = "onix_response"
+	KindCallback       = "callback"
+	KindClientResponse = "client_response"
+)
+
+// queueSize bounds the records waiting to be written; records are dropped when it is full
+const queueSize = 1024
+
+// Record is one captured message, correlated by transaction and message ID
+type Record struct {
+	Time          time.Time `json:"time"`
+	Kind          string    `json:"kind"`
+	TransactionID string    `json:"transaction_id"`
+	MessageID     string    `json:"message_id"`
+	Route         string    `json:"route"`
+	Tenant        string    `json:"tenant,omitempty"`
+	Mode          string    `json:"mode,omitempty"`
+	// URL is the request path on the adapter, or the ONIX URL for ONIX messages
+	URL        string            `json:"url,omitempty"`
+	Status     int               `json:"status,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	DurationMs float64           `json:"duration_ms,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+}
+
+// Options configures traffic capture
+type Options struct {
+	Enabled bool
+	// Dir receives one capture-{time}-{host}.jsonl file per process
+	Dir string
+	// Redact masks the logging redaction paths in captured bodies
+	Redact bool
+	// SensitiveHeaders are never captured, in addition to Authorization and Cookie
+	SensitiveHeaders []string
+}
+
+// recorder writes records to the capture file in the background
+type recorder struct {
+	path      string
+	redact    bool
+	sensitive map[string]bool
+	queue     chan Record
+	done      chan struct{}
+	mu        sync.RWMutex
+	closed    bool
+}
+
+var active *recorder
+
+// Init opens the capture file and starts the writer
+// Capture stays disabled unless Options.Enabled is set.
+func Init(opts Options) error {
+	if !opts.Enabled {
+		return nil
+	}
+	if err := os.MkdirAll(opts.Dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	host, _ := os.Hostname()
+	path := filepath.Join(opts.Dir, fmt.Sprintf("capture-%s-%s.jsonl", time.Now().UTC().Format("20060102-150405"), host))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+
+	r := &recorder{
+		path:   path,
+		redact: opts.Redact,
+		sensitive: map[string]bool{
+			"authorization":       true,
+			"proxy-authorization": true,
+			"cookie":              true,
+		},
+		queue: make(chan Record, queueSize),
+		done:  make(chan struct{}),
+	}
+	for _, header := range opts.SensitiveHeaders {
+		r.sensitive[strings.ToLower(header)] = true
+	}
+	go r.run(file)
+
+	active = r
+	captureLog.Warn("Traffic capture enabled, requests and callbacks are written to disk", "path", path, "redact", opts.Redact)
+	return nil
+}
+
+// Enabled reports whether traffic is being captured
+func Enabled() bool {
+	return active != nil
+}
+
+type recordKey struct{}
+
+// WithRequest returns a context carrying the fields shared by every record of a request
+func WithRequest(ctx context.Context, rec Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, rec)
+}
+
+// Write queues a record with its body; it never blocks the request
+// Correlation fields left empty are filled in from the request in ctx.
+func Write(ctx context.Context, rec Record, body []byte) {
+	r := active
+	if r == nil {
+		return
+	}
+	if base, ok := ctx.Value(recordKey{}).(Record); ok {
+		rec.TransactionID = firstNonEmpty(rec.TransactionID, base.TransactionID)
+		rec.MessageID = firstNonEmpty(rec.MessageID, base.MessageID)
+		rec.Route = firstNonEmpty(rec.Route, base.Route)
+		rec.Tenant = firstNonEmpty(rec.Tenant, base.Tenant)
+		rec.Mode = firstNonEmpty(rec.Mode, base.Mode)
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+	rec.Body = r.encodeBody(body)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- rec:
+		metrics.CaptureRecords.WithLabelValues(rec.Kind).Inc()
+	default:
+		metrics.CaptureDropped.Inc()
+	}
+}
+
+// Headers returns the headers to capture, without credentials
+func Headers(headers http.Header) map[string]string {
+	r := active
+	if r == nil || len(headers) == 0 {
+		return nil
+	}
+	captured := make(map[string]string, len(headers))
+	for key, values := range headers {
+		if r.sensitive[strings.ToLower(key)] || len(values) == 0 {
+			continue
+		}
+		captured[key] = values[0]
+	}
+	return captured
+}
+
+// Close flushes the queued records and closes the capture file
+func Close() {
+	r := active
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+	<-r.done
+}
+
+// Read decodes the records in a capture file
+// A truncated last record, left by a running or crashed process, is ignored.
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+	decoder := json.NewDecoder(r)
+	for {
+		var rec Record
+		err := decoder.Decode(&rec)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func firstNonEmpty(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+// encodeBody returns the body as JSON, redacted when configured
+// Bodies that are not JSON are stored as a JSON string.
+func (r *recorder) encodeBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if !json.Valid(body) {
+		encoded, _ := json.Marshal(string(body))
+		return encoded
+	}
+	if r.redact {
+		return logging.RedactPayload(body)
+	}
+	return append(json.RawMessage(nil), body...)
+}
+
+// run writes queued records as JSON lines until the queue is closed
+func (r *recorder) run(file *os.File) {
+	defer close(r.done)
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+
+	for {
+		select {
+		case rec, ok := <-r.queue:
+			if !ok {
+				if err := writer.Flush(); err != nil {
+					captureLog.Error("Failed to flush capture file", "path", r.path, "error", err)
+				}
+				file.Close()
+				return
+			}
+			if err := encoder.Encode(rec); err != nil {
+				captureLog.Error("Failed to write capture record", "path", r.path, "error", err)
+			}
+		case <-flush.C:
+			if err := writer.Flush(); err != nil {
+				captureLog.Error("Failed to flush capture file", "path", r.path, "error", err)
+			}
+		}
+	}
+}
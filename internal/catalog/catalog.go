@@ -0,0 +1,451 @@
+package catalog
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/storage"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var catalogLog = logging.For("catalog")
+
+// Fields of a catalog hash besides its items, which are keyed by "{provider_id}|{item_id}"
+const (
+	// messageField holds the message ID of the search a catalog was built from
+	messageField = "#message_id"
+	// countField holds the number of items in the catalog
+	countField = "#count"
+	// indexPrefix starts the index fields, each holding a JSON array of item fields
+	indexPrefix = "#index:"
+)
+
+// Indexes kept next to the items, so queries by provider, item or location read only
+// the matching items instead of the whole catalog
+const (
+	indexProvider = indexPrefix + "provider:"
+	indexItem     = indexPrefix + "item:"
+	// indexLocation is keyed by location ID and by city, lower-cased
+	indexLocation = indexPrefix + "location:"
+)
+
+// Options configures the catalog cache
+type Options struct {
+	Enabled bool
+	// TTL is how long a transaction's catalog is kept after its last search response
+	TTL time.Duration
+	// SelectValidation handles select requests for items not in the catalog: off, warn or reject
+	SelectValidation string
+}
+
+var options Options
+
+// Init configures the catalog cache
+func Init(opts Options) {
+	options = opts
+}
+
+// Enabled reports whether search responses are cached
+func Enabled() bool {
+	return options.Enabled
+}
+
+// SelectValidation returns how select requests for unknown items are handled
+func SelectValidation() string {
+	return options.SelectValidation
+}
+
+// makeCatalogKey creates the Redis key for a transaction's catalog
+// Format: Catalog#{tenant}#{transaction_id}, or Catalog#{transaction_id} for the default tenant
+func makeCatalogKey(tenant, transactionID string) string {
+	if tenant != "" {
+		return fmt.Sprintf("Catalog#%s#%s", tenant, transactionID)
+	}
+	return fmt.Sprintf("Catalog#%s", transactionID)
+}
+
+// storeItems replaces the catalog of an earlier search, or merges into it for the same search
+// The indexes live in the catalog hash itself, so they change atomically with the items
+// and stay in the catalog's cluster slot.
+// KEYS[1] = catalog hash
+// ARGV[1] = message ID of the search, ARGV[2] = TTL in ms, ARGV[3] = item count n,
+// ARGV[4 .. 3+2n] = field, item pairs, then index field, JSON array of item fields pairs
+// Returns the number of items in the catalog.
+var storeItems = redis.NewScript(`
+if redis.call('HGET', KEYS[1], '` + messageField + `') ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+end
+redis.call('HSET', KEYS[1], '` + messageField + `', ARGV[1])
+local last = 3 + 2 * tonumber(ARGV[3])
+local added = 0
+for i = 4, last, 2 do
+	added = added + redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+for i = last + 1, #ARGV, 2 do
+	local members = {}
+	local seen = {}
+	local current = redis.call('HGET', KEYS[1], ARGV[i])
+	if current then
+		members = cjson.decode(current)
+		for _, member in ipairs(members) do
+			seen[member] = true
+		end
+	end
+	for _, member in ipairs(cjson.decode(ARGV[i + 1])) do
+		if not seen[member] then
+			seen[member] = true
+			table.insert(members, member)
+		end
+	end
+	redis.call('HSET', KEYS[1], ARGV[i], cjson.encode(members))
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return redis.call('HINCRBY', KEYS[1], '` + countField + `', added)
+`)
+
+// indexFields returns the index fields listing an item
+func (i Item) indexFields() []string {
+	fields := []string{indexProvider + i.ProviderID, indexItem + i.ID}
+	for _, loc := range i.Locations {
+		for _, name := range []string{loc.ID, loc.City} {
+			if name != "" {
+				fields = append(fields, indexLocation+strings.ToLower(name))
+			}
+		}
+	}
+	return fields
+}
+
+// Store adds the items of a search response to the transaction's catalog
+// A response to a new search replaces the catalog; further responses to the same search,
+// such as on_search callbacks from several BPPs, are merged into it. It returns the number
+// of items in the catalog.
+func Store(ctx context.Context, tenant, transactionID, messageID string, body []byte) (int, error) {
+	items := Extract(body)
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, 0, 3+2*len(items))
+	args = append(args, messageID, options.TTL.Milliseconds(), len(items))
+	indexes := map[string][]string{}
+	var indexOrder []string
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, item.key(), data)
+		for _, field := range item.indexFields() {
+			if _, ok := indexes[field]; !ok {
+				indexOrder = append(indexOrder, field)
+			}
+			if !slices.Contains(indexes[field], item.key()) {
+				indexes[field] = append(indexes[field], item.key())
+			}
+		}
+	}
+	for _, field := range indexOrder {
+		members, err := json.Marshal(indexes[field])
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, field, members)
+	}
+	count, err := storeItems.Run(ctx, storage.RedisClient, []string{makeCatalogKey(tenant, transactionID)}, args...).Int()
+	if err != nil {
+		return 0, err
+	}
+	catalogLog.DebugContext(ctx, "Catalog updated", "items", len(items), "catalog_items", count)
+	return count, nil
+}
+
+// Load returns the tenant's transaction catalog in response order, or nil if none is cached
+func Load(ctx context.Context, tenant, transactionID string) ([]Item, error) {
+	fields, err := storage.RedisClient.HGetAll(ctx, makeCatalogKey(tenant, transactionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(fields))
+	for field, value := range fields {
+		if !strings.HasPrefix(field, "#") {
+			values = append(values, value)
+		}
+	}
+	items := decodeItems(values)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return items, nil
+}
+
+// Find returns the catalog items that may match the query, in response order
+// Queries by provider, item or location read only the items listed in those indexes;
+// other queries read the whole catalog. The caller still applies the query to the
+// result. found is false when no catalog is cached for the transaction.
+func Find(ctx context.Context, tenant, transactionID string, q Query) (items []Item, found bool, err error) {
+	var indexFields []string
+	if q.ProviderID != "" {
+		indexFields = append(indexFields, indexProvider+q.ProviderID)
+	}
+	if q.ItemID != "" {
+		indexFields = append(indexFields, indexItem+q.ItemID)
+	}
+	if q.Location != "" {
+		indexFields = append(indexFields, indexLocation+strings.ToLower(q.Location))
+	}
+	if len(indexFields) == 0 {
+		items, err = Load(ctx, tenant, transactionID)
+		return items, items != nil, err
+	}
+
+	key := makeCatalogKey(tenant, transactionID)
+	values, err := storage.RedisClient.HMGet(ctx, key, append([]string{messageField}, indexFields...)...).Result()
+	if err != nil || values[0] == nil {
+		return nil, false, err
+	}
+
+	// Intersect the indexes, keeping the order of the first
+	var members []string
+	for i, value := range values[1:] {
+		var listed []string
+		if text, ok := value.(string); ok {
+			if err := json.Unmarshal([]byte(text), &listed); err != nil {
+				return nil, true, fmt.Errorf("invalid catalog index %s: %w", indexFields[i], err)
+			}
+		}
+		if i == 0 {
+			members = listed
+			continue
+		}
+		members = slices.DeleteFunc(members, func(member string) bool { return !slices.Contains(listed, member) })
+	}
+	if len(members) == 0 {
+		return []Item{}, true, nil
+	}
+
+	if values, err = storage.RedisClient.HMGet(ctx, key, members...).Result(); err != nil {
+		return nil, true, err
+	}
+	return decodeItems(values), true, nil
+}
+
+// decodeItems decodes stored items, skipping missing or invalid ones, and sorts them in response order
+func decodeItems(values []interface{}) []Item {
+	items := make([]Item, 0, len(values))
+	for _, value := range values {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var item Item
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	slices.SortFunc(items, func(a, b Item) int {
+		if a.Position != b.Position {
+			return a.Position - b.Position
+		}
+		return strings.Compare(a.key(), b.key())
+	})
+	return items
+}
+
+// Missing returns the items of a select request that are not in the transaction's catalog
+// Nothing is reported when no catalog is cached for the transaction. Items selected
+// without a provider match any provider; a bppID is checked against the item's BPP.
+func Missing(ctx context.Context, tenant, transactionID, bppID string, body []byte) ([]Ref, error) {
+	refs := Selected(body)
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	items, err := Load(ctx, tenant, transactionID)
+	if err != nil || items == nil {
+		return nil, err
+	}
+
+	var missing []Ref
+	for _, ref := range refs {
+		found := slices.ContainsFunc(items, func(item Item) bool {
+			return item.ID == ref.ItemID &&
+				(ref.ProviderID == "" || item.ProviderID == ref.ProviderID) &&
+				(bppID == "" || item.BppID == "" || item.BppID == bppID)
+		})
+		if !found {
+			missing = append(missing, ref)
+		}
+	}
+	return missing, nil
+}
+
+// Query filters, sorts and pages a catalog
+type Query struct {
+	ProviderID string
+	ItemID     string
+	// Location matches a location ID or city name
+	Location string
+	// Text matches the item or provider name, ignoring case
+	Text     string
+	MinPrice *float64
+	MaxPrice *float64
+	// Near and RadiusKm keep items available within RadiusKm of a "lat,lon" point
+	Near     string
+	RadiusKm float64
+	// Sort is name, price or distance, prefixed with - for descending order;
+	// response order is kept when empty
+	Sort     string
+	Page     int
+	PageSize int
+}
+
+// Sort keys accepted by Query.Sort
+const (
+	SortName     = "name"
+	SortPrice    = "price"
+	SortDistance = "distance"
+)
+
+// Validate checks the query, returning a message suitable for the client
+func (q Query) Validate() error {
+	if q.Near != "" {
+		if _, _, ok := parseGPS(q.Near); !ok {
+			return fmt.Errorf("near must be \"lat,lon\"")
+		}
+	}
+	if q.RadiusKm > 0 && q.Near == "" {
+		return fmt.Errorf("radius_km requires near")
+	}
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case "", SortName, SortPrice:
+	case SortDistance:
+		if q.Near == "" {
+			return fmt.Errorf("sorting by distance requires near")
+		}
+	default:
+		return fmt.Errorf("sort must be %s, %s or %s, optionally prefixed with -", SortName, SortPrice, SortDistance)
+	}
+	if q.Page < 1 || q.PageSize < 1 {
+		return fmt.Errorf("page and page_size must be positive")
+	}
+	return nil
+}
+
+// Apply returns the requested page of matching items and the number of matches
+func (q Query) Apply(items []Item) (page []Item, total int) {
+	nearLat, nearLon, _ := parseGPS(q.Near)
+	distance := func(item Item) float64 {
+		closest := math.Inf(1)
+		for _, loc := range item.Locations {
+			if lat, lon, ok := parseGPS(loc.GPS); ok {
+				closest = math.Min(closest, haversineKm(nearLat, nearLon, lat, lon))
+			}
+		}
+		return closest
+	}
+
+	text := strings.ToLower(q.Text)
+	matches := make([]Item, 0, len(items))
+	for _, item := range items {
+		switch {
+		case q.ProviderID != "" && item.ProviderID != q.ProviderID,
+			q.ItemID != "" && item.ID != q.ItemID,
+			q.Location != "" && !item.availableAt(q.Location),
+			text != "" && !strings.Contains(strings.ToLower(item.Name+" "+item.ProviderName), text),
+			q.MinPrice != nil && (item.Price == nil || *item.Price < *q.MinPrice),
+			q.MaxPrice != nil && (item.Price == nil || *item.Price > *q.MaxPrice),
+			q.Near != "" && q.RadiusKm > 0 && distance(item) > q.RadiusKm:
+			continue
+		}
+		matches = append(matches, item)
+	}
+
+	descending := strings.HasPrefix(q.Sort, "-")
+	var compare func(a, b Item) int
+	switch strings.TrimPrefix(q.Sort, "-") {
+	case SortName:
+		compare = func(a, b Item) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) }
+	case SortPrice:
+		// Items without a price sort last in either direction
+		compare = func(a, b Item) int {
+			switch {
+			case a.Price == nil && b.Price == nil:
+				return 0
+			case a.Price == nil:
+				return boolSign(!descending)
+			case b.Price == nil:
+				return boolSign(descending)
+			}
+			return cmpFloat(*a.Price, *b.Price)
+		}
+	case SortDistance:
+		compare = func(a, b Item) int { return cmpFloat(distance(a), distance(b)) }
+	}
+	if compare != nil {
+		slices.SortStableFunc(matches, func(a, b Item) int {
+			if descending {
+				return compare(b, a)
+			}
+			return compare(a, b)
+		})
+	}
+
+	total = len(matches)
+	start := (q.Page - 1) * q.PageSize
+	if start >= total {
+		return []Item{}, total
+	}
+	return matches[start:min(start+q.PageSize, total)], total
+}
+
+// availableAt reports whether the item is available at a location ID or city
+func (i Item) availableAt(location string) bool {
+	return slices.ContainsFunc(i.Locations, func(loc Location) bool {
+		return loc.ID == location || strings.EqualFold(loc.City, location)
+	})
+}
+
+// parseGPS parses a "lat,lon" string
+func parseGPS(gps string) (lat, lon float64, ok bool) {
+	latText, lonText, found := strings.Cut(gps, ",")
+	if !found {
+		return 0, 0, false
+	}
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	return lat, lon, latErr == nil && lonErr == nil
+}
+
+// haversineKm returns the great-circle distance between two points in kilometres
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLon := toRad(lat2-lat1), toRad(lon2-lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolSign(positive bool) int {
+	if positive {
+		return 1
+	}
+	return -1
+}
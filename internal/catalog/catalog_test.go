@@ -0,0 +1,212 @@
+package catalog
+
+import (
+	"BAP_Sandbox/internal/storage/storagetest"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// useTestRedis points the storage client at an in-process Redis and enables the catalog for the test
+func useTestRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := storagetest.UseRedis(t)
+	Init(Options{Enabled: true, TTL: time.Minute})
+	return server
+}
+
+// ids returns the item IDs in order
+func ids(items []Item) []string {
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, item.ID)
+	}
+	return result
+}
+
+func TestStoreAndLoad(t *testing.T) {
+	server := useTestRedis(t)
+	ctx := context.Background()
+
+	count, err := Store(ctx, "", "txn-1", "m1", []byte(v1Search))
+	if err != nil || count != 3 {
+		t.Fatalf("Store = %d, %v, want 3 items", count, err)
+	}
+	if ttl := server.TTL("Catalog#txn-1"); ttl != time.Minute {
+		t.Errorf("TTL = %s, want %s", ttl, time.Minute)
+	}
+
+	// Another response to the same search is merged
+	if count, err = Store(ctx, "", "txn-1", "m1", []byte(v2Discover)); err != nil || count != 6 {
+		t.Fatalf("Store for the same search = %d, %v, want 6 items", count, err)
+	}
+	items, err := Load(ctx, "", "txn-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(items), []string{"ev-1", "i1", "ev-2", "i2", "ev-4", "i3"}; !slices.Equal(got, want) {
+		t.Errorf("Load = %v, want %v", got, want)
+	}
+
+	// A new search replaces the catalog
+	if count, err = Store(ctx, "", "txn-1", "m2", []byte(v2Discover)); err != nil || count != 3 {
+		t.Fatalf("Store for a new search = %d, %v, want 3 items", count, err)
+	}
+
+	// Tenants keep separate catalogs
+	if items, err = Load(ctx, "acme", "txn-1"); err != nil || items != nil {
+		t.Errorf("Load for another tenant = %v, %v, want none", ids(items), err)
+	}
+	if _, err := Store(ctx, "acme", "txn-1", "m1", []byte(v1Search)); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists("Catalog#acme#txn-1") {
+		t.Error("tenant catalog is not namespaced")
+	}
+}
+
+func TestFind(t *testing.T) {
+	useTestRedis(t)
+	ctx := context.Background()
+	if _, err := Store(ctx, "", "txn-1", "m1", []byte(v1Search)); err != nil {
+		t.Fatal(err)
+	}
+	// Indexes are merged with the first response's
+	if _, err := Store(ctx, "", "txn-1", "m1", []byte(v2Discover)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		transactionID string
+		query         Query
+		want          []string
+		found         bool
+	}{
+		{"whole catalog", "txn-1", Query{}, []string{"ev-1", "i1", "ev-2", "i2", "ev-4", "i3"}, true},
+		{"provider", "txn-1", Query{ProviderID: "p1"}, []string{"i1", "i2", "i3"}, true},
+		{"provider of the merged response", "txn-1", Query{ProviderID: "cpo-2"}, []string{"ev-2"}, true},
+		{"item", "txn-1", Query{ItemID: "i2"}, []string{"i2"}, true},
+		{"location ID", "txn-1", Query{Location: "l2"}, []string{"i2", "i3"}, true},
+		{"city from both responses", "txn-1", Query{Location: "BENGALURU"}, []string{"ev-1", "i1", "i3"}, true},
+		{"provider and location", "txn-1", Query{ProviderID: "p1", Location: "l1"}, []string{"i1", "i3"}, true},
+		{"no match", "txn-1", Query{ProviderID: "p9"}, []string{}, true},
+		{"no cached catalog", "txn-2", Query{ProviderID: "p1"}, []string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, found, err := Find(ctx, "", tt.transactionID, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(items); found != tt.found || !slices.Equal(got, tt.want) {
+				t.Errorf("Find = %v (found %v), want %v (found %v)", got, found, tt.want, tt.found)
+			}
+		})
+	}
+
+	// A new search replaces the indexes with the items
+	if _, err := Store(ctx, "", "txn-1", "m2", []byte(v2Discover)); err != nil {
+		t.Fatal(err)
+	}
+	if items, found, err := Find(ctx, "", "txn-1", Query{ProviderID: "p1"}); err != nil || !found || len(items) != 0 {
+		t.Errorf("Find after a new search = %v, %v, %v, want no items", ids(items), found, err)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	useTestRedis(t)
+	ctx := context.Background()
+	if _, err := Store(ctx, "", "txn-1", "m1", []byte(v1Search)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		transactionID string
+		bppID         string
+		body          string
+		want          []Ref
+	}{
+		{"all items known", "txn-1", "", `{"message":{"order":{"provider":{"id":"p1"},"items":[{"id":"i1"},{"id":"i2"}]}}}`, nil},
+		{"unknown item", "txn-1", "", `{"message":{"order":{"provider":{"id":"p1"},"items":[{"id":"i1"},{"id":"i7"}]}}}`, []Ref{{"p1", "i7"}}},
+		{"wrong provider", "txn-1", "", `{"message":{"order":{"provider":{"id":"p2"},"items":[{"id":"i1"}]}}}`, []Ref{{"p2", "i1"}}},
+		{"item without a provider", "txn-1", "", `{"message":{"order":{"items":[{"id":"i3"}]}}}`, nil},
+		{"matching BPP", "txn-1", "bpp.one", `{"message":{"order":{"provider":{"id":"p1"},"items":[{"id":"i1"}]}}}`, nil},
+		{"other BPP", "txn-1", "bpp.other", `{"message":{"order":{"provider":{"id":"p1"},"items":[{"id":"i1"}]}}}`, []Ref{{"p1", "i1"}}},
+		{"no cached catalog", "txn-2", "", `{"message":{"order":{"provider":{"id":"p1"},"items":[{"id":"i7"}]}}}`, nil},
+		{"no items selected", "txn-1", "", `{"message":{"order":{}}}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Missing(ctx, "", tt.transactionID, tt.bppID, []byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Missing = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		valid bool
+	}{
+		{"defaults", Query{Page: 1, PageSize: 20}, true},
+		{"sort descending", Query{Sort: "-price", Page: 1, PageSize: 20}, true},
+		{"unknown sort", Query{Sort: "rating", Page: 1, PageSize: 20}, false},
+		{"distance without near", Query{Sort: SortDistance, Page: 1, PageSize: 20}, false},
+		{"distance with near", Query{Sort: SortDistance, Near: "12.9,77.6", Page: 1, PageSize: 20}, true},
+		{"invalid near", Query{Near: "bengaluru", Page: 1, PageSize: 20}, false},
+		{"radius without near", Query{RadiusKm: 5, Page: 1, PageSize: 20}, false},
+		{"zero page", Query{Page: 0, PageSize: 20}, false},
+		{"zero page size", Query{Page: 1, PageSize: 0}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.query.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestQueryApply(t *testing.T) {
+	items := append(Extract([]byte(v1Search)), Extract([]byte(v2Discover))...)
+	price := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+		total int
+	}{
+		{"response order", Query{Page: 1, PageSize: 10}, []string{"i1", "i2", "i3", "ev-1", "ev-2", "ev-4"}, 6},
+		{"paging", Query{Page: 2, PageSize: 4}, []string{"ev-2", "ev-4"}, 6},
+		{"page past the end", Query{Page: 3, PageSize: 4}, []string{}, 6},
+		{"provider", Query{ProviderID: "p1", Page: 1, PageSize: 10}, []string{"i1", "i2", "i3"}, 3},
+		{"item", Query{ItemID: "ev-2", Page: 1, PageSize: 10}, []string{"ev-2"}, 1},
+		{"location ID", Query{Location: "l2", Page: 1, PageSize: 10}, []string{"i2", "i3"}, 2},
+		{"city ignoring case", Query{Location: "bengaluru", Page: 1, PageSize: 10}, []string{"i1", "i3", "ev-1"}, 3},
+		{"text in item or provider name", Query{Text: "CHARGE", Page: 1, PageSize: 10}, []string{"ev-1"}, 1},
+		{"price range excludes unpriced items", Query{MinPrice: price(100), MaxPrice: price(1000), Page: 1, PageSize: 10}, []string{"i2"}, 1},
+		{"sort by name", Query{Sort: SortName, ItemID: "", ProviderID: "p1", Page: 1, PageSize: 10}, []string{"i3", "i1", "i2"}, 3},
+		{"sort by price, unpriced last", Query{Sort: SortPrice, Page: 1, PageSize: 10}, []string{"ev-1", "i2", "i1", "i3", "ev-2", "ev-4"}, 6},
+		{"sort by price descending, unpriced last", Query{Sort: "-price", Page: 1, PageSize: 10}, []string{"i1", "i2", "ev-1", "i3", "ev-2", "ev-4"}, 6},
+		{"within a radius", Query{Near: "12.98,77.60", RadiusKm: 10, Page: 1, PageSize: 10}, []string{"i1", "i3", "ev-1"}, 3},
+		{"sort by distance", Query{Near: "19.0,72.8", Sort: SortDistance, ProviderID: "p1", Page: 1, PageSize: 10}, []string{"i2", "i3", "i1"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, total := tt.query.Apply(items)
+			if got := ids(page); !slices.Equal(got, tt.want) || total != tt.total {
+				t.Errorf("Apply = %v (total %d), want %v (total %d)", got, total, tt.want, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Item is a catalog item normalised from an on_search or on_discover response
+type Item struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name,omitempty"`
+	ProviderID   string     `json:"provider_id"`
+	ProviderName string     `json:"provider_name,omitempty"`
+	BppID        string     `json:"bpp_id,omitempty"`
+	Price        *float64   `json:"price,omitempty"`
+	Currency     string     `json:"currency,omitempty"`
+	Locations    []Location `json:"locations,omitempty"`
+	// Position is the item's position in the response it came from
+	Position int `json:"position"`
+	// Raw is the item as returned by the BPP
+	Raw json.RawMessage `json:"item"`
+}
+
+// Location is a place an item is available at
+type Location struct {
+	ID string `json:"id,omitempty"`
+	// GPS is "lat,lon" as in Beckn location objects
+	GPS  string `json:"gps,omitempty"`
+	City string `json:"city,omitempty"`
+}
+
+// key identifies an item within a transaction's catalog
+func (i Item) key() string {
+	return i.ProviderID + "|" + i.ID
+}
+
+// object is a decoded JSON object
+type object = map[string]interface{}
+
+// Extract returns the items of an on_search or on_discover payload
+// Both the Beckn 1.x layout (message.catalog.providers[].items[]) and the
+// 2.x layout (message.catalogs[].beckn:items[]) are understood. Items
+// without an ID or provider are skipped.
+func Extract(body []byte) []Item {
+	var payload struct {
+		Context struct {
+			BppID string `json:"bpp_id"`
+		} `json:"context"`
+		Message struct {
+			Catalog  object   `json:"catalog"`
+			Catalogs []object `json:"catalogs"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return nil
+	}
+
+	var items []Item
+	if catalog := payload.Message.Catalog; catalog != nil {
+		providers := array(catalog, "providers")
+		if len(providers) == 0 {
+			providers = array(catalog, "bpp/providers")
+		}
+		for _, provider := range providers {
+			items = append(items, providerItems(provider, payload.Context.BppID)...)
+		}
+	}
+	for _, catalog := range payload.Message.Catalogs {
+		bppID := firstString(catalog, "beckn:bppId", "bpp_id")
+		if bppID == "" {
+			bppID = payload.Context.BppID
+		}
+		for _, item := range array(catalog, "beckn:items", "items") {
+			if normalised, ok := itemV2(item, bppID); ok {
+				items = append(items, normalised)
+			}
+		}
+	}
+	for i := range items {
+		items[i].Position = i
+	}
+	return items
+}
+
+// providerItems normalises the items of a Beckn 1.x provider
+func providerItems(provider object, bppID string) []Item {
+	providerID := firstString(provider, "id")
+	providerName := firstString(child(provider, "descriptor"), "name")
+
+	locations := map[string]Location{}
+	var all []Location
+	for _, location := range array(provider, "locations") {
+		loc := Location{
+			ID:   firstString(location, "id"),
+			GPS:  firstString(location, "gps"),
+			City: firstString(child(location, "city"), "name", "code"),
+		}
+		locations[loc.ID] = loc
+		all = append(all, loc)
+	}
+
+	var items []Item
+	for _, item := range array(provider, "items") {
+		normalised := Item{
+			ID:           firstString(item, "id"),
+			Name:         firstString(child(item, "descriptor"), "name"),
+			ProviderID:   providerID,
+			ProviderName: providerName,
+			BppID:        bppID,
+			Raw:          raw(item),
+		}
+		if normalised.ID == "" || providerID == "" {
+			continue
+		}
+		normalised.Price, normalised.Currency = price(child(item, "price"))
+
+		ids := strings.Fields(firstString(item, "location_id"))
+		for _, value := range list(item, "location_ids") {
+			if id, ok := value.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			normalised.Locations = all
+		}
+		for _, id := range ids {
+			if loc, ok := locations[id]; ok {
+				normalised.Locations = append(normalised.Locations, loc)
+			} else {
+				normalised.Locations = append(normalised.Locations, Location{ID: id})
+			}
+		}
+		items = append(items, normalised)
+	}
+	return items
+}
+
+// itemV2 normalises a Beckn 2.x catalog item
+func itemV2(item object, bppID string) (Item, bool) {
+	provider := child(item, "beckn:provider")
+	normalised := Item{
+		ID:           firstString(item, "beckn:id", "id"),
+		Name:         firstString(child(item, "beckn:descriptor"), "schema:name", "name"),
+		ProviderID:   firstString(provider, "beckn:id", "id"),
+		ProviderName: firstString(child(provider, "beckn:descriptor"), "schema:name", "name"),
+		BppID:        bppID,
+		Raw:          raw(item),
+	}
+	if normalised.ProviderID == "" {
+		normalised.ProviderID = firstString(item, "beckn:providerId")
+	}
+	if normalised.ID == "" || normalised.ProviderID == "" {
+		return Item{}, false
+	}
+	normalised.Price, normalised.Currency = price(child(item, "beckn:price"))
+
+	for _, place := range array(item, "beckn:availableAt") {
+		loc := Location{ID: firstString(place, "beckn:id", "id")}
+		// GeoJSON points are [lon, lat]
+		if coordinates := list(child(place, "geo"), "coordinates"); len(coordinates) == 2 {
+			lon, lonOK := coordinates[0].(float64)
+			lat, latOK := coordinates[1].(float64)
+			if lonOK && latOK {
+				loc.GPS = fmt.Sprintf("%g,%g", lat, lon)
+			}
+		}
+		loc.City = firstString(child(place, "address"), "addressLocality")
+		normalised.Locations = append(normalised.Locations, loc)
+	}
+	return normalised, true
+}
+
+// Ref identifies an item ordered in a select request
+type Ref struct {
+	ProviderID string
+	ItemID     string
+}
+
+// Selected returns the items ordered in a select request, in both Beckn layouts
+func Selected(body []byte) []Ref {
+	var payload struct {
+		Message struct {
+			Order object `json:"order"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) != nil || payload.Message.Order == nil {
+		return nil
+	}
+	order := payload.Message.Order
+
+	var refs []Ref
+	providerID := firstString(child(order, "provider"), "id")
+	for _, item := range array(order, "items") {
+		if id := firstString(item, "id"); id != "" {
+			refs = append(refs, Ref{ProviderID: providerID, ItemID: id})
+		}
+	}
+	seller := firstString(order, "beckn:seller")
+	for _, item := range array(order, "beckn:orderItems") {
+		if id := firstString(item, "beckn:orderedItem"); id != "" {
+			refs = append(refs, Ref{ProviderID: seller, ItemID: id})
+		}
+	}
+	return refs
+}
+
+// child returns the object under key, or nil
+func child(node object, key string) object {
+	value, _ := node[key].(object)
+	return value
+}
+
+// list returns the array under key, or nil
+func list(node object, key string) []interface{} {
+	value, _ := node[key].([]interface{})
+	return value
+}
+
+// array returns the objects in the first array found under keys
+func array(node object, keys ...string) []object {
+	for _, key := range keys {
+		values := list(node, key)
+		if values == nil {
+			continue
+		}
+		objects := make([]object, 0, len(values))
+		for _, value := range values {
+			if obj, ok := value.(object); ok {
+				objects = append(objects, obj)
+			}
+		}
+		return objects
+	}
+	return nil
+}
+
+// firstString returns the first non-empty string or number found under keys
+func firstString(node object, keys ...string) string {
+	for _, key := range keys {
+		switch value := node[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// price reads a Beckn price, whose value may be a number or a decimal string
+func price(node object) (*float64, string) {
+	currency := firstString(node, "currency", "schema:priceCurrency")
+	text := firstString(node, "value", "schema:price")
+	if text == "" {
+		return nil, currency
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, currency
+	}
+	return &value, currency
+}
+
+// raw re-encodes a decoded JSON object
+func raw(node object) json.RawMessage {
+	data, _ := json.Marshal(node)
+	return data
+}
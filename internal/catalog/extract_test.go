@@ -0,0 +1,145 @@
+package catalog
+
+import (
+	"slices"
+	"testing"
+)
+
+// v1Search is an on_search response in the Beckn 1.x layout
+const v1Search = `{
+  "context": {"action": "on_search", "bpp_id": "bpp.one"},
+  "message": {"catalog": {"providers": [
+    {
+      "id": "p1",
+      "descriptor": {"name": "Corner Store"},
+      "locations": [
+        {"id": "l1", "gps": "12.97,77.59", "city": {"name": "Bengaluru"}},
+        {"id": "l2", "gps": "19.07,72.87", "city": {"code": "std:022"}}
+      ],
+      "items": [
+        {"id": "i1", "descriptor": {"name": "Laptop"}, "price": {"currency": "INR", "value": "49999.50"}, "location_ids": ["l1"]},
+        {"id": "i2", "descriptor": {"name": "Mouse"}, "price": {"currency": "INR", "value": 499}, "location_id": "l2"},
+        {"id": "i3", "descriptor": {"name": "Cable"}},
+        {"descriptor": {"name": "No ID"}}
+      ]
+    },
+    {"descriptor": {"name": "No provider ID"}, "items": [{"id": "i9"}]}
+  ]}}
+}`
+
+// v2Discover is an on_discover response in the Beckn 2.x layout
+const v2Discover = `{
+  "context": {"action": "on_discover", "bpp_id": "bpp.context"},
+  "message": {"catalogs": [
+    {
+      "beckn:bppId": "bpp.two",
+      "beckn:items": [
+        {
+          "beckn:id": "ev-1",
+          "beckn:descriptor": {"schema:name": "Fast Charger"},
+          "beckn:provider": {"beckn:id": "cpo-1", "beckn:descriptor": {"schema:name": "ChargeCo"}},
+          "beckn:price": {"schema:price": 18, "schema:priceCurrency": "INR"},
+          "beckn:availableAt": [{"beckn:id": "site-1", "geo": {"type": "Point", "coordinates": [77.59, 12.97]}, "address": {"addressLocality": "Bengaluru"}}]
+        },
+        {"beckn:id": "ev-2", "beckn:providerId": "cpo-2"},
+        {"beckn:id": "ev-3"}
+      ]
+    },
+    {"items": [{"id": "ev-4", "beckn:provider": {"id": "cpo-3"}}]}
+  ]}
+}`
+
+func TestExtract(t *testing.T) {
+	price := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name string
+		body string
+		want []Item
+	}{
+		{
+			name: "beckn 1.x providers",
+			body: v1Search,
+			want: []Item{
+				{ID: "i1", Name: "Laptop", ProviderID: "p1", ProviderName: "Corner Store", BppID: "bpp.one", Price: price(49999.5), Currency: "INR",
+					Locations: []Location{{ID: "l1", GPS: "12.97,77.59", City: "Bengaluru"}}},
+				{ID: "i2", Name: "Mouse", ProviderID: "p1", ProviderName: "Corner Store", BppID: "bpp.one", Price: price(499), Currency: "INR",
+					Locations: []Location{{ID: "l2", GPS: "19.07,72.87", City: "std:022"}}, Position: 1},
+				{ID: "i3", Name: "Cable", ProviderID: "p1", ProviderName: "Corner Store", BppID: "bpp.one",
+					Locations: []Location{{ID: "l1", GPS: "12.97,77.59", City: "Bengaluru"}, {ID: "l2", GPS: "19.07,72.87", City: "std:022"}}, Position: 2},
+			},
+		},
+		{
+			name: "beckn 2.x catalogs",
+			body: v2Discover,
+			want: []Item{
+				{ID: "ev-1", Name: "Fast Charger", ProviderID: "cpo-1", ProviderName: "ChargeCo", BppID: "bpp.two", Price: price(18), Currency: "INR",
+					Locations: []Location{{ID: "site-1", GPS: "12.97,77.59", City: "Bengaluru"}}},
+				{ID: "ev-2", ProviderID: "cpo-2", BppID: "bpp.two", Position: 1},
+				{ID: "ev-4", ProviderID: "cpo-3", BppID: "bpp.context", Position: 2},
+			},
+		},
+		{name: "no catalog", body: `{"message":{}}`},
+		{name: "invalid JSON", body: `{"message":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract([]byte(tt.body))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Extract returned %d items, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if len(got[i].Raw) == 0 {
+					t.Errorf("item %d has no raw item", i)
+				}
+				got[i].Raw = nil
+				if !itemsEqual(got[i], tt.want[i]) {
+					t.Errorf("item %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// itemsEqual compares items by value, including their prices
+func itemsEqual(a, b Item) bool {
+	if (a.Price == nil) != (b.Price == nil) || (a.Price != nil && *a.Price != *b.Price) {
+		return false
+	}
+	a.Price, b.Price = nil, nil
+	return a.ID == b.ID && a.Name == b.Name && a.ProviderID == b.ProviderID && a.ProviderName == b.ProviderName &&
+		a.BppID == b.BppID && a.Currency == b.Currency && a.Position == b.Position && slices.Equal(a.Locations, b.Locations)
+}
+
+func TestSelected(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Ref
+	}{
+		{
+			name: "beckn 1.x order",
+			body: `{"message":{"order":{"provider":{"id":"p1"},"items":[{"id":"i1"},{"id":"i2"},{"quantity":1}]}}}`,
+			want: []Ref{{"p1", "i1"}, {"p1", "i2"}},
+		},
+		{
+			name: "beckn 2.x order",
+			body: `{"message":{"order":{"beckn:seller":"cpo-1","beckn:orderItems":[{"beckn:orderedItem":"ev-1"}]}}}`,
+			want: []Ref{{"cpo-1", "ev-1"}},
+		},
+		{
+			name: "items without a provider",
+			body: `{"message":{"order":{"items":[{"id":"i1"}]}}}`,
+			want: []Ref{{"", "i1"}},
+		},
+		{name: "no order", body: `{"message":{}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Selected([]byte(tt.body)); !slices.Equal(got, tt.want) {
+				t.Errorf("Selected = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"BAP_Sandbox/internal/transformers"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+var adminLog = logging.For("admin")
+
+// AdminController exposes endpoints to manage mappings and inspect transactions and pending waits
+type AdminController struct {
+	apiKey string
+}
+
+// NewAdminController creates a new admin controller
+// Requests must present apiKey in the X-Admin-Key header; without a key every admin request is refused
+func NewAdminController(apiKey string) *AdminController {
+	return &AdminController{
+		apiKey: apiKey,
+	}
+}
+
+// MappingSummary describes a loaded route mapping
+type MappingSummary struct {
+	Route      string `json:"route"`
+	HasForward bool   `json:"has_forward"`
+	HasReverse bool   `json:"has_reverse"`
+}
+
+// Authorize rejects requests without a valid admin key
+// The admin endpoints are disabled while no key is configured.
+func (ac *AdminController) Authorize(c *fiber.Ctx) error {
+	if ac.apiKey == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "admin endpoints are disabled; set ADMIN_API_KEY to enable them",
+		})
+	}
+
+	provided := c.Get("X-Admin-Key")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(ac.apiKey)) != 1 {
+		adminLog.WarnContext(c.UserContext(), "Unauthorized admin request", "path", c.Path(), "ip", c.IP())
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid or missing X-Admin-Key header",
+		})
+	}
+	return c.Next()
+}
+
+// ListMappings returns all loaded route mappings
+func (ac *AdminController) ListMappings(c *fiber.Ctx) error {
+	transformer, err := transformers.GetTransformer()
+	if err != nil {
+		return transformerUnavailable(c, err)
+	}
+
+	loader := transformer.GetLoader()
+	routes := loader.GetRoutes()
+	summaries := make([]MappingSummary, 0, len(routes))
+	for _, route := range routes {
+		transform, err := loader.GetRouteTransform(route)
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, MappingSummary{
+			Route:      route,
+			HasForward: transform.Forward != "",
+			HasReverse: transform.Reverse != "",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"mappings_path": loader.GetConfigPath(),
+		"count":         len(summaries),
+		"mappings":      summaries,
+	})
+}
+
+// GetMapping returns the forward and reverse templates for a route
+func (ac *AdminController) GetMapping(c *fiber.Ctx) error {
+	route := c.Params("route")
+
+	transformer, err := transformers.GetTransformer()
+	if err != nil {
+		return transformerUnavailable(c, err)
+	}
+
+	transform, err := transformer.GetLoader().GetRouteTransform(route)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"route":   route,
+		"forward": transform.Forward,
+		"reverse": transform.Reverse,
+	})
+}
+
+// TestTransform runs a transformation on the posted payload and returns input and output side by side
+// The direction is selected with the "direction" query parameter (forward by default)
+func (ac *AdminController) TestTransform(c *fiber.Ctx) error {
+	route := c.Params("route")
+	direction := transformers.TransformDirection(c.Query("direction", string(transformers.DirectionForward)))
+	if direction != transformers.DirectionForward && direction != transformers.DirectionReverse {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "direction must be 'forward' or 'reverse'",
+		})
+	}
+
+	body := c.Body()
+	if err := transformers.ValidateJSON(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	transformer, err := transformers.GetTransformer()
+	if err != nil {
+		return transformerUnavailable(c, err)
+	}
+
+	adminLog.InfoContext(c.UserContext(), "Running test transformation", "route", route, "direction", direction)
+	// Dry runs are kept out of the transform metrics so they do not skew production error rates
+	output, err := transformer.DryRun(route, direction, body)
+	if err != nil {
+		response := fiber.Map{
+			"route":     route,
+			"direction": direction,
+			"input":     json.RawMessage(body),
+			"error":     err.Error(),
+		}
+		if transformErr, ok := err.(*transformers.TransformError); ok && transformErr.Err != nil {
+			response["details"] = transformErr.Err.Error()
+		}
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+	}
+
+	return c.JSON(fiber.Map{
+		"route":     route,
+		"direction": direction,
+		"input":     json.RawMessage(body),
+		"output":    json.RawMessage(output),
+	})
+}
+
+// UploadMappings validates, persists and activates a new mapping set posted as YAML
+func (ac *AdminController) UploadMappings(c *fiber.Ctx) error {
+	body := c.Body()
+	if len(body) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "request body must contain the mappings YAML",
+		})
+	}
+
+	// Tenants with their own mappings would keep serving them, so the upload would only apply partially
+	if owned := tenantsWithOwnMappings(); len(owned) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "mapping uploads are not supported while tenants have their own mappings",
+			"tenants": owned,
+		})
+	}
+
+	adminLog.InfoContext(c.UserContext(), "Received mapping upload", "bytes", len(body))
+	transformer, err := transformers.UpdateMappings(body)
+	if err != nil {
+		adminLog.WarnContext(c.UserContext(), "Mapping upload rejected", "error", err)
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loader := transformer.GetLoader()
+	adminLog.InfoContext(c.UserContext(), "Mapping upload applied", "routes", len(loader.GetRoutes()))
+	return c.JSON(fiber.Map{
+		"status":        "ok",
+		"mappings_path": loader.GetConfigPath(),
+		"routes":        loader.GetRoutes(),
+	})
+}
+
+// tenantsWithOwnMappings returns the tenants that do not use the global mappings
+func tenantsWithOwnMappings() []string {
+	var names []string
+	for _, tenant := range tenants.All() {
+		if tenant.HasOwnMappings() {
+			names = append(names, tenant.Name)
+		}
+	}
+	return names
+}
+
+// GetTransaction returns a transaction's record, timeline and pending waits
+// The "tenant" query parameter selects a named tenant's transaction. With the "since"
+// query parameter (RFC 3339) only later events are returned, for tailing.
+func (ac *AdminController) GetTransaction(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	transactionID := utils.CopyString(c.Params("transaction_id"))
+	tenant := c.Query("tenant")
+
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "since must be an RFC 3339 timestamp",
+			})
+		}
+		since = parsed
+	}
+
+	fields, err := storage.GetTransaction(ctx, tenant, transactionID)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return storageUnavailable(c, err)
+	}
+	events, err := storage.GetTransactionEvents(ctx, tenant, transactionID, since)
+	if err != nil {
+		return storageUnavailable(c, err)
+	}
+	if fields == nil && len(events) == 0 && since.IsZero() {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transaction not found",
+		})
+	}
+	all, err := ListPendingRequests(ctx, transactionID)
+	if err != nil {
+		return storageUnavailable(c, err)
+	}
+	pending := make([]PendingRequest, 0, len(all))
+	for _, p := range all {
+		if p.Tenant == tenant {
+			pending = append(pending, p)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"transaction_id": transactionID,
+		"transaction":    fields,
+		"events":         events,
+		"pending":        pending,
+	})
+}
+
+// ListPending returns the requests waiting for a callback across all instances
+// The "transaction_id" query parameter limits the list to one transaction.
+func (ac *AdminController) ListPending(c *fiber.Ctx) error {
+	pending, err := ListPendingRequests(c.UserContext(), c.Query("transaction_id"))
+	if err != nil {
+		return storageUnavailable(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"count":   len(pending),
+		"pending": pending,
+	})
+}
+
+// CancelPending cancels the waits of a transaction, or of one of its messages
+// The waiting clients receive a WAIT_CANCELLED NACK; the "route" query parameter narrows the match.
+func (ac *AdminController) CancelPending(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	transactionID := utils.CopyString(c.Params("transaction_id"))
+	messageID := c.Params("message_id")
+	route := c.Query("route")
+
+	pending, err := ListPendingRequests(ctx, transactionID)
+	if err != nil {
+		return storageUnavailable(c, err)
+	}
+
+	cancelled := []fiber.Map{}
+	for _, p := range pending {
+		if (messageID != "" && p.MessageID != messageID) || (route != "" && p.Route != route) {
+			continue
+		}
+		recordEvent(withTimeline(ctx, p.Tenant, p.TransactionID, p.Route, p.MessageID), storage.TransactionEvent{
+			Type:   storage.EventCancelled,
+			Detail: "cancelled through the admin API",
+		})
+		receivers, err := GetCallbackManager().ForTenant(p.Tenant).CancelPendingRequest(ctx, p.Route, p.TransactionID, p.MessageID)
+		if err != nil {
+			return storageUnavailable(c, err)
+		}
+		adminLog.InfoContext(ctx, "Cancelled pending request", "transaction_id", p.TransactionID, "message_id", p.MessageID, "route", p.Route, "tenant", p.Tenant, "waiting", receivers > 0)
+		cancelled = append(cancelled, fiber.Map{
+			"request": p,
+			"waiting": receivers > 0,
+		})
+	}
+
+	if len(cancelled) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "no pending request matches",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"count":     len(cancelled),
+		"cancelled": cancelled,
+	})
+}
+
+// storageUnavailable writes the response used when Redis cannot be read
+func storageUnavailable(c *fiber.Ctx, err error) error {
+	adminLog.ErrorContext(c.UserContext(), "Redis request failed", "error", err)
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// transformerUnavailable writes the response used when no transformer is loaded
+func transformerUnavailable(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
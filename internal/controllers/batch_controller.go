@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/tenants"
+	"BAP_Sandbox/internal/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var batchLog = logging.For("batch")
+
+// BatchController forwards several Beckn requests received in one call
+// Each item is dispatched through the application's own handler as a POST to
+// /api/{action}, or /api/{tenant}/{action} for a batch sent to /api/{tenant}/batch,
+// so it is authenticated, assigned a tenant, rate limited, forwarded and answered
+// exactly like a request sent on its own.
+type BatchController struct {
+	app         *fiber.App
+	maxItems    int
+	parallelism int
+	timeout     time.Duration
+	itemTimeout time.Duration
+}
+
+// BatchOptions configures the batch controller
+type BatchOptions struct {
+	// MaxItems caps the number of requests in one batch
+	MaxItems int
+	// Parallelism is how many items of a batch are forwarded at once
+	Parallelism int
+	// Timeout bounds a whole batch
+	Timeout time.Duration
+	// ItemTimeout is the longest an item can take once forwarded, i.e. the callback wait
+	// Items are only forwarded while this much time remains before the batch deadline.
+	ItemTimeout time.Duration
+}
+
+// NewBatchController creates a batch controller dispatching items through app
+func NewBatchController(app *fiber.App, opts BatchOptions) *BatchController {
+	return &BatchController{
+		app:         app,
+		maxItems:    opts.MaxItems,
+		parallelism: opts.Parallelism,
+		timeout:     opts.Timeout,
+		itemTimeout: opts.ItemTimeout,
+	}
+}
+
+// BatchResult is the outcome of one item of a batch
+type BatchResult struct {
+	Index         int     `json:"index"`
+	Action        string  `json:"action,omitempty"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+	MessageID     string  `json:"message_id,omitempty"`
+	Status        int     `json:"status"`
+	OK            bool    `json:"ok"`
+	DurationMs    float64 `json:"duration_ms"`
+	// Response is the body the item would have received from /api/{action}
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error summarises why the item failed
+	Error string `json:"error,omitempty"`
+}
+
+// batchItem is the part of a batch item's context needed to dispatch it
+type batchItem struct {
+	Context struct {
+		Action        string `json:"action"`
+		TransactionID string `json:"transaction_id"`
+		MessageID     string `json:"message_id"`
+	} `json:"context"`
+}
+
+// HandleBatch forwards a JSON array of Beckn requests and returns one result per item
+// Items are forwarded concurrently, at most parallelism at a time, and each waits for its
+// own callback. A failing or timed-out item does not affect the others; the batch itself
+// succeeds once every item has a result. Items that could not finish before the batch
+// deadline fail without being forwarded, so the batch answers before its client gives up.
+func (bc *BatchController) HandleBatch(c *fiber.Ctx) error {
+	start := time.Now()
+	deadline := start.Add(bc.timeout)
+	ctx, span := tracing.Tracer().Start(tracing.ExtractFiber(c), "batch",
+		trace.WithSpanKind(trace.SpanKindServer),
+	)
+	defer span.End()
+
+	// A batch sent to /api/{tenant}/batch dispatches its items under the same prefix
+	prefix := ""
+	if name := c.Params("tenant"); name != "" {
+		if _, ok := tenants.Lookup(name); !ok {
+			batchLog.WarnContext(ctx, "Batch for unknown tenant", "tenant", name)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "No tenant matches this request",
+			})
+		}
+		prefix = utils.CopyString(name) + "/"
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(c.Body(), &items); err != nil {
+		batchLog.WarnContext(ctx, "Invalid batch body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Body must be a JSON array of Beckn requests",
+		})
+	}
+	if len(items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Batch contains no requests",
+		})
+	}
+	if len(items) > bc.maxItems {
+		batchLog.WarnContext(ctx, "Batch too large", "items", len(items), "max_items", bc.maxItems)
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("Batch contains %d requests, the limit is %d", len(items), bc.maxItems),
+		})
+	}
+	span.SetAttributes(attribute.Int("batch.size", len(items)))
+	metrics.BatchSize.Observe(float64(len(items)))
+	batchLog.InfoContext(ctx, "Received batch", "items", len(items))
+
+	// Items carry the batch's headers, with the batch span as their parent
+	headers := copyRequestHeaders(c)
+	headers.Del(fiber.HeaderContentLength)
+	tracing.InjectHTTP(ctx, headers)
+	remoteAddr := c.Context().RemoteAddr()
+
+	results := make([]BatchResult, len(items))
+	slots := make(chan struct{}, bc.parallelism)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = bc.dispatch(ctx, i, prefix, item, headers, remoteAddr, deadline)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		outcome := "ok"
+		if !result.OK {
+			outcome = "failed"
+			failed++
+		}
+		metrics.BatchItems.WithLabelValues(routeLabel(result.Action), outcome).Inc()
+	}
+	span.SetAttributes(attribute.Int("batch.failed", failed))
+	batchLog.InfoContext(ctx, "Batch handled", "items", len(items), "failed", failed, "duration", time.Since(start))
+
+	return c.JSON(fiber.Map{
+		"results": results,
+		"summary": fiber.Map{
+			"total":     len(results),
+			"succeeded": len(results) - failed,
+			"failed":    failed,
+		},
+	})
+}
+
+// dispatch handles one item as a POST to /api/{prefix}{action} and returns its result
+// The item fails with 504 without being forwarded if it could not finish before the deadline.
+func (bc *BatchController) dispatch(ctx context.Context, index int, prefix string, item json.RawMessage, headers http.Header, remoteAddr net.Addr, deadline time.Time) BatchResult {
+	start := time.Now()
+	result := BatchResult{Index: index}
+
+	var parsed batchItem
+	if err := json.Unmarshal(item, &parsed); err != nil {
+		result.Status = fiber.StatusBadRequest
+		result.Error = "Item is not a JSON object with a context"
+		return result
+	}
+	result.Action = parsed.Context.Action
+	result.TransactionID = parsed.Context.TransactionID
+	result.MessageID = parsed.Context.MessageID
+
+	// The action selects the route; batches cannot be nested
+	action := parsed.Context.Action
+	if action == "" || action == "batch" || strings.ContainsAny(action, "/?#") {
+		result.Status = fiber.StatusBadRequest
+		result.Error = "context.action must name a Beckn action"
+		return result
+	}
+
+	if time.Until(deadline) < bc.itemTimeout {
+		batchLog.WarnContext(ctx, "Batch deadline reached, item not forwarded", "index", index, "route", action)
+		result.Status = fiber.StatusGatewayTimeout
+		result.Error = "Batch deadline reached before the item was forwarded"
+		return result
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	for key, values := range headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.SetMethod(fiber.MethodPost)
+	req.Header.SetContentType(fiber.MIMEApplicationJSON)
+	req.SetRequestURI("/api/" + prefix + action)
+	req.SetBody(item)
+
+	var fctx fasthttp.RequestCtx
+	fctx.Init(req, remoteAddr, nil)
+	batchLog.DebugContext(ctx, "Dispatching batch item", "index", index, "route", action,
+		"transaction_id", result.TransactionID, "message_id", result.MessageID)
+	bc.app.Server().Handler(&fctx)
+
+	result.Status = fctx.Response.StatusCode()
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	body := append([]byte(nil), fctx.Response.Body()...)
+	if json.Valid(body) {
+		result.Response = body
+	} else if len(body) > 0 {
+		result.Response, _ = json.Marshal(string(body))
+	}
+	result.Error = itemError(result.Status, body)
+	result.OK = result.Error == ""
+	return result
+}
+
+// itemError returns why an item failed, or "" if it succeeded
+// Error statuses and Beckn NACKs are failures; the message is taken from the response when present.
+func itemError(status int, body []byte) string {
+	var response struct {
+		Message struct {
+			Ack struct {
+				Status string `json:"status"`
+			} `json:"ack"`
+		} `json:"message"`
+		Error json.RawMessage `json:"error"`
+	}
+	_ = json.Unmarshal(body, &response)
+	if status >= 200 && status < 300 && response.Message.Ack.Status != "NACK" {
+		return ""
+	}
+
+	// The adapter returns "error" as a string; Beckn errors are objects with a message
+	var message string
+	if json.Unmarshal(response.Error, &message) == nil && message != "" {
+		return message
+	}
+	var becknError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(response.Error, &becknError) == nil && becknError.Message != "" {
+		return becknError.Message
+	}
+	if response.Message.Ack.Status == "NACK" {
+		return "NACK"
+	}
+	return fmt.Sprintf("HTTP %d %s", status, http.StatusText(status))
+}
@@ -1,22 +1,49 @@
 package controllers
 
 import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
 	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tracing"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"sync/atomic"
 	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var redisLog = logging.For("redis")
+
 // CallbackResponse represents a response waiting to be delivered
 type CallbackResponse struct {
-	Body       []byte            `json:"body"`
-	StatusCode int               `json:"status_code"`
-	Headers    map[string]string `json:"headers"`
+	Body        []byte            `json:"body"`
+	StatusCode  int               `json:"status_code"`
+	Headers     map[string]string `json:"headers"`
+	TraceParent string            `json:"trace_parent,omitempty"`
+	// Cancelled is set when an operator cancels the wait instead of a callback arriving
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// ErrWaitCancelled is returned by WaitForCallback when the wait was cancelled through the admin API
+var ErrWaitCancelled = errors.New("wait for callback cancelled")
+
+// PendingRequest is a request waiting for its callback on any instance
+type PendingRequest struct {
+	Tenant        string `json:"tenant,omitempty"`
+	Route         string `json:"route"`
+	TransactionID string `json:"transaction_id"`
+	MessageID     string `json:"message_id"`
+	CreatedAt     string `json:"created_at"`
+	// TTLMs is how long the pending entry is kept in Redis, which outlasts the wait itself
+	TTLMs int64 `json:"ttl_ms"`
 }
 
 // RouteMapping maps forward routes to their callback routes
+// The defaults are replaced by the configured route table at startup.
 var RouteMapping = map[string]string{
 	"discover": "on_discover",
 	"search":   "on_search",
@@ -31,142 +58,196 @@ var RouteMapping = map[string]string{
 	"status":   "on_status",
 }
 
+// SetRouteMapping replaces the route table; it must be called before serving requests
+func SetRouteMapping(mapping map[string]string) {
+	RouteMapping = mapping
+}
+
+// routeLabel returns the route name for use as a metrics label
+// Unknown routes are collapsed into a single label to bound cardinality.
+// The returned string never aliases the input, which may point into a
+// Fiber request buffer that is reused after the handler returns.
+func routeLabel(route string) string {
+	for forwardRoute, callbackRoute := range RouteMapping {
+		switch route {
+		case forwardRoute:
+			return forwardRoute
+		case callbackRoute:
+			return callbackRoute
+		}
+	}
+	return "unknown"
+}
+
+// pendingWaits counts requests currently waiting for a callback on this instance
+var pendingWaits atomic.Int64
+
+// PendingWaitCount returns the number of requests waiting for a callback on this instance
+func PendingWaitCount() int64 {
+	return pendingWaits.Load()
+}
+
 // CallbackManager manages pending requests using Redis
-type CallbackManager struct{}
+type CallbackManager struct {
+	// tenant namespaces the keys and channels so tenants sharing Redis never see each other's callbacks
+	tenant string
+}
 
 // GetCallbackManager returns a callback manager instance
 func GetCallbackManager() *CallbackManager {
 	return &CallbackManager{}
 }
 
+// ForTenant returns a callback manager whose keys are namespaced by tenant
+// The empty tenant uses the un-namespaced keys of a single-tenant deployment.
+func (cm *CallbackManager) ForTenant(tenant string) *CallbackManager {
+	return &CallbackManager{tenant: tenant}
+}
+
 // AddPendingRequest adds a new pending request to Redis
-func (cm *CallbackManager) AddPendingRequest(subRoute, transactionID, messageID string) error {
-	ctx := storage.GetContext()
+// The span context in ctx is stored so the callback span can link back to the waiting request
+func (cm *CallbackManager) AddPendingRequest(ctx context.Context, subRoute, transactionID, messageID string, ttl time.Duration) error {
 	key := cm.makePendingKey(subRoute, transactionID, messageID)
 
-	log.Printf("[Redis] Adding pending request - Route: %s, TransactionID: %s, MessageID: %s", subRoute, transactionID, messageID)
-	log.Printf("[Redis] Pending key: %s", key)
+	redisLog.DebugContext(ctx, "Adding pending request", "key", key)
 
-	// Store pending request metadata in Redis with 35 second TTL
+	// Store pending request metadata in Redis until the TTL, which outlasts the callback wait
 	metadata := map[string]string{
+		"route":          subRoute,
 		"transaction_id": transactionID,
 		"message_id":     messageID,
 		"created_at":     time.Now().Format(time.RFC3339),
 	}
+	if cm.tenant != "" {
+		metadata["tenant"] = cm.tenant
+	}
+	if traceParent := tracing.Serialize(ctx); traceParent != "" {
+		metadata["trace_parent"] = traceParent
+	}
 
 	data, err := json.Marshal(metadata)
 	if err != nil {
-		log.Printf("[Redis] ERROR: Failed to marshal metadata: %v", err)
+		redisLog.ErrorContext(ctx, "Failed to marshal pending request metadata", "error", err)
 		return err
 	}
 
-	err = storage.RedisClient.Set(ctx, key, data, 35*time.Second).Err()
+	err = storage.RedisClient.Set(ctx, key, data, ttl).Err()
 	if err != nil {
-		log.Printf("[Redis] ERROR: Failed to set key in Redis: %v", err)
+		redisLog.ErrorContext(ctx, "Failed to store pending request", "key", key, "error", err)
 		return err
 	}
 
-	log.Printf("[Redis] ✓ Successfully added pending request with TTL 35s")
+	redisLog.DebugContext(ctx, "Added pending request", "key", key, "ttl", ttl)
 	return nil
 }
 
 // WaitForCallback waits for a callback response via Redis pub/sub
-func (cm *CallbackManager) WaitForCallback(subRoute, transactionID, messageID string, timeout time.Duration) (*CallbackResponse, error) {
-	ctx, cancel := context.WithTimeout(storage.GetContext(), timeout)
+// It returns context.Canceled if ctx is cancelled before the callback or the timeout
+func (cm *CallbackManager) WaitForCallback(ctx context.Context, subRoute, transactionID, messageID string, timeout time.Duration) (*CallbackResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// Subscribe to the callback channel
 	channel := cm.makeCallbackChannel(subRoute, transactionID, messageID)
-	log.Printf("[Redis] Waiting for callback - Route: %s, TransactionID: %s, MessageID: %s", subRoute, transactionID, messageID)
-	log.Printf("[Redis] Subscribing to channel: %s (timeout: %v)", channel, timeout)
+	redisLog.DebugContext(ctx, "Waiting for callback", "channel", channel, "timeout", timeout)
 
 	pubsub := storage.RedisClient.Subscribe(ctx, channel)
 	defer pubsub.Close()
 
-	log.Printf("[Redis] ✓ Subscribed, waiting for message...")
+	pendingWaits.Add(1)
+	metrics.PendingWaits.Inc()
+	defer func() {
+		pendingWaits.Add(-1)
+		metrics.PendingWaits.Dec()
+	}()
+	start := time.Now()
 
 	// Wait for message or timeout
 	ch := pubsub.Channel()
 	select {
 	case msg := <-ch:
 		// Received callback response
-		log.Printf("[Redis] ✓ Received message on channel")
+		redisLog.DebugContext(ctx, "Received callback message", "channel", channel)
+		metrics.CallbackWaitDuration.WithLabelValues(routeLabel(subRoute), "received").Observe(time.Since(start).Seconds())
 		var response CallbackResponse
 		if err := json.Unmarshal([]byte(msg.Payload), &response); err != nil {
-			log.Printf("[Redis] ERROR: Failed to unmarshal callback response: %v", err)
+			redisLog.ErrorContext(ctx, "Failed to unmarshal callback response", "error", err)
 			return nil, err
 		}
-		log.Printf("[Redis] ✓ Successfully processed callback response")
+		if response.Cancelled {
+			redisLog.InfoContext(ctx, "Wait for callback cancelled", "channel", channel)
+			metrics.CallbackWaitDuration.WithLabelValues(routeLabel(subRoute), "cancelled").Observe(time.Since(start).Seconds())
+			return nil, ErrWaitCancelled
+		}
+		// Link the waiting request's span to the webhook span that delivered the callback
+		tracing.LinkTo(ctx, response.TraceParent, attribute.String("link.type", "callback"))
 		return &response, nil
 
 	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.Canceled) {
+			// The caller stopped waiting, e.g. because ONIX rejected the request
+			redisLog.DebugContext(ctx, "Stopped waiting for callback", "channel", channel)
+			metrics.CallbackWaitDuration.WithLabelValues(routeLabel(subRoute), "cancelled").Observe(time.Since(start).Seconds())
+			return nil, ctx.Err()
+		}
+
 		// Timeout
-		log.Printf("[Redis] ERROR: Timeout waiting for callback after %v", timeout)
+		redisLog.WarnContext(ctx, "Timeout waiting for callback", "channel", channel, "timeout", timeout)
+		metrics.CallbackWaitDuration.WithLabelValues(routeLabel(subRoute), "timeout").Observe(time.Since(start).Seconds())
+		metrics.CallbackTimeouts.WithLabelValues(routeLabel(subRoute)).Inc()
 		return nil, fmt.Errorf("timeout waiting for callback")
 	}
 }
 
 // PublishCallback publishes a callback response to Redis pub/sub
-func (cm *CallbackManager) PublishCallback(subRoute, transactionID, messageID string, response CallbackResponse) error {
-	ctx := storage.GetContext()
-
-	log.Printf("[Redis] Publishing callback - Route: %s, TransactionID: %s, MessageID: %s", subRoute, transactionID, messageID)
-
+// The span in ctx is linked to the waiting request's span and propagated with the response
+func (cm *CallbackManager) PublishCallback(ctx context.Context, subRoute, transactionID, messageID string, response CallbackResponse) error {
 	// Check if pending request exists
 	key := cm.makePendingKey(subRoute, transactionID, messageID)
-	log.Printf("[Redis] Checking for pending key: %s", key)
+	redisLog.DebugContext(ctx, "Looking up pending request", "key", key)
 
-	exists, err := storage.RedisClient.Exists(ctx, key).Result()
-	if err != nil {
-		log.Printf("[Redis] ERROR: Failed to check if key exists: %v", err)
+	pending, err := storage.RedisClient.Get(ctx, key).Bytes()
+	if err != nil && err != redis.Nil {
+		redisLog.ErrorContext(ctx, "Failed to look up pending request", "key", key, "error", err)
 		return err
 	}
 
-	log.Printf("[Redis] Key exists check result: %d (0=not found, 1=found)", exists)
-
-	if exists == 0 {
-		log.Printf("[Redis] ERROR: No pending request found for key: %s", key)
-
-		// Debug: List all keys matching pattern
-		pattern := "Sync#*"
-		keys, _ := storage.RedisClient.Keys(ctx, pattern).Result()
-		log.Printf("[Redis] DEBUG: All pending keys in Redis (%d total):", len(keys))
-		for _, k := range keys {
-			log.Printf("[Redis] DEBUG:   - %s", k)
-		}
-
+	if err == redis.Nil {
+		redisLog.DebugContext(ctx, "No pending request found", "key", key)
 		return fmt.Errorf("no pending request found")
 	}
 
-	log.Printf("[Redis] ✓ Found pending request")
+	// Link the webhook span back to the waiting request
+	var metadata map[string]string
+	if err := json.Unmarshal(pending, &metadata); err == nil {
+		tracing.LinkTo(ctx, metadata["trace_parent"], attribute.String("link.type", "pending_request"))
+	}
+	response.TraceParent = tracing.Serialize(ctx)
 
 	// Marshal response
 	data, err := json.Marshal(response)
 	if err != nil {
-		log.Printf("[Redis] ERROR: Failed to marshal response: %v", err)
+		redisLog.ErrorContext(ctx, "Failed to marshal callback response", "error", err)
 		return err
 	}
 
 	// Publish to callback channel
 	channel := cm.makeCallbackChannel(subRoute, transactionID, messageID)
-	log.Printf("[Redis] Publishing to channel: %s", channel)
-
 	numSubscribers, err := storage.RedisClient.Publish(ctx, channel, data).Result()
 	if err != nil {
-		log.Printf("[Redis] ERROR: Failed to publish to channel: %v", err)
+		redisLog.ErrorContext(ctx, "Failed to publish callback", "channel", channel, "error", err)
 		return err
 	}
 
-	log.Printf("[Redis] ✓ Published to %d subscriber(s)", numSubscribers)
+	redisLog.DebugContext(ctx, "Published callback", "channel", channel, "subscribers", numSubscribers)
 
 	// Delete pending request from Redis
 	err = storage.RedisClient.Del(ctx, key).Err()
 	if err != nil {
-		log.Printf("[Redis] ERROR: Failed to delete pending key: %v", err)
+		redisLog.ErrorContext(ctx, "Failed to delete pending request", "key", key, "error", err)
 		return err
 	}
 
-	log.Printf("[Redis] ✓ Deleted pending request key")
 	return nil
 }
 
@@ -177,14 +258,78 @@ func (cm *CallbackManager) RemovePendingRequest(subRoute, transactionID, message
 	return storage.RedisClient.Del(ctx, key).Err()
 }
 
+// ListPendingRequests returns the requests waiting for a callback across all instances and tenants
+// When transactionID is not empty only that transaction's requests are returned.
+func ListPendingRequests(ctx context.Context, transactionID string) ([]PendingRequest, error) {
+	pending := []PendingRequest{}
+	err := storage.ScanKeys(ctx, "Sync#*", func(key string) error {
+		data, err := storage.RedisClient.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			// Delivered or expired since the scan saw it
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var metadata map[string]string
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			redisLog.DebugContext(ctx, "Skipping unreadable pending request", "key", key, "error", err)
+			return nil
+		}
+		if transactionID != "" && metadata["transaction_id"] != transactionID {
+			return nil
+		}
+		ttl, err := storage.RedisClient.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		pending = append(pending, PendingRequest{
+			Tenant:        metadata["tenant"],
+			Route:         metadata["route"],
+			TransactionID: metadata["transaction_id"],
+			MessageID:     metadata["message_id"],
+			CreatedAt:     metadata["created_at"],
+			TTLMs:         ttl.Milliseconds(),
+		})
+		return nil
+	})
+	return pending, err
+}
+
+// CancelPendingRequest stops a wait, wherever it runs, and removes its pending entry
+// The waiting client receives a cancellation response. It returns the number of waits
+// that received the cancellation, which is 0 if the wait had already ended.
+func (cm *CallbackManager) CancelPendingRequest(ctx context.Context, subRoute, transactionID, messageID string) (int64, error) {
+	data, err := json.Marshal(CallbackResponse{Cancelled: true})
+	if err != nil {
+		return 0, err
+	}
+	channel := cm.makeCallbackChannel(subRoute, transactionID, messageID)
+	receivers, err := storage.RedisClient.Publish(ctx, channel, data).Result()
+	if err != nil {
+		redisLog.ErrorContext(ctx, "Failed to publish cancellation", "channel", channel, "error", err)
+		return 0, err
+	}
+	if err := storage.RedisClient.Del(ctx, cm.makePendingKey(subRoute, transactionID, messageID)).Err(); err != nil {
+		return receivers, err
+	}
+	return receivers, nil
+}
+
 // makePendingKey creates a Redis key for pending requests
-// Format: Sync#{sub-route}#{message_id}#{transaction_id}
+// Format: Sync#{sub-route}#{message_id}#{transaction_id}, or Sync#{tenant}#{sub-route}#... for a named tenant
 func (cm *CallbackManager) makePendingKey(subRoute, transactionID, messageID string) string {
+	if cm.tenant != "" {
+		return fmt.Sprintf("Sync#%s#%s#%s#%s", cm.tenant, subRoute, messageID, transactionID)
+	}
 	return fmt.Sprintf("Sync#%s#%s#%s", subRoute, messageID, transactionID)
 }
 
 // makeCallbackChannel creates a Redis pub/sub channel name
-// Format: Callback#{sub-route}#{message_id}#{transaction_id}
+// Format: Callback#{sub-route}#{message_id}#{transaction_id}, or Callback#{tenant}#{sub-route}#... for a named tenant
 func (cm *CallbackManager) makeCallbackChannel(subRoute, transactionID, messageID string) string {
+	if cm.tenant != "" {
+		return fmt.Sprintf("Callback#%s#%s#%s#%s", cm.tenant, subRoute, messageID, transactionID)
+	}
 	return fmt.Sprintf("Callback#%s#%s#%s", subRoute, messageID, transactionID)
 }
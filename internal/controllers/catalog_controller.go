@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"BAP_Sandbox/internal/catalog"
+	"BAP_Sandbox/internal/lifecycle"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var catalogLog = logging.For("catalog")
+
+// HeaderCatalogWarning carries the items of a select forwarded in warn mode that were not in the catalog
+const HeaderCatalogWarning = "X-Catalog-Warning"
+
+// defaultCatalogPageSize is the page size used when the query does not set one
+const defaultCatalogPageSize = 20
+
+// CatalogController serves the catalogs cached from search and discover responses
+type CatalogController struct {
+	maxPageSize int
+}
+
+// NewCatalogController creates a new catalog controller
+func NewCatalogController(maxPageSize int) *CatalogController {
+	return &CatalogController{
+		maxPageSize: maxPageSize,
+	}
+}
+
+// Query pages, filters and sorts the catalog cached for a transaction
+// Query parameters: transaction_id (required), provider_id, item_id, location, q,
+// min_price, max_price, near ("lat,lon"), radius_km, sort (name, price or distance,
+// prefixed with - for descending order), page and page_size. Authenticated clients
+// only see the catalogs of their own transactions.
+func (cc *CatalogController) Query(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	if !catalog.Enabled() {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "catalog cache is disabled",
+		})
+	}
+
+	transactionID := c.Query("transaction_id")
+	if transactionID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "transaction_id is required",
+		})
+	}
+	query, err := cc.parseQuery(c)
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	tenant := tenants.Name(ctx)
+	fields, err := storage.GetTransaction(ctx, tenant, transactionID)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		catalogLog.ErrorContext(ctx, "Failed to read transaction", "transaction_id", transactionID, "error", err)
+		return catalogUnavailable(c)
+	}
+	var items []catalog.Item
+	found := false
+	if ownsTransaction(ctx, fields) {
+		if items, found, err = catalog.Find(ctx, tenant, transactionID, query); err != nil {
+			catalogLog.ErrorContext(ctx, "Failed to read catalog", "transaction_id", transactionID, "error", err)
+			return catalogUnavailable(c)
+		}
+	}
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "no catalog cached for this transaction",
+		})
+	}
+
+	page, total := query.Apply(items)
+	return c.JSON(fiber.Map{
+		"transaction_id": transactionID,
+		"total":          total,
+		"page":           query.Page,
+		"page_size":      query.PageSize,
+		"items":          page,
+	})
+}
+
+// parseQuery reads the catalog query from the query string
+func (cc *CatalogController) parseQuery(c *fiber.Ctx) (catalog.Query, error) {
+	query := catalog.Query{
+		ProviderID: c.Query("provider_id"),
+		ItemID:     c.Query("item_id"),
+		Location:   c.Query("location"),
+		Text:       c.Query("q"),
+		Near:       c.Query("near"),
+		Sort:       c.Query("sort"),
+		Page:       c.QueryInt("page", 1),
+		PageSize:   c.QueryInt("page_size", min(defaultCatalogPageSize, cc.maxPageSize)),
+	}
+	if query.PageSize > cc.maxPageSize {
+		return query, fmt.Errorf("page_size must not exceed %d", cc.maxPageSize)
+	}
+
+	for name, target := range map[string]**float64{"min_price": &query.MinPrice, "max_price": &query.MaxPrice} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return query, fmt.Errorf("%s must be a number", name)
+			}
+			*target = &parsed
+		}
+	}
+	if value := c.Query("radius_km"); value != "" {
+		radius, err := strconv.ParseFloat(value, 64)
+		if err != nil || radius <= 0 {
+			return query, fmt.Errorf("radius_km must be a positive number")
+		}
+		query.RadiusKm = radius
+	}
+	return query, nil
+}
+
+// catalogUnavailable writes the response used when Redis cannot be read
+func catalogUnavailable(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": "Catalog is unavailable",
+	})
+}
+
+// recordCatalog adds the items of a search response to the catalog of the transaction in ctx
+// Failures are only logged, so caching never affects the response.
+func recordCatalog(ctx context.Context, callback string, body []byte) {
+	t, ok := ctx.Value(timelineKey{}).(timeline)
+	if !ok || !catalog.Enabled() || (callback != "on_search" && callback != "on_discover") {
+		return
+	}
+	count, err := catalog.Store(ctx, t.tenant, t.transactionID, t.messageID, body)
+	if err != nil {
+		catalogLog.WarnContext(ctx, "Failed to cache catalog", "callback", callback, "error", err)
+		return
+	}
+	if count > 0 {
+		metrics.CatalogUpdates.WithLabelValues(routeLabel(callback)).Inc()
+	}
+}
+
+// checkCatalog checks that the items of the select request in ctx were returned by its search
+// It returns the violation, or nil if every item is known, validation is off or no catalog
+// is cached. Violations are logged, counted and added to the timeline.
+func checkCatalog(ctx context.Context, bppID string, body []byte) error {
+	t, ok := ctx.Value(timelineKey{}).(timeline)
+	validation := catalog.SelectValidation()
+	if !ok || !catalog.Enabled() || t.action != "select" || validation == lifecycle.EnforceOff {
+		return nil
+	}
+
+	missing, err := catalog.Missing(ctx, t.tenant, t.transactionID, bppID, body)
+	if err != nil {
+		catalogLog.WarnContext(ctx, "Failed to read catalog, skipping select validation", "error", err)
+		return nil
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(missing))
+	for _, ref := range missing {
+		if ref.ProviderID != "" {
+			names = append(names, ref.ProviderID+"/"+ref.ItemID)
+		} else {
+			names = append(names, ref.ItemID)
+		}
+	}
+	violation := fmt.Errorf("items not in the search results: %s", strings.Join(names, ", "))
+	catalogLog.WarnContext(ctx, "Select request for unknown items", "items", names, "validation", validation)
+	metrics.CatalogSelectMisses.WithLabelValues(validation).Inc()
+	recordEvent(ctx, storage.TransactionEvent{Type: storage.EventViolation, Detail: violation.Error()})
+	return violation
+}
@@ -1,39 +1,113 @@
 package controllers
 
 import (
+	"BAP_Sandbox/internal/auth"
+	"BAP_Sandbox/internal/capture"
+	"BAP_Sandbox/internal/catalog"
+	"BAP_Sandbox/internal/drain"
+	"BAP_Sandbox/internal/lifecycle"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"BAP_Sandbox/internal/tracing"
 	"BAP_Sandbox/internal/transformers"
+	"BAP_Sandbox/internal/upstream"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var forwardLog = logging.For("forward")
+
 // ForwardController handles forwarding requests to another service
+// The target ONIX is chosen per request from the tenant in the request context.
 type ForwardController struct {
-	targetURL  string
-	httpClient *http.Client
+	httpClient   *http.Client
+	callbackWait time.Duration
+	pendingTTL   time.Duration
+	syncRoutes   map[string]bool
+	lifecycle    string
+}
+
+// ForwardOptions configures the forward controller
+type ForwardOptions struct {
+	// Timeout bounds each sync request to ONIX
+	Timeout time.Duration
+	// CallbackWait is how long async requests wait for their callback
+	CallbackWait time.Duration
+	// PendingTTL is how long a pending request is kept in Redis
+	PendingTTL time.Duration
+	// SyncRoutes return the ONIX response directly instead of waiting for a callback
+	SyncRoutes []string
+	// Lifecycle is how requests invalid in the transaction's state are handled: off, warn or reject
+	Lifecycle string
 }
 
 // NewForwardController creates a new forward controller
-func NewForwardController() *ForwardController {
-	targetURL := os.Getenv("ONIX_URL")
-	if targetURL == "" {
-		targetURL = "http://localhost:8080" // Default fallback
+func NewForwardController(opts ForwardOptions) *ForwardController {
+	syncRoutes := make(map[string]bool, len(opts.SyncRoutes))
+	for _, route := range opts.SyncRoutes {
+		syncRoutes[route] = true
 	}
-
 	return &ForwardController{
-		targetURL: targetURL,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: opts.Timeout,
 		},
+		callbackWait: opts.CallbackWait,
+		pendingTTL:   opts.PendingTTL,
+		syncRoutes:   syncRoutes,
+		lifecycle:    opts.Lifecycle,
+	}
+}
+
+// asyncResult is the outcome of delivering an async request to ONIX
+type asyncResult struct {
+	StatusCode int
+	Body       []byte
+	Headers    http.Header
+	Err        error
+}
+
+// rejected reports whether ONIX refused the request, so no callback will follow
+// Transport errors, non-2xx responses and NACK acknowledgements are rejections
+func (r *asyncResult) rejected() bool {
+	if r.Err != nil || r.StatusCode < 200 || r.StatusCode >= 300 {
+		return true
+	}
+	var ack struct {
+		Message struct {
+			Ack struct {
+				Status string `json:"status"`
+			} `json:"ack"`
+		} `json:"message"`
+	}
+	return json.Unmarshal(r.Body, &ack) == nil && ack.Message.Ack.Status == "NACK"
+}
+
+// reason returns the rejection reason used in logs and metrics
+func (r *asyncResult) reason() string {
+	switch {
+	case r.Err != nil:
+		return "transport_error"
+	case r.StatusCode < 200 || r.StatusCode >= 300:
+		return "http_error"
+	default:
+		return "nack"
 	}
 }
 
@@ -42,34 +116,76 @@ type RequestContext struct {
 	Context struct {
 		TransactionID string `json:"transaction_id"`
 		MessageID     string `json:"message_id"`
+		BppID         string `json:"bpp_id"`
+		BapID         string `json:"bap_id"`
 	} `json:"context"`
 }
 
 // isSyncRoute checks if the route should use synchronous forwarding
 func (fc *ForwardController) isSyncRoute(subRoute string) bool {
-	return subRoute == "search" || subRoute == "discover"
+	return fc.syncRoutes[subRoute]
+}
+
+// transactionForbidden writes the Beckn NACK returned for another client's transaction
+func transactionForbidden(ctx context.Context, c *fiber.Ctx) error {
+	forwardLog.WarnContext(ctx, "Rejected request for another client's transaction")
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    "POLICY-ERROR",
+			"code":    "FORBIDDEN",
+			"message": "Transaction belongs to another client",
+		},
+	})
 }
 
 // ForwardRequest forwards the incoming request to the target service and waits for callback
 func (fc *ForwardController) ForwardRequest(c *fiber.Ctx) error {
-	// Get the sub-route from params
-	subRoute := c.Params("*")
+	start := time.Now()
+
+	// Get the sub-route from params without any tenant prefix
+	// (copied, since the async goroutine may outlive the request buffer)
+	subRoute := utils.CopyString(tenants.Action(c))
 	if subRoute == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "sub-route is required",
 		})
 	}
 
-	log.Printf("[Forward] ========== NEW REQUEST ==========")
-	log.Printf("[Forward] Received request for route: %s", subRoute)
+	// Start the request span, continuing any trace context sent by the client
+	ctx, span := tracing.Tracer().Start(tracing.ExtractFiber(c), "forward "+routeLabel(subRoute),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("beckn.action", routeLabel(subRoute))),
+	)
+	ctx = logging.WithFields(ctx, "route", subRoute)
+	c.SetUserContext(ctx)
+	tenant := tenants.FromContext(ctx)
+	if tenant.Name != "" {
+		span.SetAttributes(attribute.String("tenant.id", tenant.Name))
+	}
+
+	defer func() {
+		status := c.Response().StatusCode()
+		metrics.ObserveForward(routeLabel(subRoute), status, start)
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= fiber.StatusBadRequest {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		span.End()
+	}()
 
-	// Read the request body
-	body := c.Body()
+	// Copy the request body since the async delivery may outlive the handler
+	// (callback timeout, NACK or drain abort) while fasthttp reuses the request buffer
+	body := append([]byte(nil), c.Body()...)
 
 	// Parse request to extract context
 	var reqContext RequestContext
 	if err := json.Unmarshal(body, &reqContext); err != nil {
-		log.Printf("[Forward] ERROR: Invalid JSON body: %v", err)
+		forwardLog.WarnContext(ctx, "Invalid JSON body", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid JSON body",
 		})
@@ -78,49 +194,200 @@ func (fc *ForwardController) ForwardRequest(c *fiber.Ctx) error {
 	transactionID := reqContext.Context.TransactionID
 	messageID := reqContext.Context.MessageID
 
-	log.Printf("[Forward] TransactionID: %s", transactionID)
-	log.Printf("[Forward] MessageID: %s", messageID)
+	ctx = logging.WithFields(ctx,
+		"transaction_id", transactionID,
+		"message_id", messageID,
+		"bpp_id", reqContext.Context.BppID,
+	)
+	c.SetUserContext(ctx)
+
+	forwardLog.InfoContext(ctx, "Received request")
+	logging.DebugPayload(ctx, forwardLog, "Request payload", body)
 
 	if transactionID == "" || messageID == "" {
-		log.Printf("[Forward] ERROR: Missing transaction_id or message_id")
+		forwardLog.WarnContext(ctx, "Missing transaction_id or message_id")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "context.transaction_id and context.message_id are required",
 		})
 	}
 
-	// Check if this is a synchronous route (search/discover)
+	span.SetAttributes(
+		attribute.String("beckn.transaction_id", transactionID),
+		attribute.String("beckn.message_id", messageID),
+	)
+
+	// Capture the client request and, once handled, the response returned to it
+	if capture.Enabled() {
+		mode := "async"
+		if fc.isSyncRoute(subRoute) {
+			mode = "sync"
+		}
+		ctx = capture.WithRequest(ctx, capture.Record{
+			TransactionID: transactionID,
+			MessageID:     messageID,
+			Route:         subRoute,
+			Tenant:        tenant.Name,
+			Mode:          mode,
+		})
+		c.SetUserContext(ctx)
+		capture.Write(ctx, capture.Record{
+			Kind:    capture.KindClientRequest,
+			URL:     utils.CopyString(c.Path()),
+			Headers: capture.Headers(copyRequestHeaders(c)),
+		}, body)
+		defer func() {
+			capture.Write(ctx, capture.Record{
+				Kind:       capture.KindClientResponse,
+				Status:     c.Response().StatusCode(),
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			}, c.Response().Body())
+		}()
+	}
+
+	// Refuse transactions owned by another client before anything is checked or recorded
+	clientID := auth.ClientID(ctx)
+	if clientID != "" {
+		span.SetAttributes(attribute.String("client.id", clientID))
+	}
+	if err := storage.CheckTransactionOwner(ctx, tenant.Name, transactionID, clientID); err != nil {
+		if errors.Is(err, storage.ErrTransactionOwned) {
+			return transactionForbidden(ctx, c)
+		}
+		forwardLog.WarnContext(ctx, "Failed to read transaction owner", "error", err)
+	}
+	ctx = withTimeline(ctx, tenant.Name, transactionID, subRoute, messageID)
+	c.SetUserContext(ctx)
+	recordEvent(ctx, storage.TransactionEvent{Type: storage.EventRequest})
+	defer func() {
+		recordEvent(ctx, storage.TransactionEvent{
+			Type:       storage.EventResponse,
+			Status:     c.Response().StatusCode(),
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		})
+	}()
+
+	// Check the request against the transaction's lifecycle state
+	if violation := checkLifecycle(ctx, fc.lifecycle); violation != nil {
+		if fc.lifecycle == lifecycle.EnforceReject {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": fiber.Map{
+					"ack": fiber.Map{
+						"status": "NACK",
+					},
+				},
+				"error": fiber.Map{
+					"type":    "CONTEXT-ERROR",
+					"code":    "INVALID_STATE",
+					"message": violation.Error(),
+				},
+			})
+		}
+		c.Set(HeaderLifecycleWarning, violation.Error())
+	}
+
+	// Check that a select orders items returned by the transaction's search
+	if violation := checkCatalog(ctx, reqContext.Context.BppID, body); violation != nil {
+		if catalog.SelectValidation() == lifecycle.EnforceReject {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": fiber.Map{
+					"ack": fiber.Map{
+						"status": "NACK",
+					},
+				},
+				"error": fiber.Map{
+					"type":    "DOMAIN-ERROR",
+					"code":    "ITEM_NOT_FOUND",
+					"message": violation.Error(),
+				},
+			})
+		}
+		c.Set(HeaderCatalogWarning, violation.Error())
+	}
+
+	// Record the transaction now that the request will be forwarded; ownership is claimed
+	// atomically, so a client racing for the same transaction is still refused
+	txnFields := map[string]string{
+		"last_action":     subRoute,
+		"last_message_id": messageID,
+	}
+	if err := storage.TouchTransaction(ctx, tenant.Name, transactionID, clientID, txnFields); err != nil {
+		if errors.Is(err, storage.ErrTransactionOwned) {
+			return transactionForbidden(ctx, c)
+		}
+		forwardLog.WarnContext(ctx, "Failed to record transaction", "error", err)
+	}
+
+	// Check if this is a synchronous route (search/discover by default)
 	if fc.isSyncRoute(subRoute) {
-		log.Printf("[Forward] Route '%s' uses synchronous forwarding", subRoute)
-		return fc.forwardRequestSync(c, subRoute, body)
+		forwardLog.DebugContext(ctx, "Using synchronous forwarding")
+		return fc.forwardRequestSync(ctx, c, tenant, subRoute, body)
 	}
 
 	// For other routes, use the async webhook-based mechanism
-	log.Printf("[Forward] Route '%s' uses async webhook-based forwarding", subRoute)
+	forwardLog.DebugContext(ctx, "Using async webhook-based forwarding")
 
-	// Register pending request in Redis
-	log.Printf("[Forward] Registering pending request in Redis...")
-	callbackManager := GetCallbackManager()
-	if err := callbackManager.AddPendingRequest(subRoute, transactionID, messageID); err != nil {
-		log.Printf("[Forward] ERROR: Failed to register pending request: %v", err)
+	// Register pending request in Redis, in the tenant's namespace
+	callbackManager := GetCallbackManager().ForTenant(tenant.Name)
+	if err := callbackManager.AddPendingRequest(ctx, subRoute, transactionID, messageID, fc.pendingTTL); err != nil {
+		forwardLog.ErrorContext(ctx, "Failed to register pending request", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to register pending request",
 		})
 	}
 	defer func() {
-		log.Printf("[Forward] Cleaning up pending request from Redis")
+		forwardLog.DebugContext(ctx, "Cleaning up pending request")
 		callbackManager.RemovePendingRequest(subRoute, transactionID, messageID)
 	}()
 
-	// Forward the request asynchronously
-	log.Printf("[Forward] Forwarding request to: %s/%s", fc.targetURL, subRoute)
-	go fc.forwardRequestAsync(subRoute, body, c.GetReqHeaders())
+	// Forward the request asynchronously; if ONIX rejects it, stop waiting for the callback
+	// The wait is also cut short if the instance is still draining at its shutdown deadline
+	waitCtx, cancelWait := context.WithCancel(ctx)
+	defer cancelWait()
+	stopAbort := drain.OnAbort(cancelWait)
+	defer stopAbort()
+	rejection := make(chan *asyncResult, 1)
+
+	forwardLog.DebugContext(ctx, "Forwarding request", "upstream", tenant.Pool(subRoute).Name())
+	go func(headers http.Header) {
+		result := fc.forwardRequestAsync(ctx, tenant, subRoute, body, headers)
+		if result.rejected() {
+			rejection <- result
+			cancelWait()
+		}
+	}(upstreamRequestHeaders(c))
 
-	// Wait for callback response via Redis pub/sub or timeout
-	log.Printf("[Forward] Waiting for callback response (30s timeout)...")
-	response, err := callbackManager.WaitForCallback(subRoute, transactionID, messageID, 30*time.Second)
+	// Wait for callback response via Redis pub/sub, an ONIX rejection or timeout
+	response, err := callbackManager.WaitForCallback(waitCtx, subRoute, transactionID, messageID, fc.callbackWait)
 	if err != nil {
+		select {
+		case result := <-rejection:
+			return fc.upstreamRejectedResponse(ctx, c, tenant, subRoute, result)
+		default:
+		}
+
+		if drain.Aborted() {
+			forwardLog.WarnContext(ctx, "Stopped waiting for callback, instance is shutting down")
+			return drain.ShuttingDown(c)
+		}
+
+		if errors.Is(err, ErrWaitCancelled) {
+			forwardLog.WarnContext(ctx, "Wait for callback cancelled by an operator")
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": fiber.Map{
+					"ack": fiber.Map{
+						"status": "NACK",
+					},
+				},
+				"error": fiber.Map{
+					"type":    "INTERNAL-ERROR",
+					"code":    "WAIT_CANCELLED",
+					"message": "The wait for the callback was cancelled by an operator",
+				},
+			})
+		}
+
 		// Timeout - return static response
-		log.Printf("[Forward] ERROR: Request timed out after 30 seconds")
+		forwardLog.WarnContext(ctx, "Request timed out waiting for callback", "timeout", fc.callbackWait)
 		return c.Status(fiber.StatusRequestTimeout).JSON(fiber.Map{
 			"message": fiber.Map{
 				"ack": fiber.Map{
@@ -130,14 +397,15 @@ func (fc *ForwardController) ForwardRequest(c *fiber.Ctx) error {
 			"error": fiber.Map{
 				"type":    "TIMEOUT",
 				"code":    "REQUEST_TIMEOUT",
-				"message": "No response received within 30 seconds",
+				"message": fmt.Sprintf("No response received within %s", fc.callbackWait),
 			},
 		})
 	}
 
 	// Received callback response
-	log.Printf("[Forward] ✓ Received callback response, returning to client")
-	for key, value := range response.Headers {
+	forwardLog.InfoContext(ctx, "Returning callback response to client", "status", response.StatusCode)
+	// Filter again, since callbacks published by older instances carry every header
+	for key, value := range allowedCallbackHeaders(func(key string) string { return response.Headers[key] }) {
 		c.Set(key, value)
 	}
 	return c.Status(response.StatusCode).Send(response.Body)
@@ -145,91 +413,43 @@ func (fc *ForwardController) ForwardRequest(c *fiber.Ctx) error {
 
 // forwardRequestSync forwards the request synchronously and returns the direct response
 // Applies transformations for sync routes (search/discover)
-func (fc *ForwardController) forwardRequestSync(c *fiber.Ctx, subRoute string, body []byte) error {
-	// Get the transformer instance
-	transformer, err := transformers.GetTransformer()
+func (fc *ForwardController) forwardRequestSync(ctx context.Context, c *fiber.Ctx, tenant *tenants.Tenant, subRoute string, body []byte) error {
+	// Get the tenant's transformer instance
+	transformer, err := tenant.Transformer()
 	if err != nil {
-		log.Printf("[Forward] WARNING: Transformer not available: %v", err)
-		log.Printf("[Forward] Proceeding without transformation")
+		forwardLog.WarnContext(ctx, "Transformer not available, proceeding without transformation", "error", err)
 	}
 
 	// Apply forward transformation if transformer is available and has mapping for this route
 	requestBody := body
 	if transformer != nil && transformer.HasMapping(subRoute) {
-		log.Printf("[Forward] Applying forward transformation for route: %s", subRoute)
+		forwardLog.DebugContext(ctx, "Applying forward transformation")
 		transformedBody, err := transformer.TransformForward(subRoute, body)
 		if err != nil {
-			log.Printf("[Forward] ERROR: Forward transformation failed: %v", err)
-			errResponse := transformers.CreateMappingErrorResponse(subRoute, err)
-			return c.Status(fiber.StatusInternalServerError).JSON(errResponse)
+			forwardLog.ErrorContext(ctx, "Forward transformation failed", "error", err)
+			status, errResponse := transformers.CreateMappingErrorResponse(subRoute, err)
+			return c.Status(status).JSON(errResponse)
 		}
 		requestBody = transformedBody
-		log.Printf("[Forward] Forward transformation completed successfully")
 	} else {
-		log.Printf("[Forward] No transformation mapping found for route: %s, forwarding as-is", subRoute)
+		forwardLog.DebugContext(ctx, "No transformation mapping found, forwarding as-is")
 	}
 
-	// Construct the target URL
-	targetURL := fmt.Sprintf("%s/%s", fc.targetURL, subRoute)
-	log.Printf("[Forward] Making synchronous request to: %s", targetURL)
-
-	// Create a new request
-	req, err := http.NewRequest(http.MethodPost, targetURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		log.Printf("[Forward] ERROR: Failed to create request: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create request",
-		})
-	}
-
-	// Copy headers from original request
-	c.Request().Header.VisitAll(func(key, value []byte) {
-		keyStr := string(key)
-		if keyStr != "Host" {
-			req.Header.Add(keyStr, string(value))
+	// Repeats of a cached request are answered without calling ONIX
+	lookup := lookupResponse(ctx, c, tenant.Name, subRoute, requestBody)
+	status, header, respBody, cached := lookup.hit(ctx)
+	if !cached {
+		var done bool
+		if status, header, respBody, done, err = fc.fetchSync(ctx, c, tenant, subRoute, requestBody); done {
+			return err
 		}
-	})
-
-	// Ensure Content-Type is set
-	if req.Header.Get("Content-Type") == "" {
-		req.Header.Set("Content-Type", "application/json")
+		lookup.store(ctx, status, header, respBody)
 	}
-
-	// Make the synchronous request
-	resp, err := fc.httpClient.Do(req)
-	if err != nil {
-		log.Printf("[Forward] ERROR: Request failed: %v", err)
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": "Failed to forward request to ONIX service",
-		})
+	logging.DebugPayload(ctx, forwardLog, "Response payload", respBody)
+	if status >= 200 && status < 300 {
+		advanceLifecycle(ctx, "on_"+subRoute, respBody)
+		recordCatalog(ctx, "on_"+subRoute, respBody)
 	}
-	defer resp.Body.Close()
-
-	// Handle GZIP decompression if needed
-	var reader io.Reader = resp.Body
-	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Encoding")), "gzip") {
-		log.Printf("[Forward] Response is GZIP compressed, decompressing...")
-		gzipReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			log.Printf("[Forward] ERROR: Failed to create gzip reader: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to decompress response",
-			})
-		}
-		defer gzipReader.Close()
-		reader = gzipReader
-	}
-
-	// Read the response body
-	respBody, err := io.ReadAll(reader)
-	if err != nil {
-		log.Printf("[Forward] ERROR: Failed to read response body: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to read response",
-		})
-	}
-
-	log.Printf("[Forward] Received response (status: %d) from: %s", resp.StatusCode, targetURL)
 
 	// Apply reverse transformation to the response
 	// For search/discover, we need to transform the on_search/on_discover response
@@ -239,65 +459,415 @@ func (fc *ForwardController) forwardRequestSync(c *fiber.Ctx, subRoute string, b
 		callbackRoute := "on_" + subRoute
 
 		if transformer.HasMapping(callbackRoute) {
-			log.Printf("[Forward] Applying reverse transformation for callback route: %s", callbackRoute)
+			forwardLog.DebugContext(ctx, "Applying response transformation", "callback_route", callbackRoute)
 			transformedResponse, err := transformer.TransformForward(callbackRoute, respBody)
 			if err != nil {
-				log.Printf("[Forward] ERROR: Response transformation failed: %v", err)
-				errResponse := transformers.CreateMappingErrorResponse(callbackRoute, err)
-				return c.Status(fiber.StatusInternalServerError).JSON(errResponse)
+				forwardLog.ErrorContext(ctx, "Response transformation failed", "callback_route", callbackRoute, "error", err)
+				status, errResponse := transformers.CreateMappingErrorResponse(callbackRoute, err)
+				return c.Status(status).JSON(errResponse)
 			}
 			responseBody = transformedResponse
-			log.Printf("[Forward] Response transformation completed successfully")
 		} else {
-			log.Printf("[Forward] No transformation mapping found for callback route: %s, returning as-is", callbackRoute)
+			forwardLog.DebugContext(ctx, "No transformation mapping found for callback route, returning as-is", "callback_route", callbackRoute)
 		}
 	}
 
 	// Copy response headers (exclude Content-Encoding and Content-Length since we decompressed/transformed the body)
-	for key, values := range resp.Header {
+	for key, values := range header {
 		if key != "Host" && key != "Content-Encoding" && key != "Content-Length" {
 			for _, value := range values {
 				c.Set(key, value)
 			}
 		}
 	}
+	lookup.setHeaders(c)
 
-	log.Printf("[Forward] ✓ Returning transformed response to client")
-	return c.Status(resp.StatusCode).Send(responseBody)
+	forwardLog.InfoContext(ctx, "Returning response to client", "status", status)
+	return c.Status(status).Send(responseBody)
 }
 
-// forwardRequestAsync forwards the request to the target service asynchronously
-func (fc *ForwardController) forwardRequestAsync(subRoute string, body []byte, headers map[string][]string) {
-	// Construct the target URL
-	targetURL := fmt.Sprintf("%s/%s", fc.targetURL, subRoute)
+// fetchSync sends a sync request to ONIX and returns its response
+// When done is set, the error response has already been written to the client.
+func (fc *ForwardController) fetchSync(ctx context.Context, c *fiber.Ctx, tenant *tenants.Tenant, subRoute string, requestBody []byte) (status int, header http.Header, respBody []byte, done bool, err error) {
+	// Select the ONIX replicas serving this route
+	pool := tenant.Pool(subRoute)
+	forwardLog.DebugContext(ctx, "Making synchronous request", "upstream", pool.Name())
+
+	// Send the request, failing over between replicas and retrying transient failures within the client timeout
+	resp, err := fc.sendUpstream(ctx, tenant, subRoute, "sync", requestBody, upstreamRequestHeaders(c), fc.httpClient.Timeout)
+	if errors.Is(err, upstream.ErrCircuitOpen) {
+		return 0, nil, nil, true, fc.circuitOpenResponse(ctx, c, pool)
+	}
+	if err != nil {
+		forwardLog.ErrorContext(ctx, "Request to ONIX failed", "upstream", pool.Name(), "error", err)
+		recordUpstream(ctx, requestBody, nil, nil, err)
+		return 0, nil, nil, true, c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to forward request to ONIX service",
+		})
+	}
+	defer resp.Body.Close()
 
-	// Create a new request
-	req, err := http.NewRequest(http.MethodPost, targetURL, bytes.NewBuffer(body))
+	// Read the response body, decompressing GZIP if needed
+	respBody, err = readResponseBody(resp)
 	if err != nil {
-		return
+		forwardLog.ErrorContext(ctx, "Failed to read response body", "error", err)
+		return 0, nil, nil, true, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read response",
+		})
 	}
 
-	// Copy headers from original request
-	for key, values := range headers {
-		if key != "Host" {
-			for _, value := range values {
-				req.Header.Add(key, value)
+	forwardLog.InfoContext(ctx, "Received response from ONIX", "status", resp.StatusCode, "target_url", resp.Request.URL.String())
+	recordUpstream(ctx, requestBody, resp, respBody, nil)
+	return resp.StatusCode, resp.Header, respBody, false, nil
+}
+
+// forwardRequestAsync forwards the request to the target service and returns the ONIX acknowledgement
+func (fc *ForwardController) forwardRequestAsync(ctx context.Context, tenant *tenants.Tenant, subRoute string, body []byte, headers http.Header) *asyncResult {
+	// Send the request, failing over between replicas and retrying transient failures within the callback wait
+	resp, err := fc.sendUpstream(ctx, tenant, subRoute, "async", body, headers, fc.callbackWait)
+	if err != nil {
+		forwardLog.WarnContext(ctx, "Async request to ONIX failed", "upstream", tenant.Pool(subRoute).Name(), "error", err)
+		recordUpstream(ctx, body, nil, nil, err)
+		return &asyncResult{Err: err}
+	}
+	defer resp.Body.Close()
+
+	// Read the acknowledgement
+	respBody, err := readResponseBody(resp)
+	if err != nil {
+		forwardLog.WarnContext(ctx, "Failed to read ONIX acknowledgement", "status", resp.StatusCode, "error", err)
+	}
+	forwardLog.DebugContext(ctx, "Async request delivered to ONIX", "status", resp.StatusCode, "target_url", resp.Request.URL.String())
+	logging.DebugPayload(ctx, forwardLog, "Acknowledgement payload", respBody)
+	recordUpstream(ctx, body, resp, respBody, nil)
+
+	return &asyncResult{
+		StatusCode: resp.StatusCode,
+		Body:       respBody,
+		Headers:    resp.Header,
+	}
+}
+
+// sendUpstream posts body to the tenant's ONIX, retrying transient failures according to the route's retry policy
+// Each attempt tries the route's replicas in balancing order, failing over to the next replica
+// on failures the retry policy considers safe to resend. Every request passes through the
+// replica's circuit breaker and resends the same body, so the message_id is reused. The
+// deadline bounds the time spent across all attempts.
+func (fc *ForwardController) sendUpstream(ctx context.Context, tenant *tenants.Tenant, subRoute, mode string, body []byte, headers http.Header, deadline time.Duration) (*http.Response, error) {
+	policy := upstream.RetryPolicyFor(subRoute)
+	if policy.Deadline > 0 && policy.Deadline < deadline {
+		deadline = policy.Deadline
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, deadline)
+	pool := tenant.Pool(subRoute)
+	headers = headers.Clone()
+	tenant.ApplyHeaders(headers)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := fc.attemptPool(sendCtx, pool, policy, subRoute, mode, body, headers, attempt)
+		status := statusOf(resp)
+
+		retry := attempt < policy.MaxAttempts && policy.ShouldRetry(status, err)
+		backoff := policy.Backoff(attempt)
+		if expiry, ok := sendCtx.Deadline(); retry && ok && time.Until(expiry) < backoff {
+			forwardLog.DebugContext(ctx, "Retry deadline reached", "attempt", attempt, "deadline", deadline)
+			retry = false
+		}
+		if !retry {
+			if err != nil {
+				cancel()
+				return nil, err
 			}
+			// Keep the deadline context alive until the caller has read the body
+			resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		metrics.UpstreamRetries.WithLabelValues(routeLabel(subRoute), mode).Inc()
+		forwardLog.WarnContext(ctx, "Retrying request to ONIX",
+			"attempt", attempt+1, "max_attempts", policy.MaxAttempts, "backoff", backoff, "status", status, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-sendCtx.Done():
+			cancel()
+			return nil, sendCtx.Err()
 		}
 	}
+}
+
+// attemptPool makes one attempt against the pool, trying its replicas in balancing order
+// The request moves to the next replica when the circuit is open or the retry policy
+// allows the outcome to be resent; otherwise, or on the last replica, the outcome is returned.
+func (fc *ForwardController) attemptPool(ctx context.Context, pool *upstream.Pool, policy upstream.RetryPolicy, subRoute, mode string, body []byte, headers http.Header, attempt int) (*http.Response, error) {
+	candidates := pool.Candidates()
+	for i, endpoint := range candidates {
+		resp, err := fc.attemptUpstream(ctx, endpoint, subRoute, mode, body, headers, attempt)
+		status := statusOf(resp)
+
+		circuitOpen := errors.Is(err, upstream.ErrCircuitOpen)
+		if i == len(candidates)-1 || ctx.Err() != nil || !(circuitOpen || policy.ShouldRetry(status, err)) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		metrics.UpstreamFailovers.WithLabelValues(routeLabel(subRoute), mode).Inc()
+		if circuitOpen {
+			forwardLog.DebugContext(ctx, "ONIX replica circuit open, trying next replica", "endpoint", endpoint.URL())
+		} else {
+			forwardLog.WarnContext(ctx, "ONIX replica failed, failing over to next replica",
+				"endpoint", endpoint.URL(), "next", candidates[i+1].URL(), "status", status, "error", err)
+		}
+	}
+	return nil, fmt.Errorf("upstream pool %s has no endpoints", pool.Name())
+}
+
+// attemptUpstream makes a single request to an ONIX replica and records it on the breaker, a span and metrics
+func (fc *ForwardController) attemptUpstream(ctx context.Context, endpoint *upstream.Endpoint, subRoute, mode string, body []byte, headers http.Header, attempt int) (*http.Response, error) {
+	// Fail fast while the replica's circuit is open
+	done, err := endpoint.Breaker().Allow()
+	if err != nil {
+		return nil, err
+	}
+	end := endpoint.Begin()
+	defer end()
+	targetURL := fmt.Sprintf("%s/%s", endpoint.URL(), subRoute)
+
+	// Start the upstream span
+	ctx, span := fc.startUpstreamSpan(ctx, subRoute, mode, targetURL)
+	span.SetAttributes(attribute.Int("http.request.resend_count", attempt-1))
+	defer span.End()
+
+	// Create a new request with the headers from the original request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
+	if err != nil {
+		done(false)
+		return nil, err
+	}
+	req.Header = headers.Clone()
 
 	// Ensure Content-Type is set
 	if req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
-	// Make the request (ignore errors in async mode)
+	// Propagate W3C trace context to ONIX
+	tracing.InjectHTTP(ctx, req.Header)
+
+	// Make the request
+	upstreamStart := time.Now()
 	resp, err := fc.httpClient.Do(req)
+	endUpstreamSpan(span, resp, err)
+	done(!upstream.IsFailure(statusOf(resp), err))
+	metrics.ObserveUpstream(routeLabel(subRoute), mode, statusOf(resp), upstreamStart)
+	return resp, err
+}
+
+// upstreamRejectedResponse returns the ONIX rejection to the client instead of waiting for a callback
+// NACK and error responses are passed through; transport errors become a Beckn NACK
+func (fc *ForwardController) upstreamRejectedResponse(ctx context.Context, c *fiber.Ctx, tenant *tenants.Tenant, subRoute string, result *asyncResult) error {
+	if errors.Is(result.Err, upstream.ErrCircuitOpen) {
+		return fc.circuitOpenResponse(ctx, c, tenant.Pool(subRoute))
+	}
+
+	reason := result.reason()
+	metrics.UpstreamRejections.WithLabelValues(routeLabel(subRoute), reason).Inc()
+	trace.SpanFromContext(ctx).AddEvent("upstream_rejected", trace.WithAttributes(attribute.String("reason", reason)))
+
+	if result.Err != nil {
+		forwardLog.WarnContext(ctx, "ONIX unreachable, returning error without waiting for callback", "reason", reason, "error", result.Err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"message": fiber.Map{
+				"ack": fiber.Map{
+					"status": "NACK",
+				},
+			},
+			"error": fiber.Map{
+				"type":    "INTERNAL-ERROR",
+				"code":    "UPSTREAM_UNREACHABLE",
+				"message": "Failed to forward request to ONIX service",
+			},
+		})
+	}
+
+	forwardLog.WarnContext(ctx, "ONIX rejected request, returning without waiting for callback", "reason", reason, "status", result.StatusCode)
+	for key, values := range result.Headers {
+		if key != "Host" && key != "Content-Encoding" && key != "Content-Length" {
+			for _, value := range values {
+				c.Set(key, value)
+			}
+		}
+	}
+	return c.Status(result.StatusCode).Send(result.Body)
+}
+
+// recordUpstream records a request sent to ONIX and the response or error it produced
+// The outcome is added to the transaction timeline and, when enabled, to the capture.
+func recordUpstream(ctx context.Context, body []byte, resp *http.Response, respBody []byte, err error) {
+	event := storage.TransactionEvent{Type: storage.EventOnix, Status: statusOf(resp)}
+	if err != nil {
+		event.Detail = err.Error()
+	}
+	recordEvent(ctx, event)
+
+	if !capture.Enabled() {
+		return
+	}
+	request := capture.Record{Kind: capture.KindOnixRequest}
+	response := capture.Record{Kind: capture.KindOnixResponse}
+	if resp != nil {
+		request.URL = resp.Request.URL.String()
+		response.URL = request.URL
+		response.Status = resp.StatusCode
+	}
 	if err != nil {
+		response.Error = err.Error()
+	}
+	capture.Write(ctx, request, body)
+	capture.Write(ctx, response, respBody)
+}
+
+type timelineKey struct{}
+
+// timeline identifies the transaction and request whose events are recorded
+type timeline struct {
+	tenant        string
+	transactionID string
+	action        string
+	messageID     string
+}
+
+// withTimeline returns a context whose events are recorded on the tenant's transaction timeline
+func withTimeline(ctx context.Context, tenant, transactionID, action, messageID string) context.Context {
+	return context.WithValue(ctx, timelineKey{}, timeline{tenant: tenant, transactionID: transactionID, action: action, messageID: messageID})
+}
+
+// recordEvent adds an event to the timeline of the transaction in ctx
+// The action and message ID default to those of the request. Failures are only logged.
+func recordEvent(ctx context.Context, event storage.TransactionEvent) {
+	t, ok := ctx.Value(timelineKey{}).(timeline)
+	if !ok {
 		return
 	}
-	defer resp.Body.Close()
+	if event.Action == "" {
+		event.Action = t.action
+	}
+	if event.MessageID == "" {
+		event.MessageID = t.messageID
+	}
+	if err := storage.AppendTransactionEvent(ctx, t.tenant, t.transactionID, event); err != nil {
+		forwardLog.WarnContext(ctx, "Failed to record transaction event", "type", event.Type, "error", err)
+	}
+}
+
+// copyRequestHeaders copies the client request headers
+// The values are copied since the upstream request may outlive the Fiber request buffer
+func copyRequestHeaders(c *fiber.Ctx) http.Header {
+	headers := http.Header{}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		keyStr := string(key)
+		if keyStr != "Host" {
+			headers.Add(keyStr, string(value))
+		}
+	})
+	return headers
+}
 
-	// Read and discard the response body
-	io.ReadAll(resp.Body)
+// upstreamRequestHeaders copies the client request headers for forwarding to ONIX
+// The client's credentials are removed; tenants that need upstream credentials set
+// them through their ONIX headers.
+func upstreamRequestHeaders(c *fiber.Ctx) http.Header {
+	headers := copyRequestHeaders(c)
+	for _, header := range auth.CredentialHeaders() {
+		headers.Del(header)
+	}
+	return headers
+}
+
+// cancelOnClose releases a context once the response body it guards is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// readResponseBody reads an ONIX response body, decompressing GZIP if needed
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	var reader io.Reader = resp.Body
+	if strings.Contains(strings.ToLower(resp.Header.Get("Content-Encoding")), "gzip") {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	return io.ReadAll(reader)
+}
+
+// circuitOpenResponse returns the Beckn error sent while the circuit breakers of every ONIX replica are open
+func (fc *ForwardController) circuitOpenResponse(ctx context.Context, c *fiber.Ctx, pool *upstream.Pool) error {
+	retryAfter := pool.RetryAfter()
+	forwardLog.WarnContext(ctx, "ONIX circuit open, rejecting request", "upstream", pool.Name(), "retry_after", retryAfter)
+	trace.SpanFromContext(ctx).AddEvent("circuit_open")
+
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter.Round(time.Second).Seconds())))
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    "INTERNAL-ERROR",
+			"code":    "UPSTREAM_UNAVAILABLE",
+			"message": "ONIX service is unavailable, please retry later",
+		},
+	})
+}
+
+// statusOf returns the response status code, or 0 if there is no response
+func statusOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// startUpstreamSpan starts a client span for a request to ONIX
+func (fc *ForwardController) startUpstreamSpan(ctx context.Context, subRoute, mode, targetURL string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "onix "+routeLabel(subRoute),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("beckn.action", routeLabel(subRoute)),
+			attribute.String("forward.mode", mode),
+			attribute.String("url.full", targetURL),
+		),
+	)
+}
+
+// endUpstreamSpan records the outcome of an ONIX request on its span
+func endUpstreamSpan(span trace.Span, resp *http.Response, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", resp.StatusCode))
+	}
 }
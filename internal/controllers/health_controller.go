@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"BAP_Sandbox/internal/drain"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"BAP_Sandbox/internal/transformers"
+	"BAP_Sandbox/internal/upstream"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var healthLog = logging.For("health")
+
+// Dependency check statuses
+const (
+	CheckStatusUp   = "up"
+	CheckStatusDown = "down"
+)
+
+// CheckResult is the outcome of a single dependency check
+type CheckResult struct {
+	Status    string                 `json:"status"`
+	LatencyMs float64                `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	// Informational results are reported without affecting readiness
+	Informational bool `json:"informational,omitempty"`
+}
+
+// HealthCheck checks a single dependency
+type HealthCheck func(ctx context.Context) CheckResult
+
+// HealthController serves liveness and readiness probes
+type HealthController struct {
+	checks map[string]HealthCheck
+	// informational checks are reported but do not affect readiness
+	informational map[string]bool
+	timeout       time.Duration
+}
+
+// HealthOptions configures the readiness checks
+type HealthOptions struct {
+	// Upstreams maps check names to the ONIX replicas to probe, one per tenant
+	Upstreams       map[string][]string
+	OnixProbePath   string
+	MaxPendingWaits int
+	Timeout         time.Duration
+}
+
+// NewHealthController creates a health controller with the standard dependency checks
+func NewHealthController(opts HealthOptions) *HealthController {
+	httpClient := &http.Client{Timeout: opts.Timeout}
+
+	hc := &HealthController{
+		checks:        map[string]HealthCheck{},
+		informational: map[string]bool{},
+		timeout:       opts.Timeout,
+	}
+	hc.AddCheck("redis", checkRedis)
+	hc.AddCheck("draining", checkDraining)
+	for name, onixURLs := range opts.Upstreams {
+		probeURLs := make([]string, len(onixURLs))
+		for i, onixURL := range onixURLs {
+			probeURLs[i] = strings.TrimRight(onixURL, "/") + "/" + strings.TrimLeft(opts.OnixProbePath, "/")
+		}
+		hc.AddInfoCheck(name, checkReplicas(httpClient, probeURLs))
+	}
+	hc.AddCheck("transformer", checkTransformer)
+	hc.AddCheck("pending_waits", checkPendingWaits(opts.MaxPendingWaits))
+	hc.AddInfoCheck("circuit_breakers", checkCircuitBreakers)
+	return hc
+}
+
+// AddCheck registers a named readiness check
+func (hc *HealthController) AddCheck(name string, check HealthCheck) {
+	hc.checks[name] = check
+}
+
+// AddInfoCheck registers a named check that is reported without affecting readiness
+// ONIX is shared by every instance, so an outage must not take them all out of the
+// load balancer; requests then fail fast with a Beckn error instead.
+func (hc *HealthController) AddInfoCheck(name string, check HealthCheck) {
+	hc.checks[name] = check
+	hc.informational[name] = true
+}
+
+// Live reports that the process is running
+func (hc *HealthController) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": "ok",
+	})
+}
+
+// Ready runs all dependency checks and reports per-dependency status and latency
+func (hc *HealthController) Ready(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.UserContext(), hc.timeout)
+	defer cancel()
+
+	results := make(map[string]CheckResult, len(hc.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range hc.checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			result := check(ctx)
+			result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+			result.Informational = hc.informational[name]
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	ready, degraded := true, false
+	for name, result := range results {
+		switch {
+		case result.Status == CheckStatusUp:
+		case result.Informational:
+			degraded = true
+			healthLog.WarnContext(ctx, "Upstream check failed", "check", name, "error", result.Error)
+		default:
+			ready = false
+			healthLog.WarnContext(ctx, "Readiness check failed", "check", name, "error", result.Error)
+		}
+	}
+
+	status := "ready"
+	httpStatus := fiber.StatusOK
+	switch {
+	case !ready:
+		status = "not_ready"
+		httpStatus = fiber.StatusServiceUnavailable
+	case degraded:
+		status = "degraded"
+	}
+
+	return c.Status(httpStatus).JSON(fiber.Map{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// checkDraining reports the instance as not ready once shutdown has started
+// so load balancers stop sending it new requests.
+func checkDraining(ctx context.Context) CheckResult {
+	if drain.Draining() {
+		return CheckResult{
+			Status:  CheckStatusDown,
+			Error:   "instance is shutting down",
+			Details: map[string]interface{}{"in_flight": drain.InFlight()},
+		}
+	}
+	return CheckResult{Status: CheckStatusUp}
+}
+
+// checkRedis pings Redis
+func checkRedis(ctx context.Context) CheckResult {
+	if storage.RedisClient == nil {
+		return CheckResult{Status: CheckStatusDown, Error: "redis client not initialized"}
+	}
+	if err := storage.RedisClient.Ping(ctx).Err(); err != nil {
+		return CheckResult{Status: CheckStatusDown, Error: err.Error()}
+	}
+	return CheckResult{Status: CheckStatusUp}
+}
+
+// checkHTTP probes an HTTP endpoint; any response below 500 counts as reachable
+func checkHTTP(client *http.Client, url string) HealthCheck {
+	return func(ctx context.Context) CheckResult {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return CheckResult{Status: CheckStatusDown, Error: err.Error()}
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return CheckResult{Status: CheckStatusDown, Error: err.Error()}
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+
+		details := map[string]interface{}{"url": url, "status_code": resp.StatusCode}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return CheckResult{Status: CheckStatusDown, Error: fmt.Sprintf("probe returned HTTP %d", resp.StatusCode), Details: details}
+		}
+		return CheckResult{Status: CheckStatusUp, Details: details}
+	}
+}
+
+// checkReplicas probes every replica of an upstream; it is up while any replica is reachable
+func checkReplicas(client *http.Client, urls []string) HealthCheck {
+	if len(urls) == 1 {
+		return checkHTTP(client, urls[0])
+	}
+	return func(ctx context.Context) CheckResult {
+		results := make([]CheckResult, len(urls))
+		var wg sync.WaitGroup
+		for i, url := range urls {
+			wg.Add(1)
+			go func(i int, url string) {
+				defer wg.Done()
+				results[i] = checkHTTP(client, url)(ctx)
+			}(i, url)
+		}
+		wg.Wait()
+
+		details := map[string]interface{}{}
+		up := 0
+		for i, result := range results {
+			replica := map[string]interface{}{"status": result.Status}
+			if result.Error != "" {
+				replica["error"] = result.Error
+			}
+			if code, ok := result.Details["status_code"]; ok {
+				replica["status_code"] = code
+			}
+			details[urls[i]] = replica
+			if result.Status == CheckStatusUp {
+				up++
+			}
+		}
+		details["replicas_up"] = up
+		if up == 0 {
+			return CheckResult{Status: CheckStatusDown, Error: "no replica reachable", Details: details}
+		}
+		return CheckResult{Status: CheckStatusUp, Details: details}
+	}
+}
+
+// checkTransformer verifies that the mappings were loaded
+func checkTransformer(ctx context.Context) CheckResult {
+	if _, err := transformers.GetTransformer(); err != nil || !transformers.IsInitialized() {
+		message := "transformer not initialized"
+		if err != nil {
+			message = err.Error()
+		}
+		return CheckResult{Status: CheckStatusDown, Error: message}
+	}
+	return CheckResult{Status: CheckStatusUp}
+}
+
+// checkPendingWaits reports whether this instance can accept more waiting requests
+func checkPendingWaits(maxPendingWaits int) HealthCheck {
+	return func(ctx context.Context) CheckResult {
+		current := PendingWaitCount()
+		details := map[string]interface{}{"current": current, "max": maxPendingWaits}
+		if maxPendingWaits > 0 && current >= int64(maxPendingWaits) {
+			return CheckResult{Status: CheckStatusDown, Error: "pending waits saturated", Details: details}
+		}
+		return CheckResult{Status: CheckStatusUp, Details: details}
+	}
+}
+
+// checkCircuitBreakers reports the upstream circuit breaker states
+// It is down when every replica serving a tenant or route has an open circuit.
+func checkCircuitBreakers(ctx context.Context) CheckResult {
+	details := map[string]interface{}{}
+	for _, status := range upstream.Breakers() {
+		details[status.Name] = status
+	}
+
+	var unavailable []string
+	for _, tenant := range tenants.All() {
+		for _, pool := range tenant.Pools() {
+			open := 0
+			for _, endpoint := range pool.Endpoints() {
+				if endpoint.Breaker().Status().State == upstream.StateOpen.String() {
+					open++
+				}
+			}
+			if open == len(pool.Endpoints()) && !slices.Contains(unavailable, pool.Name()) {
+				unavailable = append(unavailable, pool.Name())
+			}
+		}
+	}
+	if len(unavailable) > 0 {
+		return CheckResult{Status: CheckStatusDown, Error: "circuit open for " + strings.Join(unavailable, ", "), Details: details}
+	}
+	return CheckResult{Status: CheckStatusUp, Details: details}
+}
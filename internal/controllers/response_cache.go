@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"BAP_Sandbox/internal/cache"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/storage"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var cacheLog = logging.For("cache")
+
+// responseLookup is the response cache state of a sync request
+type responseLookup struct {
+	key       string
+	ttl       time.Duration
+	directive cache.Directive
+	// result is HIT, MISS or BYPASS; empty when the request is not cacheable
+	result string
+	// entry is the cached response served on a hit
+	entry *cache.Entry
+}
+
+// lookupResponse looks up the cached ONIX response to a sync request
+// Requests are only cacheable on routes with a TTL and when they carry a transaction,
+// since a cached body is rewritten with the IDs of the request it answers.
+func lookupResponse(ctx context.Context, c *fiber.Ctx, tenant, subRoute string, body []byte) *responseLookup {
+	lookup := &responseLookup{ttl: cache.TTLFor(subRoute)}
+	if _, ok := ctx.Value(timelineKey{}).(timeline); !ok || lookup.ttl <= 0 {
+		return lookup
+	}
+	key, err := cache.Key(tenant, subRoute, body)
+	if err != nil {
+		cacheLog.DebugContext(ctx, "Request body is not cacheable", "error", err)
+		return lookup
+	}
+	lookup.key = key
+	lookup.directive = cache.DirectiveFor(func(key string) string { return c.Get(key) })
+
+	if !lookup.directive.Lookup {
+		lookup.result = cache.Bypass
+	} else if lookup.entry, err = cache.Get(ctx, key); err != nil {
+		cacheLog.WarnContext(ctx, "Failed to read response cache", "error", err)
+		lookup.result = cache.Miss
+	} else if lookup.entry != nil {
+		lookup.result = cache.Hit
+	} else {
+		lookup.result = cache.Miss
+	}
+	metrics.ResponseCacheRequests.WithLabelValues(routeLabel(subRoute), strings.ToLower(lookup.result)).Inc()
+	return lookup
+}
+
+// hit returns the cached response rewritten for the request in ctx, or false on a miss
+// The caller handles it like a fresh ONIX response, so a hit advances the transaction's
+// lifecycle state and fills the catalog cache as the original response did.
+func (l *responseLookup) hit(ctx context.Context) (status int, header http.Header, body []byte, ok bool) {
+	if l.entry == nil {
+		return 0, nil, nil, false
+	}
+	t := ctx.Value(timelineKey{}).(timeline)
+	header = l.entry.Header()
+	cacheLog.InfoContext(ctx, "Serving response from cache", "age", l.entry.Age().Round(time.Second))
+	recordEvent(ctx, storage.TransactionEvent{
+		Type:   storage.EventCache,
+		Status: l.entry.Status,
+		Detail: "served from response cache, age " + l.entry.Age().Round(time.Second).String(),
+	})
+	return l.entry.Status, header, cache.Rewrite(l.entry.Body, t.transactionID, t.messageID, time.Now()), true
+}
+
+// store caches a successful ONIX response unless the request asked for no-store
+// Error statuses and NACK acknowledgements are never cached. Failures are only logged.
+func (l *responseLookup) store(ctx context.Context, status int, header http.Header, body []byte) {
+	if l.key == "" || !l.directive.Store || status < 200 || status >= 300 {
+		return
+	}
+	var ack struct {
+		Message struct {
+			Ack struct {
+				Status string `json:"status"`
+			} `json:"ack"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(body, &ack) != nil || ack.Message.Ack.Status == "NACK" {
+		return
+	}
+	entry, err := cache.Put(ctx, l.key, status, header, body, l.ttl)
+	if err != nil {
+		cacheLog.WarnContext(ctx, "Failed to cache response", "error", err)
+		return
+	}
+	l.entry = entry
+}
+
+// setHeaders reports the cache result and freshness of the response to the client
+func (l *responseLookup) setHeaders(c *fiber.Ctx) {
+	if l.result == "" {
+		return
+	}
+	c.Set(cache.HeaderCache, l.result)
+	if l.entry == nil {
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return
+	}
+	c.Set(fiber.HeaderCacheControl, "private, max-age="+strconv.Itoa(int(l.entry.Remaining().Seconds())))
+	c.Set(fiber.HeaderAge, strconv.Itoa(int(l.entry.Age().Seconds())))
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"BAP_Sandbox/internal/auth"
+	"BAP_Sandbox/internal/lifecycle"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+var lifecycleLog = logging.For("lifecycle")
+
+// HeaderLifecycleWarning carries the lifecycle violation of a request forwarded in warn mode
+const HeaderLifecycleWarning = "X-Lifecycle-Warning"
+
+// TransactionController lets clients query the lifecycle state of their transactions
+type TransactionController struct {
+	enforcement string
+}
+
+// NewTransactionController creates a new transaction controller
+func NewTransactionController(enforcement string) *TransactionController {
+	return &TransactionController{
+		enforcement: enforcement,
+	}
+}
+
+// GetState returns a transaction's lifecycle state and the actions that are valid in it
+// Authenticated clients only see their own transactions; others are reported as not found.
+func (tc *TransactionController) GetState(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	transactionID := utils.CopyString(c.Params("transaction_id"))
+
+	fields, err := storage.GetTransaction(ctx, tenants.Name(ctx), transactionID)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		lifecycleLog.ErrorContext(ctx, "Failed to read transaction", "transaction_id", transactionID, "error", err)
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Transaction state is unavailable",
+		})
+	}
+	if !ownsTransaction(ctx, fields) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transaction not found",
+		})
+	}
+
+	state := lifecycle.State(fields["state"])
+	return c.JSON(fiber.Map{
+		"transaction_id":   transactionID,
+		"state":            state.String(),
+		"terminal":         state.Terminal(),
+		"state_updated_at": fields["state_updated_at"],
+		"last_action":      fields["last_action"],
+		"updated_at":       fields["updated_at"],
+		"allowed_actions":  lifecycle.AllowedActions(state),
+		"enforcement":      tc.enforcement,
+	})
+}
+
+// ownsTransaction reports whether the client in ctx may read a transaction record
+// Records that do not exist are never readable; unauthenticated requests read any record.
+func ownsTransaction(ctx context.Context, fields map[string]string) bool {
+	if fields == nil {
+		return false
+	}
+	clientID := auth.ClientID(ctx)
+	return clientID == "" || fields["client_id"] == "" || fields["client_id"] == clientID
+}
+
+// checkLifecycle checks the request in ctx against its transaction's lifecycle state
+// It returns the violation, or nil if the request is valid, enforcement is off or the
+// state cannot be read. Violations are logged, counted and added to the timeline.
+func checkLifecycle(ctx context.Context, enforcement string) error {
+	t, ok := ctx.Value(timelineKey{}).(timeline)
+	if !ok || enforcement == lifecycle.EnforceOff {
+		return nil
+	}
+
+	fields, err := storage.GetTransaction(ctx, t.tenant, t.transactionID)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		lifecycleLog.WarnContext(ctx, "Failed to read transaction state, skipping lifecycle check", "error", err)
+		return nil
+	}
+	state := lifecycle.State(fields["state"])
+	violation := lifecycle.Check(state, t.action)
+	if violation == nil {
+		return nil
+	}
+
+	lifecycleLog.WarnContext(ctx, "Request is not valid in the transaction state",
+		"state", state.String(), "enforcement", enforcement, "violation", violation)
+	metrics.LifecycleViolations.WithLabelValues(routeLabel(t.action), enforcement).Inc()
+	recordEvent(ctx, storage.TransactionEvent{Type: storage.EventViolation, Detail: violation.Error()})
+	return violation
+}
+
+// advanceLifecycle moves the transaction in ctx to the state reached by a callback or sync response
+// Failures are only logged; the state catches up with the next callback.
+func advanceLifecycle(ctx context.Context, callback string, body []byte) {
+	t, ok := ctx.Value(timelineKey{}).(timeline)
+	if !ok {
+		return
+	}
+
+	from, to, err := storage.UpdateTransactionState(ctx, t.tenant, t.transactionID, func(current string) string {
+		return string(lifecycle.Next(lifecycle.State(current), callback, body))
+	})
+	if err != nil {
+		lifecycleLog.WarnContext(ctx, "Failed to update transaction state", "callback", callback, "error", err)
+		return
+	}
+	if from == to {
+		return
+	}
+
+	previous, next := lifecycle.State(from), lifecycle.State(to)
+	lifecycleLog.InfoContext(ctx, "Transaction state changed", "from", previous.String(), "to", next.String())
+	metrics.LifecycleTransitions.WithLabelValues(next.String()).Inc()
+	recordEvent(ctx, storage.TransactionEvent{
+		Type:   storage.EventState,
+		Action: callback,
+		Detail: previous.String() + " -> " + next.String(),
+	})
+}
@@ -1,32 +1,200 @@
 package controllers
 
 import (
+	"BAP_Sandbox/internal/capture"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"BAP_Sandbox/internal/tracing"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var webhookLog = logging.For("webhook")
+
 // WebhookController handles incoming webhook callbacks
-type WebhookController struct{}
+type WebhookController struct {
+	hmacSecret   []byte
+	hmacHeader   string
+	bearerTokens []string
+	allowedCIDRs []netip.Prefix
+}
+
+// WebhookAuthOptions configures callback authentication
+// Every configured check must pass; leaving all options empty disables authentication.
+type WebhookAuthOptions struct {
+	// HMACSecret is the shared secret used to sign callback bodies with HMAC-SHA256
+	HMACSecret string
+	// HMACHeader carries the hex signature, optionally prefixed with "sha256="
+	HMACHeader string
+	// BearerTokens are accepted in the Authorization header
+	BearerTokens []string
+	// AllowedCIDRs restricts the source addresses allowed to send callbacks
+	AllowedCIDRs []string
+}
 
 // NewWebhookController creates a new webhook controller
-func NewWebhookController() *WebhookController {
-	return &WebhookController{}
+func NewWebhookController(opts WebhookAuthOptions) (*WebhookController, error) {
+	wc := &WebhookController{
+		hmacSecret:   []byte(opts.HMACSecret),
+		hmacHeader:   opts.HMACHeader,
+		bearerTokens: opts.BearerTokens,
+	}
+	if wc.hmacHeader == "" {
+		wc.hmacHeader = "X-Signature"
+	}
+
+	for _, cidr := range opts.AllowedCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			// Accept bare addresses as single-host prefixes
+			addr, addrErr := netip.ParseAddr(cidr)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid webhook CIDR %q: %w", cidr, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		wc.allowedCIDRs = append(wc.allowedCIDRs, prefix.Masked())
+	}
+
+	if len(wc.hmacSecret) == 0 && len(wc.bearerTokens) == 0 && len(wc.allowedCIDRs) == 0 {
+		webhookLog.Warn("Webhook authentication disabled, /webhook accepts callbacks from any sender")
+	} else {
+		webhookLog.Info("Webhook authentication enabled",
+			"hmac", len(wc.hmacSecret) > 0,
+			"bearer_tokens", len(wc.bearerTokens),
+			"allowed_cidrs", len(wc.allowedCIDRs),
+		)
+	}
+	return wc, nil
+}
+
+// Authorize rejects callbacks that fail the configured source, token or signature checks
+func (wc *WebhookController) Authorize(c *fiber.Ctx) error {
+	if len(wc.allowedCIDRs) > 0 && !wc.ipAllowed(c.IP()) {
+		return wc.rejectCallback(c, fiber.StatusForbidden, "ip_not_allowed", "Callback source is not allowed")
+	}
+
+	if len(wc.bearerTokens) > 0 {
+		token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || token == "" {
+			return wc.rejectCallback(c, fiber.StatusUnauthorized, "missing_token", "Authentication required")
+		}
+		if !wc.tokenValid(token) {
+			return wc.rejectCallback(c, fiber.StatusUnauthorized, "invalid_token", "Invalid credentials")
+		}
+	}
+
+	if len(wc.hmacSecret) > 0 {
+		signature := strings.TrimPrefix(c.Get(wc.hmacHeader), "sha256=")
+		if signature == "" {
+			return wc.rejectCallback(c, fiber.StatusUnauthorized, "missing_signature", "Callback signature required")
+		}
+		if !wc.signatureValid(c.Body(), signature) {
+			return wc.rejectCallback(c, fiber.StatusUnauthorized, "invalid_signature", "Invalid callback signature")
+		}
+	}
+
+	return c.Next()
+}
+
+// ipAllowed reports whether the address falls within an allowed CIDR
+func (wc *WebhookController) ipAllowed(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range wc.allowedCIDRs {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenValid compares the token against every configured token in constant time
+func (wc *WebhookController) tokenValid(token string) bool {
+	valid := false
+	for _, expected := range wc.bearerTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// signatureValid checks the hex HMAC-SHA256 of the body
+func (wc *WebhookController) signatureValid(body []byte, signature string) bool {
+	provided, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, wc.hmacSecret)
+	mac.Write(body)
+	return hmac.Equal(provided, mac.Sum(nil))
+}
+
+// rejectCallback returns a NACK for a callback that failed authentication
+func (wc *WebhookController) rejectCallback(c *fiber.Ctx, status int, reason, message string) error {
+	route := routeLabel(tenants.Action(c))
+	webhookLog.WarnContext(c.UserContext(), "Callback authentication failed", "route", route, "reason", reason, "ip", c.IP())
+	metrics.WebhookAuthFailures.WithLabelValues(route, reason).Inc()
+	metrics.WebhookResponses.WithLabelValues(route, "NACK").Inc()
+
+	code := "UNAUTHORIZED"
+	if status == fiber.StatusForbidden {
+		code = "FORBIDDEN"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    "POLICY-ERROR",
+			"code":    code,
+			"message": message,
+		},
+	})
 }
 
 // HandleWebhook processes incoming webhook callbacks
 func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
-	// Get the sub-route from params
-	subRoute := c.Params("*")
+	// Get the sub-route from params without any tenant prefix
+	subRoute := utils.CopyString(tenants.Action(c))
 	if subRoute == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "sub-route is required",
 		})
 	}
 
-	log.Printf("[Webhook] ========== CALLBACK RECEIVED ==========")
-	log.Printf("[Webhook] Callback route: %s", subRoute)
+	// Start the callback span, continuing any trace context sent by ONIX
+	ctx, span := tracing.Tracer().Start(tracing.ExtractFiber(c), "webhook "+routeLabel(subRoute),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("beckn.action", routeLabel(subRoute))),
+	)
+	defer span.End()
+	ctx = logging.WithFields(ctx, "route", subRoute)
+	c.SetUserContext(ctx)
+	if tenant := tenants.Name(ctx); tenant != "" {
+		span.SetAttributes(attribute.String("tenant.id", tenant))
+	}
 
 	// Read the request body
 	body := c.Body()
@@ -34,7 +202,7 @@ func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
 	// Parse request to extract context
 	var reqContext RequestContext
 	if err := json.Unmarshal(body, &reqContext); err != nil {
-		log.Printf("[Webhook] ERROR: Invalid JSON body: %v", err)
+		webhookLog.WarnContext(ctx, "Invalid JSON body", "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid JSON body",
 		})
@@ -43,16 +211,52 @@ func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
 	transactionID := reqContext.Context.TransactionID
 	messageID := reqContext.Context.MessageID
 
-	log.Printf("[Webhook] TransactionID: %s", transactionID)
-	log.Printf("[Webhook] MessageID: %s", messageID)
+	ctx = logging.WithFields(ctx,
+		"transaction_id", transactionID,
+		"message_id", messageID,
+		"bpp_id", reqContext.Context.BppID,
+	)
+	c.SetUserContext(ctx)
+
+	webhookLog.InfoContext(ctx, "Callback received")
+	logging.DebugPayload(ctx, webhookLog, "Callback payload", body)
+
+	// Capture the callback with the status returned to ONIX
+	if capture.Enabled() {
+		received := capture.Record{
+			Time:          time.Now().UTC(),
+			Kind:          capture.KindCallback,
+			TransactionID: transactionID,
+			MessageID:     messageID,
+			Route:         subRoute,
+			Tenant:        tenants.Name(ctx),
+			URL:           utils.CopyString(c.Path()),
+			Headers:       capture.Headers(copyRequestHeaders(c)),
+		}
+		defer func() {
+			received.Status = c.Response().StatusCode()
+			capture.Write(ctx, received, body)
+		}()
+	}
+
+	span.SetAttributes(
+		attribute.String("beckn.transaction_id", transactionID),
+		attribute.String("beckn.message_id", messageID),
+	)
 
 	if transactionID == "" || messageID == "" {
-		log.Printf("[Webhook] ERROR: Missing transaction_id or message_id")
+		webhookLog.WarnContext(ctx, "Missing transaction_id or message_id")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "context.transaction_id and context.message_id are required",
 		})
 	}
 
+	// Add the callback and the status returned to ONIX to the transaction timeline
+	ctx = withTimeline(ctx, tenants.Name(ctx), transactionID, subRoute, messageID)
+	defer func() {
+		recordEvent(ctx, storage.TransactionEvent{Type: storage.EventCallback, Status: c.Response().StatusCode()})
+	}()
+
 	// Validate that this is a valid callback route and get corresponding forward route
 	var forwardRoute string
 	isValidCallback := false
@@ -64,38 +268,36 @@ func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
 		}
 	}
 
-	log.Printf("[Webhook] Callback route '%s' mapped to forward route: '%s' (valid: %v)", subRoute, forwardRoute, isValidCallback)
-
 	if !isValidCallback {
-		log.Printf("[Webhook] ERROR: Invalid callback route: %s", subRoute)
+		webhookLog.WarnContext(ctx, "Invalid callback route")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid callback route: " + subRoute,
 		})
 	}
 
-	// Prepare the callback response
-	headers := make(map[string]string)
-	c.Request().Header.VisitAll(func(key, value []byte) {
-		keyStr := string(key)
-		if keyStr != "Host" {
-			headers[keyStr] = string(value)
-		}
-	})
+	// Follow the order lifecycle and cache catalogs before the waiting client is answered,
+	// so what it queries next already reflects this callback; late callbacks still count
+	advanceLifecycle(ctx, subRoute, body)
+	recordCatalog(ctx, subRoute, body)
 
+	// Prepare the callback response
 	callbackResponse := CallbackResponse{
 		Body:       body,
 		StatusCode: fiber.StatusOK,
-		Headers:    headers,
+		Headers: allowedCallbackHeaders(func(key string) string {
+			return utils.CopyString(c.Get(key))
+		}),
 	}
 
-	// Publish callback to Redis pub/sub using the forward route name
-	log.Printf("[Webhook] Publishing callback to Redis...")
-	callbackManager := GetCallbackManager()
-	err := callbackManager.PublishCallback(forwardRoute, transactionID, messageID, callbackResponse)
+	// Publish callback to Redis pub/sub using the forward route name, in the tenant's namespace
+	webhookLog.DebugContext(ctx, "Publishing callback", "forward_route", forwardRoute)
+	callbackManager := GetCallbackManager().ForTenant(tenants.Name(ctx))
+	err := callbackManager.PublishCallback(ctx, forwardRoute, transactionID, messageID, callbackResponse)
 
 	if err == nil {
 		// Successfully published to waiting request
-		log.Printf("[Webhook] ✓ Successfully published callback, returning ACK")
+		webhookLog.InfoContext(ctx, "Callback delivered, returning ACK")
+		metrics.WebhookResponses.WithLabelValues(routeLabel(subRoute), "ACK").Inc()
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"message": fiber.Map{
 				"ack": fiber.Map{
@@ -106,8 +308,10 @@ func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
 	}
 
 	// No pending request found - might have timed out or doesn't exist
-	log.Printf("[Webhook] ERROR: Failed to publish callback: %v", err)
-	log.Printf("[Webhook] Returning NACK to caller")
+	webhookLog.WarnContext(ctx, "Failed to publish callback, returning NACK", "error", err)
+	span.SetStatus(codes.Error, err.Error())
+	metrics.UnmatchedCallbacks.WithLabelValues(routeLabel(subRoute)).Inc()
+	metrics.WebhookResponses.WithLabelValues(routeLabel(subRoute), "NACK").Inc()
 	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 		"message": fiber.Map{
 			"ack": fiber.Map{
@@ -119,3 +323,19 @@ func (wc *WebhookController) HandleWebhook(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// callbackHeaders are the callback request headers passed on to the waiting client
+// Everything else stays with the adapter, notably the webhook's Authorization and
+// signature headers, Content-Length and hop-by-hop headers.
+var callbackHeaders = []string{fiber.HeaderContentType}
+
+// allowedCallbackHeaders returns the callback headers that may be passed on to the client
+func allowedCallbackHeaders(get func(key string) string) map[string]string {
+	headers := make(map[string]string, len(callbackHeaders))
+	for _, key := range callbackHeaders {
+		if value := get(key); value != "" {
+			headers[key] = value
+		}
+	}
+	return headers
+}
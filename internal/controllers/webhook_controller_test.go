@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sign returns the hex HMAC-SHA256 of body
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignatureValid(t *testing.T) {
+	wc, err := NewWebhookController(WebhookAuthOptions{HMACSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"context":{"action":"on_search"}}`
+
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"valid", body, sign("secret", body), true},
+		{"uppercase hex", body, strings.ToUpper(sign("secret", body)), true},
+		{"wrong secret", body, sign("other", body), false},
+		{"tampered body", body + " ", sign("secret", body), false},
+		{"not hex", body, "not-a-signature", false},
+		{"truncated", body, sign("secret", body)[:32], false},
+		{"empty", body, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wc.signatureValid([]byte(tt.body), tt.signature); got != tt.want {
+				t.Errorf("signatureValid = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	wc, err := NewWebhookController(WebhookAuthOptions{
+		AllowedCIDRs: []string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32", "172.16.5.9/16"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"192.168.1.10", true},
+		{"192.168.1.11", false},
+		{"172.16.200.1", true},
+		{"172.17.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::ffff:10.0.0.1", true},
+		{"not-an-ip", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := wc.ipAllowed(tt.ip); got != tt.want {
+			t.Errorf("ipAllowed(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestNewWebhookControllerRejectsInvalidCIDR(t *testing.T) {
+	if _, err := NewWebhookController(WebhookAuthOptions{AllowedCIDRs: []string{"10.0.0.0/33"}}); err == nil {
+		t.Fatal("NewWebhookController accepted an invalid CIDR")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	body := `{"context":{"action":"on_search"}}`
+
+	tests := []struct {
+		name    string
+		opts    WebhookAuthOptions
+		headers map[string]string
+		want    int
+	}{
+		{
+			name: "no authentication configured",
+			want: fiber.StatusOK,
+		},
+		{
+			name:    "valid signature",
+			opts:    WebhookAuthOptions{HMACSecret: "secret"},
+			headers: map[string]string{"X-Signature": "sha256=" + sign("secret", body)},
+			want:    fiber.StatusOK,
+		},
+		{
+			name:    "signature in a custom header",
+			opts:    WebhookAuthOptions{HMACSecret: "secret", HMACHeader: "X-Onix-Signature"},
+			headers: map[string]string{"X-Onix-Signature": sign("secret", body)},
+			want:    fiber.StatusOK,
+		},
+		{
+			name: "missing signature",
+			opts: WebhookAuthOptions{HMACSecret: "secret"},
+			want: fiber.StatusUnauthorized,
+		},
+		{
+			name:    "invalid signature",
+			opts:    WebhookAuthOptions{HMACSecret: "secret"},
+			headers: map[string]string{"X-Signature": sign("other", body)},
+			want:    fiber.StatusUnauthorized,
+		},
+		{
+			name:    "valid bearer token",
+			opts:    WebhookAuthOptions{BearerTokens: []string{"t1", "t2"}},
+			headers: map[string]string{"Authorization": "Bearer t2"},
+			want:    fiber.StatusOK,
+		},
+		{
+			name:    "invalid bearer token",
+			opts:    WebhookAuthOptions{BearerTokens: []string{"t1"}},
+			headers: map[string]string{"Authorization": "Bearer t3"},
+			want:    fiber.StatusUnauthorized,
+		},
+		{
+			name:    "token and signature both required",
+			opts:    WebhookAuthOptions{BearerTokens: []string{"t1"}, HMACSecret: "secret"},
+			headers: map[string]string{"Authorization": "Bearer t1"},
+			want:    fiber.StatusUnauthorized,
+		},
+		{
+			name: "source outside the allowed CIDRs",
+			opts: WebhookAuthOptions{AllowedCIDRs: []string{"10.0.0.0/8"}},
+			want: fiber.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wc, err := NewWebhookController(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app := fiber.New()
+			app.Post("/webhook/*", wc.Authorize, func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(fiber.MethodPost, "/webhook/on_search", strings.NewReader(body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
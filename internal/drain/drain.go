@@ -0,0 +1,126 @@
+package drain
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var drainLog = logging.For("drain")
+
+// retryAfterSeconds is the Retry-After sent to clients rejected while draining
+const retryAfterSeconds = "5"
+
+var (
+	draining atomic.Bool
+	inFlight atomic.Int64
+
+	// idle is signalled whenever the last in-flight request finishes
+	idle = make(chan struct{}, 1)
+	once sync.Once
+
+	// abort is cancelled when the drain deadline passes
+	abort, cancel = context.WithCancel(context.Background())
+)
+
+// Start stops accepting new /api requests
+// Requests already in flight, and webhook callbacks for them, are still served.
+func Start() {
+	once.Do(func() {
+		draining.Store(true)
+		metrics.Draining.Set(1)
+		drainLog.Info("Draining, new requests are rejected", "in_flight", InFlight())
+	})
+}
+
+// Draining reports whether the instance is shutting down
+func Draining() bool {
+	return draining.Load()
+}
+
+// InFlight returns the number of /api requests being handled
+func InFlight() int64 {
+	return inFlight.Load()
+}
+
+// Wait blocks until every in-flight request has finished or the timeout passes
+// It returns false if requests were still in flight at the deadline.
+func Wait(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for InFlight() > 0 {
+		select {
+		case <-idle:
+		case <-ticker.C:
+			drainLog.Debug("Waiting for in-flight requests", "in_flight", InFlight())
+		case <-deadline.C:
+			return InFlight() == 0
+		}
+	}
+	return true
+}
+
+// Abort cancels the callback waits still in flight after the drain deadline
+// Their clients receive a shutdown response instead of a broken connection.
+func Abort() {
+	drainLog.Warn("Drain deadline reached, aborting in-flight requests", "in_flight", InFlight())
+	cancel()
+}
+
+// OnAbort calls f when in-flight requests are aborted; the returned function stops it
+func OnAbort(f func()) (stop func() bool) {
+	return context.AfterFunc(abort, f)
+}
+
+// Aborted reports whether in-flight requests were aborted
+func Aborted() bool {
+	return abort.Err() != nil
+}
+
+// Middleware rejects new requests while draining and counts the requests it admits
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if Draining() {
+			drainLog.DebugContext(c.UserContext(), "Rejecting request while draining", "path", c.Path())
+			metrics.DrainRejections.Inc()
+			c.Set(fiber.HeaderConnection, "close")
+			c.Set(fiber.HeaderRetryAfter, retryAfterSeconds)
+			return ShuttingDown(c)
+		}
+
+		inFlight.Add(1)
+		defer func() {
+			if inFlight.Add(-1) == 0 {
+				select {
+				case idle <- struct{}{}:
+				default:
+				}
+			}
+		}()
+		return c.Next()
+	}
+}
+
+// ShuttingDown writes the Beckn NACK returned when the instance is shutting down
+func ShuttingDown(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    "INTERNAL-ERROR",
+			"code":    "SHUTTING_DOWN",
+			"message": "This instance is shutting down, please retry",
+		},
+	})
+}
@@ -0,0 +1,198 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// State is the Beckn order lifecycle state of a transaction
+type State string
+
+// Lifecycle states, in the order a transaction usually moves through them
+const (
+	None        State = ""
+	Searched    State = "searched"
+	Selected    State = "selected"
+	Initialized State = "initialized"
+	Confirmed   State = "confirmed"
+	InProgress  State = "in_progress"
+	Completed   State = "completed"
+	Cancelled   State = "cancelled"
+)
+
+// Enforcement modes for requests that are invalid in the current state
+const (
+	// EnforceOff forwards every request without checking it
+	EnforceOff = "off"
+	// EnforceWarn forwards invalid requests, logging and reporting the violation
+	EnforceWarn = "warn"
+	// EnforceReject refuses invalid requests with a Beckn NACK
+	EnforceReject = "reject"
+)
+
+// rank orders the states; callbacks never move a transaction back to a lower rank
+// once it has been confirmed.
+var rank = map[State]int{
+	None:        0,
+	Searched:    1,
+	Selected:    2,
+	Initialized: 3,
+	Confirmed:   4,
+	InProgress:  5,
+	Completed:   6,
+	Cancelled:   6,
+}
+
+// allowedFrom lists the states each request action is valid in
+// Actions that are not listed, such as support, are valid in every state.
+var allowedFrom = map[string][]State{
+	"search":   {None, Searched, Selected, Initialized},
+	"discover": {None, Searched, Selected, Initialized},
+	"select":   {None, Searched, Selected, Initialized},
+	"init":     {Selected, Initialized},
+	"confirm":  {Initialized},
+	"status":   {Confirmed, InProgress, Completed, Cancelled},
+	"track":    {Confirmed, InProgress},
+	"update":   {Confirmed, InProgress},
+	"cancel":   {Confirmed, InProgress},
+	"rating":   {InProgress, Completed},
+}
+
+// Terminal reports whether no further order changes are expected in the state
+func (s State) Terminal() bool {
+	return s == Completed || s == Cancelled
+}
+
+// String returns the state name, or "none" for a transaction without a state
+func (s State) String() string {
+	if s == None {
+		return "none"
+	}
+	return string(s)
+}
+
+// Check returns an error describing why action is not valid in state, or nil if it is
+func Check(state State, action string) error {
+	allowed, ok := allowedFrom[action]
+	if !ok || slices.Contains(allowed, state) {
+		return nil
+	}
+	if state == None {
+		return fmt.Errorf("%s is not allowed before the transaction has started", action)
+	}
+	return fmt.Errorf("%s is not allowed while the transaction is %s", action, state)
+}
+
+// AllowedActions returns the request actions that are valid in state, sorted
+// Actions valid in every state are not included.
+func AllowedActions(state State) []string {
+	actions := []string{}
+	for action, allowed := range allowedFrom {
+		if slices.Contains(allowed, state) {
+			actions = append(actions, action)
+		}
+	}
+	slices.Sort(actions)
+	return actions
+}
+
+// Next returns the state a successful callback or sync response moves the transaction to
+// Pre-order callbacks (on_search, on_select, on_init) only apply before confirmation.
+// Order callbacks follow the order status in the payload and never move the transaction
+// back, except that an order can be cancelled at any point before it completes. Callbacks
+// carrying an error leave the state unchanged.
+func Next(current State, callback string, body []byte) State {
+	var payload struct {
+		Message struct {
+			Order map[string]interface{} `json:"order"`
+		} `json:"message"`
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return current
+	}
+	if len(payload.Error) > 0 && string(payload.Error) != "null" {
+		return current
+	}
+
+	var target State
+	switch callback {
+	case "on_search", "on_discover":
+		target = Searched
+	case "on_select":
+		target = Selected
+	case "on_init":
+		target = Initialized
+	case "on_cancel":
+		target = Cancelled
+	case "on_confirm":
+		target = Confirmed
+		if status := orderState(payload.Message.Order); status != None {
+			target = status
+		}
+	case "on_status", "on_update", "on_track":
+		target = orderState(payload.Message.Order)
+	}
+
+	switch {
+	case target == None || current.Terminal():
+		return current
+	case rank[target] <= rank[Initialized]:
+		// Re-searching or re-selecting is allowed until the order is confirmed
+		if rank[current] > rank[Initialized] {
+			return current
+		}
+		return target
+	case target == Cancelled:
+		return target
+	case rank[target] < rank[current]:
+		return current
+	default:
+		return target
+	}
+}
+
+// orderStatusFields are the order fields carrying its status, across Beckn versions
+var orderStatusFields = []string{"status", "state", "beckn:orderStatus"}
+
+// orderState maps the status of a Beckn order to a lifecycle state
+// Statuses may be plain strings or descriptors with a code. It returns None for
+// missing or unrecognised statuses.
+func orderState(order map[string]interface{}) State {
+	for _, field := range orderStatusFields {
+		var status string
+		switch value := order[field].(type) {
+		case string:
+			status = value
+		case map[string]interface{}:
+			status, _ = value["code"].(string)
+			if status == "" {
+				if descriptor, ok := value["descriptor"].(map[string]interface{}); ok {
+					status, _ = descriptor["code"].(string)
+				}
+			}
+		}
+		if state := statusState(status); state != None {
+			return state
+		}
+	}
+	return None
+}
+
+// statusState maps a single order status to a lifecycle state
+func statusState(status string) State {
+	status = strings.NewReplacer("-", "_", " ", "_").Replace(strings.ToUpper(strings.TrimSpace(status)))
+	switch status {
+	case "CREATED", "ACCEPTED", "CONFIRMED":
+		return Confirmed
+	case "IN_PROGRESS", "INPROGRESS", "ACTIVE", "PACKED", "SHIPPED", "OUT_FOR_DELIVERY":
+		return InProgress
+	case "COMPLETED", "COMPLETE", "FULFILLED", "DELIVERED":
+		return Completed
+	case "CANCELLED", "CANCELED":
+		return Cancelled
+	}
+	return None
+}
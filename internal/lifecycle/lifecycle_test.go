@@ -0,0 +1,120 @@
+package lifecycle
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		state  State
+		action string
+		valid  bool
+	}{
+		{None, "search", true},
+		{None, "select", true},
+		{None, "init", false},
+		{None, "confirm", false},
+		{None, "status", false},
+		{Searched, "select", true},
+		{Searched, "confirm", false},
+		{Selected, "init", true},
+		{Initialized, "init", true},
+		{Initialized, "confirm", true},
+		{Initialized, "select", true},
+		{Confirmed, "confirm", false},
+		{Confirmed, "search", false},
+		{Confirmed, "status", true},
+		{Confirmed, "cancel", true},
+		{InProgress, "track", true},
+		{InProgress, "rating", true},
+		{Completed, "rating", true},
+		{Completed, "cancel", false},
+		{Cancelled, "status", true},
+		{Cancelled, "update", false},
+		{Cancelled, "support", true},
+		{None, "support", true},
+		{Completed, "unknown_action", true},
+	}
+	for _, tt := range tests {
+		err := Check(tt.state, tt.action)
+		if (err == nil) != tt.valid {
+			t.Errorf("Check(%s, %s) = %v, want valid %v", tt.state, tt.action, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckMessages(t *testing.T) {
+	tests := []struct {
+		state  State
+		action string
+		want   string
+	}{
+		{None, "confirm", "confirm is not allowed before the transaction has started"},
+		{Completed, "cancel", "cancel is not allowed while the transaction is completed"},
+	}
+	for _, tt := range tests {
+		if err := Check(tt.state, tt.action); err == nil || err.Error() != tt.want {
+			t.Errorf("Check(%s, %s) = %v, want %q", tt.state, tt.action, err, tt.want)
+		}
+	}
+}
+
+func TestAllowedActions(t *testing.T) {
+	tests := []struct {
+		state State
+		want  []string
+	}{
+		{None, []string{"discover", "search", "select"}},
+		{Initialized, []string{"confirm", "discover", "init", "search", "select"}},
+		{Confirmed, []string{"cancel", "status", "track", "update"}},
+		{Completed, []string{"rating", "status"}},
+	}
+	for _, tt := range tests {
+		if got := AllowedActions(tt.state); !slices.Equal(got, tt.want) {
+			t.Errorf("AllowedActions(%s) = %v, want %v", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	const empty = `{"message":{}}`
+
+	tests := []struct {
+		name     string
+		current  State
+		callback string
+		body     string
+		want     State
+	}{
+		{"search starts the transaction", None, "on_search", empty, Searched},
+		{"discover counts as search", None, "on_discover", empty, Searched},
+		{"select", Searched, "on_select", empty, Selected},
+		{"init", Selected, "on_init", empty, Initialized},
+		{"re-search before confirmation", Initialized, "on_search", empty, Searched},
+		{"search after confirmation is ignored", Confirmed, "on_search", empty, Confirmed},
+		{"confirm without a status", Initialized, "on_confirm", empty, Confirmed},
+		{"confirm with an active order", Initialized, "on_confirm", `{"message":{"order":{"status":"ACTIVE"}}}`, InProgress},
+		{"status moves forward", Confirmed, "on_status", `{"message":{"order":{"status":"Out-for-delivery"}}}`, InProgress},
+		{"status never moves back", InProgress, "on_status", `{"message":{"order":{"status":"CREATED"}}}`, InProgress},
+		{"status descriptor code", InProgress, "on_status", `{"message":{"order":{"state":{"descriptor":{"code":"COMPLETED"}}}}}`, Completed},
+		{"beckn 2.0 order status", Confirmed, "on_update", `{"message":{"order":{"beckn:orderStatus":"DELIVERED"}}}`, Completed},
+		{"unknown status is ignored", Confirmed, "on_status", `{"message":{"order":{"status":"WAITING"}}}`, Confirmed},
+		{"status cancels", InProgress, "on_status", `{"message":{"order":{"status":"CANCELLED"}}}`, Cancelled},
+		{"cancel", Confirmed, "on_cancel", empty, Cancelled},
+		{"completed is terminal", Completed, "on_cancel", empty, Completed},
+		{"cancelled is terminal", Cancelled, "on_status", `{"message":{"order":{"status":"ACTIVE"}}}`, Cancelled},
+		{"error leaves the state", Initialized, "on_confirm", `{"message":{},"error":{"code":"30001"}}`, Initialized},
+		{"null error is not an error", Initialized, "on_confirm", `{"message":{},"error":null}`, Confirmed},
+		{"invalid body leaves the state", Selected, "on_init", `not json`, Selected},
+		{"unmapped callback leaves the state", Confirmed, "on_support", empty, Confirmed},
+		{"rating leaves the state", Completed, "on_rating", empty, Completed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Next(tt.current, tt.callback, []byte(tt.body)); got != tt.want {
+				t.Errorf("Next(%s, %s) = %s, want %s", tt.current, tt.callback, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported output formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Options configures the global logging setup
+type Options struct {
+	Level        string
+	Format       string
+	ModuleLevels map[string]string
+	RedactPaths  []string
+	Output       io.Writer
+}
+
+// settings is the active logging configuration shared by all module loggers
+type settings struct {
+	handler      slog.Handler
+	defaultLevel slog.Level
+	moduleLevels map[string]slog.Level
+	redactor     *Redactor
+}
+
+var current atomic.Pointer[settings]
+
+func init() {
+	current.Store(&settings{
+		handler:      slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		defaultLevel: slog.LevelInfo,
+		moduleLevels: map[string]slog.Level{},
+		redactor:     NewRedactor(DefaultRedactPaths),
+	})
+}
+
+// Init configures the output format, levels and redaction rules
+// Loggers returned by For before Init pick up the new settings immediately.
+func Init(opts Options) error {
+	defaultLevel, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+
+	moduleLevels := make(map[string]slog.Level, len(opts.ModuleLevels))
+	for module, value := range opts.ModuleLevels {
+		level, err := ParseLevel(value)
+		if err != nil {
+			return fmt.Errorf("invalid log level for module %s: %w", module, err)
+		}
+		moduleLevels[module] = level
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+
+	// Level filtering happens per module, so the base handler accepts everything
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	switch opts.Format {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(output, handlerOpts)
+	case FormatText:
+		handler = slog.NewTextHandler(output, handlerOpts)
+	default:
+		return fmt.Errorf("unsupported log format: %s", opts.Format)
+	}
+
+	redactPaths := opts.RedactPaths
+	if redactPaths == nil {
+		redactPaths = DefaultRedactPaths
+	}
+
+	current.Store(&settings{
+		handler:      handler,
+		defaultLevel: defaultLevel,
+		moduleLevels: moduleLevels,
+		redactor:     NewRedactor(redactPaths),
+	})
+
+	// Route any remaining standard library log output through slog
+	slog.SetDefault(For("default"))
+	return nil
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) into a slog level
+func ParseLevel(value string) (slog.Level, error) {
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", value)
+	}
+	return level, nil
+}
+
+// For returns the logger for a module
+// Every record carries a "module" attribute and is filtered by the module's level.
+func For(module string) *slog.Logger {
+	return slog.New(&moduleHandler{module: module})
+}
+
+type contextKey struct{}
+
+// WithFields returns a context carrying per-request fields
+// Records logged with a *Context method and this context include the fields.
+func WithFields(ctx context.Context, args ...any) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]any)
+	fields := make([]any, 0, len(existing)+len(args))
+	fields = append(fields, existing...)
+	fields = append(fields, args...)
+	return context.WithValue(ctx, contextKey{}, fields)
+}
+
+// Payload returns a "payload" attribute holding the redacted JSON body
+func Payload(body []byte) slog.Attr {
+	return slog.Any("payload", current.Load().redactor.Redact(body))
+}
+
+// RedactPayload returns the JSON body with the configured redaction paths masked
+func RedactPayload(body []byte) json.RawMessage {
+	return current.Load().redactor.Redact(body)
+}
+
+// DebugPayload logs a redacted payload at debug level
+// Redaction is skipped entirely when debug logging is disabled for the logger.
+func DebugPayload(ctx context.Context, logger *slog.Logger, msg string, body []byte) {
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, msg, Payload(body))
+}
+
+// moduleHandler applies module levels and request fields on top of the active base handler
+type moduleHandler struct {
+	module string
+	ops    []func(slog.Handler) slog.Handler
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	s := current.Load()
+	minLevel, ok := s.moduleLevels[h.module]
+	if !ok {
+		minLevel = s.defaultLevel
+	}
+	return level >= minLevel
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := current.Load().handler.WithAttrs([]slog.Attr{slog.String("module", h.module)})
+
+	if fields, ok := ctx.Value(contextKey{}).([]any); ok {
+		record.Add(fields...)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+}
+
+// with returns a copy of the handler with an extra operation applied at handle time
+func (h *moduleHandler) with(op func(slog.Handler) slog.Handler) *moduleHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &moduleHandler{module: h.module, ops: append(ops, op)}
+}
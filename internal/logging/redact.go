@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RedactedValue replaces redacted fields in logged payloads
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactPaths lists the Beckn payload fields holding personal data
+// Paths are dot-separated; "*" matches every array element or object key.
+var DefaultRedactPaths = []string{
+	"message.order.billing",
+	"message.order.fulfillments.*.contact",
+	"message.order.fulfillments.*.customer",
+	"message.order.fulfillments.*.stops.*.contact",
+	"message.order.fulfillments.*.stops.*.person",
+	"message.order.fulfillments.*.stops.*.authorization",
+	"message.order.payments.*.params",
+	"message.order.payment.params",
+}
+
+// Redactor masks configured paths in JSON payloads before they are logged
+type Redactor struct {
+	paths [][]string
+}
+
+// NewRedactor creates a redactor for the given dot-separated paths
+func NewRedactor(paths []string) *Redactor {
+	r := &Redactor{}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		r.paths = append(r.paths, strings.Split(path, "."))
+	}
+	return r
+}
+
+// Redact returns a copy of the JSON body with all configured paths masked
+// Bodies that are not valid JSON are never logged verbatim. Numbers are kept as
+// written, so large IDs and amounts survive in captures that are replayed later.
+func (r *Redactor) Redact(body []byte) json.RawMessage {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil || decoder.Decode(new(interface{})) != io.EOF {
+		placeholder, _ := json.Marshal(fmt.Sprintf("<non-JSON payload, %d bytes>", len(body)))
+		return placeholder
+	}
+
+	for _, path := range r.paths {
+		data = redactPath(data, path)
+	}
+
+	redacted, err := json.Marshal(data)
+	if err != nil {
+		placeholder, _ := json.Marshal(fmt.Sprintf("<unencodable payload, %d bytes>", len(body)))
+		return placeholder
+	}
+	return redacted
+}
+
+// redactPath masks the value at path within data
+func redactPath(data interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return RedactedValue
+	}
+
+	segment, rest := path[0], path[1:]
+	switch node := data.(type) {
+	case map[string]interface{}:
+		if segment == "*" {
+			for key, value := range node {
+				node[key] = redactPath(value, rest)
+			}
+			return node
+		}
+		if value, ok := node[segment]; ok {
+			node[segment] = redactPath(value, rest)
+		}
+		return node
+	case []interface{}:
+		// Arrays are traversed element-wise whether or not the path uses "*"
+		if segment != "*" {
+			rest = path
+		}
+		for i, value := range node {
+			node[i] = redactPath(value, rest)
+		}
+		return node
+	default:
+		return data
+	}
+}
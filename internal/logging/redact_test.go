@@ -0,0 +1,44 @@
+package logging
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	redactor := NewRedactor([]string{"message.order.billing", "message.order.fulfillments.*.contact", " ", "message.items.price"})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "object path",
+			body: `{"message":{"order":{"id":"o1","billing":{"name":"A","phone":"9999"}}}}`,
+			want: `{"message":{"order":{"billing":"[REDACTED]","id":"o1"}}}`,
+		},
+		{
+			name: "wildcard over an array",
+			body: `{"message":{"order":{"fulfillments":[{"id":"f1","contact":{"phone":"1"}},{"id":"f2"}]}}}`,
+			want: `{"message":{"order":{"fulfillments":[{"contact":"[REDACTED]","id":"f1"},{"id":"f2"}]}}}`,
+		},
+		{
+			name: "arrays are traversed without a wildcard",
+			body: `{"message":{"items":[{"id":"i1","price":{"value":"10"}},{"id":"i2"}]}}`,
+			want: `{"message":{"items":[{"id":"i1","price":"[REDACTED]"},{"id":"i2"}]}}`,
+		},
+		{
+			name: "large numbers keep their precision",
+			body: `{"message":{"order":{"id":12345678901234567890,"amount":0.1000000000000000055511151231257827,"count":3}}}`,
+			want: `{"message":{"order":{"amount":0.1000000000000000055511151231257827,"count":3,"id":12345678901234567890}}}`,
+		},
+		{name: "missing path", body: `{"context":{"action":"search"}}`, want: `{"context":{"action":"search"}}`},
+		{name: "not JSON", body: `name=A`, want: `"\u003cnon-JSON payload, 6 bytes\u003e"`},
+		{name: "trailing data", body: `{"a":1} {"b":2}`, want: `"\u003cnon-JSON payload, 15 bytes\u003e"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(redactor.Redact([]byte(tt.body))); got != tt.want {
+				t.Errorf("Redact = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bap_adapter"
+
+var (
+	// ForwardedRequests counts requests received on /api per route and response status
+	ForwardedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "forwarded_requests_total",
+		Help:      "Requests received on /api by route and HTTP status returned to the client.",
+	}, []string{"route", "status"})
+
+	// ForwardDuration observes the total time spent handling /api requests
+	ForwardDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "forward_request_duration_seconds",
+		Help:      "End-to-end duration of /api requests by route and HTTP status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+
+	// CallbackWaitDuration observes how long async requests waited for their callback
+	CallbackWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "callback_wait_duration_seconds",
+		Help:      "Time spent waiting for a webhook callback by route and outcome.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 15, 20, 30},
+	}, []string{"route", "outcome"})
+
+	// CallbackTimeouts counts async requests that did not receive a callback in time
+	CallbackTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callback_timeouts_total",
+		Help:      "Async requests that timed out waiting for a callback.",
+	}, []string{"route"})
+
+	// PendingWaits tracks the number of requests currently waiting for a callback
+	PendingWaits = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_waits",
+		Help:      "Requests currently waiting for a webhook callback on this instance.",
+	})
+
+	// WebhookResponses counts ACK/NACK responses returned on /webhook
+	WebhookResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_responses_total",
+		Help:      "Webhook callbacks by route and ACK/NACK status.",
+	}, []string{"route", "ack"})
+
+	// WebhookAuthFailures counts callbacks rejected by webhook authentication
+	WebhookAuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_auth_failures_total",
+		Help:      "Webhook callbacks rejected by authentication by route and reason.",
+	}, []string{"route", "reason"})
+
+	// UnmatchedCallbacks counts callbacks for which no pending request was found
+	UnmatchedCallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unmatched_callbacks_total",
+		Help:      "Webhook callbacks that did not match a pending request.",
+	}, []string{"route"})
+
+	// TransformDuration observes JSONata transformation duration
+	TransformDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "transform_duration_seconds",
+		Help:      "JSONata transformation duration by route and direction.",
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"route", "direction"})
+
+	// TransformErrors counts failed transformations
+	TransformErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transform_errors_total",
+		Help:      "Failed JSONata transformations by route, direction and error kind.",
+	}, []string{"route", "direction", "kind"})
+
+	// UpstreamDuration observes ONIX request latency
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of requests to the ONIX upstream by route, mode and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "mode", "status"})
+
+	// UpstreamRetries counts retried requests to ONIX
+	UpstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Retries of requests to the ONIX upstream by route and mode.",
+	}, []string{"route", "mode"})
+
+	// UpstreamRejections counts async requests that ONIX rejected instead of acknowledging
+	UpstreamRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_rejections_total",
+		Help:      "Async requests rejected by ONIX by route and reason (nack, http_error, transport_error).",
+	}, []string{"route", "reason"})
+
+	// AuthFailures counts rejected /api requests
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Requests rejected by client authentication by method and reason (missing, invalid).",
+	}, []string{"method", "reason"})
+
+	// TenantRequests counts /api requests and callbacks by the tenant that served them
+	TenantRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tenant_requests_total",
+		Help:      "Requests and callbacks by tenant and how it was selected (path, header, bap_id, default).",
+	}, []string{"tenant", "selector"})
+
+	// TenantRejections counts requests rejected during tenant selection
+	TenantRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tenant_rejections_total",
+		Help:      "Requests rejected during tenant selection by reason (unknown_tenant, client_not_allowed).",
+	}, []string{"reason"})
+
+	// RateLimited counts requests rejected by the per-client limits
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by per-client limits by route and limit (rate, concurrency).",
+	}, []string{"route", "limit"})
+
+	// UpstreamFailovers counts requests moved to another ONIX endpoint after a failure
+	UpstreamFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_failovers_total",
+		Help:      "Requests failed over to another ONIX endpoint by route and mode.",
+	}, []string{"route", "mode"})
+
+	// UpstreamEndpointUp tracks the active health check result per ONIX endpoint
+	UpstreamEndpointUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upstream_endpoint_up",
+		Help:      "Whether the last active health check of an ONIX endpoint succeeded (1) or failed (0).",
+	}, []string{"endpoint"})
+
+	// Draining reports whether the instance is shutting down
+	Draining = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "draining",
+		Help:      "Whether the instance is draining before shutdown (1) or serving (0).",
+	})
+
+	// DrainRejections counts /api requests rejected because the instance is draining
+	DrainRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "drain_rejections_total",
+		Help:      "Requests on /api rejected while the instance was draining.",
+	})
+
+	// CaptureRecords counts records queued for the traffic capture file
+	CaptureRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "capture_records_total",
+		Help:      "Traffic capture records queued by kind.",
+	}, []string{"kind"})
+
+	// CaptureDropped counts capture records dropped because the writer fell behind
+	CaptureDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "capture_dropped_total",
+		Help:      "Traffic capture records dropped because the write queue was full.",
+	})
+
+	// BatchSize tracks the number of requests per /api/batch call
+	BatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "Requests per /api/batch call.",
+		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500},
+	})
+
+	// BatchItems counts batch items by route and outcome
+	BatchItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batch_items_total",
+		Help:      "Items forwarded through /api/batch by route and outcome (ok or failed).",
+	}, []string{"route", "outcome"})
+
+	// LifecycleTransitions counts transactions entering each lifecycle state
+	LifecycleTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lifecycle_transitions_total",
+		Help:      "Transaction lifecycle transitions by new state.",
+	}, []string{"state"})
+
+	// LifecycleViolations counts requests that are not valid in their transaction's state
+	LifecycleViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lifecycle_violations_total",
+		Help:      "Requests invalid in their transaction's lifecycle state by route and enforcement (warn or reject).",
+	}, []string{"route", "enforcement"})
+
+	// CatalogUpdates counts search responses cached in a transaction catalog
+	CatalogUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "catalog_updates_total",
+		Help:      "Search responses cached in a transaction catalog by callback route.",
+	}, []string{"route"})
+
+	// CatalogSelectMisses counts select requests for items not in the transaction catalog
+	CatalogSelectMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "catalog_select_misses_total",
+		Help:      "Select requests for items missing from the transaction catalog by validation (warn or reject).",
+	}, []string{"validation"})
+
+	// ResponseCacheRequests counts sync requests by response cache result
+	ResponseCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "response_cache_requests_total",
+		Help:      "Sync requests by route and response cache result (hit, miss or bypass).",
+	}, []string{"route", "result"})
+
+	// CircuitState tracks the circuit breaker state per upstream
+	CircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state per upstream (0 = closed, 1 = half-open, 2 = open).",
+	}, []string{"upstream"})
+
+	// CircuitTransitions counts circuit breaker state changes
+	CircuitTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_transitions_total",
+		Help:      "Circuit breaker state transitions by upstream and new state.",
+	}, []string{"upstream", "state"})
+
+	// CircuitRejections counts requests rejected without contacting the upstream
+	CircuitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_rejections_total",
+		Help:      "Requests rejected by an open circuit breaker by upstream.",
+	}, []string{"upstream"})
+)
+
+// ObserveForward records the outcome of an /api request
+func ObserveForward(route string, status int, start time.Time) {
+	statusLabel := strconv.Itoa(status)
+	ForwardedRequests.WithLabelValues(route, statusLabel).Inc()
+	ForwardDuration.WithLabelValues(route, statusLabel).Observe(time.Since(start).Seconds())
+}
+
+// ObserveUpstream records the latency of a request to ONIX
+// A status of 0 indicates a transport error
+func ObserveUpstream(route, mode string, status int, start time.Time) {
+	statusLabel := "error"
+	if status > 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+	UpstreamDuration.WithLabelValues(route, mode, statusLabel).Observe(time.Since(start).Seconds())
+}
+
+// Handler returns the Fiber handler serving the Prometheus metrics endpoint
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
@@ -0,0 +1,201 @@
+package ratelimit
+
+import (
+	"BAP_Sandbox/internal/auth"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/tenants"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket atomically refills and takes a token from a client's bucket
+// Returns {allowed, retry_after_ms, remaining_tokens}
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, retry_after, math.floor(tokens)}
+`)
+
+// acquireSlot atomically takes a concurrency slot if fewer than max are held
+// Expired slots left behind by crashed replicas are purged first.
+// Returns {acquired, in_flight}
+var acquireSlot = redis.NewScript(`
+local max = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local expires = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local count = redis.call('ZCARD', KEYS[1])
+if count >= max then
+	return {0, count}
+end
+
+redis.call('ZADD', KEYS[1], expires, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], expires - now)
+return {1, count + 1}
+`)
+
+// Middleware enforces the per-client rate limit and concurrency quota for the route in the "*" parameter
+// Limits and their state are kept separately for each tenant. Redis errors are logged and the request is let through, so a Redis outage does not block traffic.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p := current()
+		if !p.Enabled {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		tenant := tenants.Name(ctx)
+		route := utils.CopyString(tenants.Action(c))
+		limit := p.LimitFor(tenant, route)
+		if limit.Rate <= 0 && limit.MaxConcurrent <= 0 {
+			return c.Next()
+		}
+
+		// Tenants sharing Redis keep separate buckets and slots
+		scope := route
+		if tenant != "" {
+			scope = tenant + "#" + route
+		}
+
+		client := p.ClientID(c)
+		if auth.FromContext(ctx) == nil {
+			ctx = logging.WithFields(ctx, "client_id", client)
+			c.SetUserContext(ctx)
+		}
+
+		if limit.Rate > 0 {
+			allowed, retryAfter, remaining, err := takeToken(ctx, scope, client, limit)
+			switch {
+			case err != nil:
+				ratelimitLog.WarnContext(ctx, "Rate limit check failed, allowing request", "route", route, "error", err)
+			case !allowed:
+				ratelimitLog.WarnContext(ctx, "Rate limit exceeded", "route", route, "retry_after", retryAfter)
+				metrics.RateLimited.WithLabelValues(metricRoute(route), "rate").Inc()
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				c.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+				c.Set("X-RateLimit-Remaining", "0")
+				return tooManyRequests(c, "RATE_LIMIT_EXCEEDED", "Rate limit exceeded, please retry later")
+			default:
+				c.Set("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+				c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			}
+		}
+
+		if limit.MaxConcurrent > 0 {
+			release, acquired, err := acquire(ctx, scope, client, limit, p.Lease)
+			switch {
+			case err != nil:
+				ratelimitLog.WarnContext(ctx, "Concurrency check failed, allowing request", "route", route, "error", err)
+			case !acquired:
+				ratelimitLog.WarnContext(ctx, "Concurrency limit exceeded", "route", route, "max_concurrent", limit.MaxConcurrent)
+				metrics.RateLimited.WithLabelValues(metricRoute(route), "concurrency").Inc()
+				return tooManyRequests(c, "CONCURRENCY_LIMIT_EXCEEDED", "Too many requests in flight, please retry later")
+			default:
+				defer release()
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// takeToken takes a token from the client's bucket for a route scope ("{route}" or "{tenant}#{route}")
+func takeToken(ctx context.Context, scope, client string, limit Limit) (allowed bool, retryAfter time.Duration, remaining int, err error) {
+	key := fmt.Sprintf("RateLimit#%s#%s", scope, client)
+	result, err := tokenBucket.Run(ctx, storage.RedisClient, []string{key},
+		limit.Rate, limit.Burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, int(result[2]), nil
+}
+
+// acquire takes a concurrency slot for the client on a route scope ("{route}" or "{tenant}#{route}")
+// The returned release function frees the slot; the lease bounds how long it is held otherwise.
+func acquire(ctx context.Context, scope, client string, limit Limit, lease time.Duration) (release func(), acquired bool, err error) {
+	key := fmt.Sprintf("Concurrency#%s#%s", scope, client)
+	member := newSlotID()
+	now := time.Now()
+	result, err := acquireSlot.Run(ctx, storage.RedisClient, []string{key},
+		limit.MaxConcurrent, now.UnixMilli(), now.Add(lease).UnixMilli(), member).Int64Slice()
+	if err != nil {
+		return nil, false, err
+	}
+	if result[0] != 1 {
+		return nil, false, nil
+	}
+
+	return func() {
+		if err := storage.RedisClient.ZRem(context.WithoutCancel(ctx), key, member).Err(); err != nil {
+			ratelimitLog.WarnContext(ctx, "Failed to release concurrency slot", "key", key, "error", err)
+		}
+	}, true, nil
+}
+
+// tooManyRequests writes the Beckn NACK returned when a limit is exceeded
+func tooManyRequests(c *fiber.Ctx, code, message string) error {
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    "POLICY-ERROR",
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+// newSlotID returns a random identifier for a concurrency slot
+func newSlotID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// metricRoute bounds the route label to configured routes
+func metricRoute(route string) string {
+	p := current()
+	if _, ok := p.Routes[route]; ok {
+		return route
+	}
+	for _, overrides := range p.Tenants {
+		if _, ok := overrides.Routes[route]; ok {
+			return route
+		}
+	}
+	return "default"
+}
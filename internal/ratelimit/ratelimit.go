@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"BAP_Sandbox/internal/auth"
+	"BAP_Sandbox/internal/logging"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var ratelimitLog = logging.For("ratelimit")
+
+// Limit is the rate and concurrency quota applied to each client on a route
+type Limit struct {
+	// Rate is the number of requests per second refilled into the client's bucket; 0 disables rate limiting
+	Rate float64 `yaml:"rate"`
+	// Burst is the bucket size, i.e. the number of requests allowed at once; defaults to Rate
+	Burst int `yaml:"burst"`
+	// MaxConcurrent is the number of in-flight requests allowed per client; 0 disables the quota
+	MaxConcurrent int `yaml:"max_concurrent"`
+}
+
+// Identity configures how clients are identified
+// Authenticated clients are identified by their client ID. The API key and client
+// headers are only used when TrustHeaders is set; otherwise the remote IP is used.
+type Identity struct {
+	APIKeyHeader string `yaml:"api_key_header"`
+	ClientHeader string `yaml:"client_header"`
+	// TrustHeaders uses the unverified API key and client headers, which callers control;
+	// only enable it behind an authenticated proxy that sets them
+	TrustHeaders bool `yaml:"trust_headers"`
+}
+
+// TenantLimits overrides the limits for one tenant's requests
+type TenantLimits struct {
+	// Default replaces the global default for the tenant's routes without their own entry
+	Default *Limit           `yaml:"default"`
+	Routes  map[string]Limit `yaml:"routes"`
+}
+
+// Policies is the rate limit file format
+type Policies struct {
+	Enabled  bool             `yaml:"enabled"`
+	Identity Identity         `yaml:"identity"`
+	Default  Limit            `yaml:"default"`
+	Routes   map[string]Limit `yaml:"routes"`
+	// Tenants overrides the limits per tenant, keyed by tenant name
+	Tenants map[string]TenantLimits `yaml:"tenants"`
+	// Lease bounds how long a concurrency slot is held if a replica dies before releasing it
+	// It must outlast the longest request, so slots never expire while requests are in flight.
+	Lease time.Duration `yaml:"lease"`
+}
+
+// Options configures rate limiting
+type Options struct {
+	// Path is the rate limit file; a missing file leaves rate limiting disabled
+	Path string
+	// WaitTimeout is the longest a request waits for its callback, and so holds its concurrency slot
+	WaitTimeout time.Duration
+}
+
+// defaultLease returns the lease used when the file sets none: 1m, or twice the callback wait if longer
+func defaultLease(wait time.Duration) time.Duration {
+	return max(time.Minute, 2*wait)
+}
+
+var (
+	policies = defaultPolicies()
+	policyMu sync.RWMutex
+)
+
+// defaultPolicies returns the built-in policies used when no rate limit file exists
+// Limiting is disabled until a policy file enables it.
+func defaultPolicies() *Policies {
+	return &Policies{
+		Identity: Identity{
+			APIKeyHeader: "X-API-Key",
+			ClientHeader: "X-Client-ID",
+		},
+		Routes: map[string]Limit{},
+	}
+}
+
+// Init loads the rate limit policies from a YAML file
+// A missing file leaves rate limiting disabled. A lease that does not outlast the
+// callback wait is rejected, since slots would expire while requests are in flight.
+func Init(opts Options) error {
+	loaded := defaultPolicies()
+	path := opts.Path
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			ratelimitLog.Info("Rate limit file not found, rate limiting disabled", "path", path)
+		case err != nil:
+			return fmt.Errorf("failed to read rate limits: %w", err)
+		default:
+			decoder := yaml.NewDecoder(bytes.NewReader(data))
+			decoder.KnownFields(true)
+			if err := decoder.Decode(loaded); err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("failed to parse rate limits YAML: %w", err)
+			}
+			if loaded.Routes == nil {
+				loaded.Routes = map[string]Limit{}
+			}
+			if loaded.Lease < 0 {
+				return fmt.Errorf("invalid rate limits: lease must not be negative, got %s", loaded.Lease)
+			}
+			if loaded.Lease > 0 && loaded.Lease <= opts.WaitTimeout {
+				return fmt.Errorf("invalid rate limits: lease (%s) must exceed the callback wait timeout (%s)", loaded.Lease, opts.WaitTimeout)
+			}
+			ratelimitLog.Info("Loaded rate limits", "enabled", loaded.Enabled, "routes", len(loaded.Routes), "tenants", len(loaded.Tenants), "path", path)
+		}
+	}
+
+	if loaded.Lease == 0 {
+		loaded.Lease = defaultLease(opts.WaitTimeout)
+	}
+
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policies = loaded
+	return nil
+}
+
+// current returns the active policies
+func current() *Policies {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return policies
+}
+
+// LimitFor returns the limit for a tenant's route
+// The tenant's route and default limits take precedence over the global route and default limits.
+func (p *Policies) LimitFor(tenant, route string) Limit {
+	limit, ok := p.Routes[route]
+	if !ok {
+		limit = p.Default
+	}
+	if overrides, found := p.Tenants[tenant]; found {
+		if tenantLimit, ok := overrides.Routes[route]; ok {
+			limit = tenantLimit
+		} else if overrides.Default != nil {
+			limit = *overrides.Default
+		}
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(limit.Rate)
+		if limit.Burst < 1 {
+			limit.Burst = 1
+		}
+	}
+	return limit
+}
+
+// ClientID identifies the caller of a request
+// Authenticated clients are identified by their client ID. Otherwise the identity
+// headers are used when trusted, with API keys hashed so they never appear in Redis
+// keys or logs, and the remote IP when not.
+func (p *Policies) ClientID(c *fiber.Ctx) string {
+	if clientID := auth.ClientID(c.UserContext()); clientID != "" {
+		return "auth:" + clientID
+	}
+	if !p.Identity.TrustHeaders {
+		return "ip:" + c.IP()
+	}
+	if p.Identity.APIKeyHeader != "" {
+		if key := c.Get(p.Identity.APIKeyHeader); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			return "key:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	if p.Identity.ClientHeader != "" {
+		if client := c.Get(p.Identity.ClientHeader); client != "" {
+			return "client:" + client
+		}
+	}
+	return "ip:" + c.IP()
+}
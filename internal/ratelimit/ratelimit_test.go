@@ -0,0 +1,234 @@
+package ratelimit
+
+import (
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/storage/storagetest"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	type take struct {
+		atMs       int64
+		allowed    bool
+		retryAfter int64
+		remaining  int64
+	}
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		takes []take
+	}{
+		{
+			name:  "burst then reject",
+			rate:  1,
+			burst: 3,
+			takes: []take{
+				{0, true, 0, 2},
+				{0, true, 0, 1},
+				{0, true, 0, 0},
+				{0, false, 1000, 0},
+			},
+		},
+		{
+			name:  "refills at the rate",
+			rate:  2,
+			burst: 2,
+			takes: []take{
+				{0, true, 0, 1},
+				{0, true, 0, 0},
+				{0, false, 500, 0},
+				{250, false, 250, 0},
+				{500, true, 0, 0},
+				{1500, true, 0, 1},
+			},
+		},
+		{
+			name:  "refill is capped at the burst",
+			rate:  10,
+			burst: 2,
+			takes: []take{
+				{0, true, 0, 1},
+				{60000, true, 0, 1},
+				{60000, true, 0, 0},
+				{60000, false, 100, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storagetest.UseRedis(t)
+			ctx := context.Background()
+			start := time.Now().UnixMilli()
+			for i, tk := range tt.takes {
+				result, err := tokenBucket.Run(ctx, storage.RedisClient, []string{"RateLimit#test"},
+					tt.rate, tt.burst, start+tk.atMs).Int64Slice()
+				if err != nil {
+					t.Fatalf("take %d: %v", i, err)
+				}
+				got := take{tk.atMs, result[0] == 1, result[1], result[2]}
+				if got != tk {
+					t.Errorf("take %d: got %+v, want %+v", i, got, tk)
+				}
+			}
+		})
+	}
+}
+
+func TestTakeTokenSeparatesScopesAndClients(t *testing.T) {
+	storagetest.UseRedis(t)
+	ctx := context.Background()
+	limit := Limit{Rate: 0.001, Burst: 1}
+
+	tests := []struct {
+		scope, client string
+		allowed       bool
+	}{
+		{"search", "auth:a", true},
+		{"search", "auth:a", false},
+		{"search", "auth:b", true},
+		{"select", "auth:a", true},
+		{"acme#search", "auth:a", true},
+		{"acme#search", "auth:a", false},
+	}
+	for i, tt := range tests {
+		allowed, _, _, err := takeToken(ctx, tt.scope, tt.client, limit)
+		if err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+		if allowed != tt.allowed {
+			t.Errorf("take %d (%s, %s): allowed = %v, want %v", i, tt.scope, tt.client, allowed, tt.allowed)
+		}
+	}
+}
+
+func TestAcquireSlots(t *testing.T) {
+	storagetest.UseRedis(t)
+	ctx := context.Background()
+	limit := Limit{MaxConcurrent: 2}
+
+	first, acquired, err := acquire(ctx, "search", "auth:a", limit, time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("first slot: acquired = %v, err = %v", acquired, err)
+	}
+	if _, acquired, err := acquire(ctx, "search", "auth:a", limit, time.Minute); err != nil || !acquired {
+		t.Fatalf("second slot: acquired = %v, err = %v", acquired, err)
+	}
+	if _, acquired, err := acquire(ctx, "search", "auth:a", limit, time.Minute); err != nil || acquired {
+		t.Fatalf("third slot: acquired = %v, err = %v, want refused", acquired, err)
+	}
+	if _, acquired, err := acquire(ctx, "search", "auth:b", limit, time.Minute); err != nil || !acquired {
+		t.Fatalf("another client's slot: acquired = %v, err = %v", acquired, err)
+	}
+
+	first()
+	if _, acquired, err := acquire(ctx, "search", "auth:a", limit, time.Minute); err != nil || !acquired {
+		t.Fatalf("slot after release: acquired = %v, err = %v", acquired, err)
+	}
+}
+
+func TestAcquireSlotPurgesExpiredLeases(t *testing.T) {
+	storagetest.UseRedis(t)
+	ctx := context.Background()
+	key := []string{"Concurrency#search#auth:a"}
+	now := time.Now().UnixMilli()
+
+	tests := []struct {
+		name     string
+		now      int64
+		acquired int64
+		inFlight int64
+	}{
+		{"first slot", now, 1, 1},
+		{"quota reached", now + 500, 0, 1},
+		{"lease expired", now + 1001, 1, 1},
+	}
+	for _, tt := range tests {
+		result, err := acquireSlot.Run(ctx, storage.RedisClient, key, 1, tt.now, tt.now+1000, tt.name).Int64Slice()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result[0] != tt.acquired || result[1] != tt.inFlight {
+			t.Errorf("%s: got {%d, %d}, want {%d, %d}", tt.name, result[0], result[1], tt.acquired, tt.inFlight)
+		}
+	}
+}
+
+func TestLimitFor(t *testing.T) {
+	tenantDefault := Limit{Rate: 1}
+	p := &Policies{
+		Default: Limit{Rate: 10, Burst: 20},
+		Routes: map[string]Limit{
+			"search": {Rate: 5},
+			"select": {Rate: 0.5},
+		},
+		Tenants: map[string]TenantLimits{
+			"acme": {
+				Default: &tenantDefault,
+				Routes:  map[string]Limit{"search": {Rate: 50, Burst: 100}},
+			},
+			"globex": {Routes: map[string]Limit{"init": {MaxConcurrent: 2}}},
+		},
+	}
+
+	tests := []struct {
+		tenant, route string
+		want          Limit
+	}{
+		{"", "confirm", Limit{Rate: 10, Burst: 20}},
+		{"", "search", Limit{Rate: 5, Burst: 5}},
+		{"", "select", Limit{Rate: 0.5, Burst: 1}},
+		{"acme", "search", Limit{Rate: 50, Burst: 100}},
+		{"acme", "select", Limit{Rate: 1, Burst: 1}},
+		{"globex", "init", Limit{MaxConcurrent: 2, Burst: 1}},
+		{"globex", "search", Limit{Rate: 5, Burst: 5}},
+	}
+	for _, tt := range tests {
+		if got := p.LimitFor(tt.tenant, tt.route); got != tt.want {
+			t.Errorf("LimitFor(%q, %q) = %+v, want %+v", tt.tenant, tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestInitLease(t *testing.T) {
+	t.Cleanup(func() { Init(Options{}) })
+
+	tests := []struct {
+		name    string
+		file    string
+		wait    time.Duration
+		want    time.Duration
+		wantErr bool
+	}{
+		{"configured lease", "enabled: true\nlease: 2m\n", 30 * time.Second, 2 * time.Minute, false},
+		{"default lease", "enabled: true\n", 30 * time.Second, time.Minute, false},
+		{"default lease follows a long wait", "enabled: true\n", 45 * time.Second, 90 * time.Second, false},
+		{"lease equal to the wait", "enabled: true\nlease: 30s\n", 30 * time.Second, 0, true},
+		{"lease below the wait", "enabled: true\nlease: 1m\n", 2 * time.Minute, 0, true},
+		{"negative lease", "enabled: true\nlease: -1m\n", 30 * time.Second, 0, true},
+		{"no file", "", 2 * time.Minute, 4 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rate_limits.yaml")
+			if tt.file != "" {
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := Init(Options{Path: path, WaitTimeout: tt.wait})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && current().Lease != tt.want {
+				t.Errorf("Lease = %s, want %s", current().Lease, tt.want)
+			}
+		})
+	}
+}
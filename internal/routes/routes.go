@@ -1,16 +1,69 @@
 package routes
 
 import (
+	"BAP_Sandbox/config"
+	"BAP_Sandbox/internal/auth"
 	"BAP_Sandbox/internal/controllers"
+	"BAP_Sandbox/internal/drain"
+	"BAP_Sandbox/internal/metrics"
+	"BAP_Sandbox/internal/ratelimit"
+	"BAP_Sandbox/internal/tenants"
+	"slices"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // SetupRoutes configures all application routes
-func SetupRoutes(app *fiber.App) {
+func SetupRoutes(app *fiber.App, cfg *config.Config) error {
 	// Initialize controllers
-	forwardController := controllers.NewForwardController()
-	webhookController := controllers.NewWebhookController()
+	controllers.SetRouteMapping(cfg.Routes.Callbacks)
+	forwardController := controllers.NewForwardController(controllers.ForwardOptions{
+		Timeout:      cfg.Onix.Timeout,
+		CallbackWait: cfg.Callbacks.WaitTimeout,
+		PendingTTL:   cfg.Callbacks.PendingTTL,
+		SyncRoutes:   cfg.Routes.Sync,
+		Lifecycle:    cfg.Lifecycle.Enforcement,
+	})
+	webhookController, err := controllers.NewWebhookController(controllers.WebhookAuthOptions{
+		HMACSecret:   cfg.Webhook.HMACSecret,
+		HMACHeader:   cfg.Webhook.HMACHeader,
+		BearerTokens: cfg.Webhook.BearerTokens,
+		AllowedCIDRs: cfg.Webhook.AllowedCIDRs,
+	})
+	if err != nil {
+		return err
+	}
+	adminController := controllers.NewAdminController(cfg.Admin.APIKey)
+	transactionController := controllers.NewTransactionController(cfg.Lifecycle.Enforcement)
+	catalogController := controllers.NewCatalogController(cfg.Catalog.MaxPageSize)
+	batchController := controllers.NewBatchController(app, controllers.BatchOptions{
+		MaxItems:    cfg.Batch.MaxItems,
+		Parallelism: cfg.Batch.Parallelism,
+		Timeout:     cfg.Batch.Timeout,
+		ItemTimeout: cfg.Callbacks.WaitTimeout,
+	})
+
+	// Probe every tenant's ONIX replicas; a single-tenant deployment keeps the "onix" check
+	upstreams := map[string][]string{}
+	for _, tenant := range tenants.All() {
+		name := "onix"
+		if tenant.Name != "" {
+			name = "onix:" + tenant.Name
+		}
+		for _, pool := range tenant.Pools() {
+			for _, endpoint := range pool.Endpoints() {
+				if !slices.Contains(upstreams[name], endpoint.URL()) {
+					upstreams[name] = append(upstreams[name], endpoint.URL())
+				}
+			}
+		}
+	}
+	healthController := controllers.NewHealthController(controllers.HealthOptions{
+		Upstreams:       upstreams,
+		OnixProbePath:   cfg.Onix.ProbePath,
+		MaxPendingWaits: cfg.Health.MaxPendingWaits,
+		Timeout:         cfg.Health.Timeout,
+	})
 
 	// Health check endpoint
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -20,9 +73,46 @@ func SetupRoutes(app *fiber.App) {
 		})
 	})
 
+	// Kubernetes liveness and readiness probes
+	app.Get("/health/live", healthController.Live)
+	app.Get("/health/ready", healthController.Ready)
+
+	// Prometheus metrics endpoint
+	app.Get("/metrics", metrics.Handler())
+
+	// Forward several requests in one call; each item is dispatched to /api/{action} below,
+	// so tenants, rate limits and quotas apply per item. Registered first so "batch" is not
+	// taken for an action; /api/{tenant}/batch dispatches its items under the tenant's prefix
+	app.Post("/api/batch", drain.Middleware(), auth.Middleware(), batchController.HandleBatch)
+	app.Post("/api/:tenant/batch", drain.Middleware(), auth.Middleware(), batchController.HandleBatch)
+
+	// Lifecycle state of a transaction and the actions valid in it, for its client
+	// Transactions belong to a tenant, selected by the tenant header or the default tenant
+	app.Get("/api/transactions/:transaction_id", auth.Middleware(), tenants.Middleware(), transactionController.GetState)
+
+	// Catalog cached from a transaction's search responses, for paging and filtering
+	app.Get("/api/catalog", auth.Middleware(), tenants.Middleware(), catalogController.Query)
+
 	// Forward all POST requests from /api/* to target service and wait for webhook
-	app.Post("/api/*", forwardController.ForwardRequest)
+	// New requests are refused while draining for shutdown. Clients are then authenticated,
+	// the tenant is selected from /api/{tenant}/{action}, the tenant header or context.bap_id,
+	// and per-client rate limits and concurrency quotas are applied
+	app.Post("/api/*", drain.Middleware(), auth.Middleware(), tenants.Middleware(), ratelimit.Middleware(), forwardController.ForwardRequest)
+
+	// Webhook endpoint to receive callbacks, authenticated when configured
+	// It keeps serving while draining, so callbacks for in-flight requests still land
+	// Callbacks are matched to the tenant the same way, e.g. /webhook/{tenant}/{on_action}
+	app.Post("/webhook/*", webhookController.Authorize, tenants.Middleware(), webhookController.HandleWebhook)
+
+	// Admin endpoints to manage mappings, follow transactions and cancel pending waits
+	admin := app.Group("/admin", adminController.Authorize)
+	admin.Get("/mappings", adminController.ListMappings)
+	admin.Put("/mappings", adminController.UploadMappings)
+	admin.Get("/mappings/:route", adminController.GetMapping)
+	admin.Post("/mappings/:route/transform", adminController.TestTransform)
+	admin.Get("/transactions/:transaction_id", adminController.GetTransaction)
+	admin.Get("/pending", adminController.ListPending)
+	admin.Delete("/pending/:transaction_id/:message_id?", adminController.CancelPending)
 
-	// Webhook endpoint to receive callbacks
-	app.Post("/webhook/*", webhookController.HandleWebhook)
+	return nil
 }
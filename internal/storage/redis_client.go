@@ -1,45 +1,209 @@
 package storage
 
 import (
+	"BAP_Sandbox/internal/logging"
 	"context"
-	"log"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+var redisLog = logging.For("redis")
+
 var (
 	// RedisClient is the global Redis client instance
-	RedisClient *redis.Client
+	// It is a standalone, Sentinel-backed or Cluster client depending on the configured mode.
+	RedisClient redis.UniversalClient
 	ctx         = context.Background()
 )
 
-// InitRedis initializes the Redis client
-func InitRedis() error {
-	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		redisURL = "localhost:6379"
-	}
+// Redis deployment modes
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// RedisOptions configures the Redis connection
+type RedisOptions struct {
+	// URL is a host:port address or a redis:// or rediss:// URL, which may carry
+	// the username, password, DB and TLS; the fields below override it when set
+	URL string
+	// Addrs lists Sentinel or Cluster seed addresses; the URL's address is used when empty
+	Addrs []string
+	// Mode is standalone (default), sentinel or cluster
+	Mode string
+
+	Username string
+	Password string
+	DB       int
 
-	redisPassword := os.Getenv("REDIS_PASSWORD")
-	redisDB := 0 // Default DB
+	// MasterName is the Sentinel master; SentinelUsername and SentinelPassword authenticate to the Sentinels
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+
+	TLS                   bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+}
+
+// InitRedis initializes the Redis client
+func InitRedis(opts RedisOptions) error {
+	// Route go-redis internal logs through the structured logger
+	redis.SetLogger(redisLogger{})
 
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     redisURL,
-		Password: redisPassword,
-		DB:       redisDB,
-	})
+	universal, err := universalOptions(opts)
+	if err != nil {
+		return err
+	}
+	RedisClient = redis.NewUniversalClient(universal)
 
 	// Test connection
-	_, err := RedisClient.Ping(ctx).Result()
+	_, err = RedisClient.Ping(ctx).Result()
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Connected to Redis at %s", redisURL)
+	redisLog.Info("Connected to Redis",
+		"mode", modeName(opts.Mode),
+		"addrs", universal.Addrs,
+		"db", universal.DB,
+		"tls", universal.TLSConfig != nil,
+		"username", universal.Username,
+	)
 	return nil
 }
 
+// universalOptions translates the options into go-redis universal client options
+func universalOptions(opts RedisOptions) (*redis.UniversalOptions, error) {
+	universal := &redis.UniversalOptions{
+		Addrs:            opts.Addrs,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		DB:               opts.DB,
+		SentinelUsername: opts.SentinelUsername,
+		SentinelPassword: opts.SentinelPassword,
+		PoolSize:         opts.PoolSize,
+		MinIdleConns:     opts.MinIdleConns,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		PoolTimeout:      opts.PoolTimeout,
+	}
+
+	// A redis:// or rediss:// URL supplies defaults for the explicit options
+	addr := opts.URL
+	if strings.Contains(opts.URL, "://") {
+		parsed, err := redis.ParseURL(opts.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+		addr = parsed.Addr
+		if universal.Username == "" {
+			universal.Username = parsed.Username
+		}
+		if universal.Password == "" {
+			universal.Password = parsed.Password
+		}
+		if universal.DB == 0 {
+			universal.DB = parsed.DB
+		}
+		universal.TLSConfig = parsed.TLSConfig
+	}
+	if len(universal.Addrs) == 0 && addr != "" {
+		universal.Addrs = []string{addr}
+	}
+	if len(universal.Addrs) == 0 {
+		return nil, fmt.Errorf("no Redis address configured")
+	}
+
+	switch modeName(opts.Mode) {
+	case ModeStandalone:
+		if len(universal.Addrs) > 1 {
+			return nil, fmt.Errorf("standalone Redis takes one address, got %d (set the mode to sentinel or cluster)", len(universal.Addrs))
+		}
+	case ModeSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("sentinel mode requires a master name")
+		}
+		universal.MasterName = opts.MasterName
+	case ModeCluster:
+		if universal.DB != 0 {
+			return nil, fmt.Errorf("cluster mode only supports DB 0, got %d", universal.DB)
+		}
+		universal.IsClusterMode = true
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q (supported: %s, %s, %s)", opts.Mode, ModeStandalone, ModeSentinel, ModeCluster)
+	}
+
+	if opts.TLS || opts.TLSCAFile != "" || opts.TLSCertFile != "" || opts.TLSInsecureSkipVerify {
+		tlsConfig, err := buildTLSConfig(universal.TLSConfig, opts)
+		if err != nil {
+			return nil, err
+		}
+		universal.TLSConfig = tlsConfig
+	}
+	return universal, nil
+}
+
+// buildTLSConfig extends base, which may be nil, with the configured CA, client certificate and server name
+func buildTLSConfig(base *tls.Config, opts RedisOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		tlsConfig = base.Clone()
+	}
+	if opts.TLSServerName != "" {
+		tlsConfig.ServerName = opts.TLSServerName
+	}
+	tlsConfig.InsecureSkipVerify = tlsConfig.InsecureSkipVerify || opts.TLSInsecureSkipVerify
+
+	if opts.TLSCAFile != "" {
+		pem, err := os.ReadFile(opts.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", opts.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// modeName returns the mode, defaulting to standalone
+func modeName(mode string) string {
+	if mode == "" {
+		return ModeStandalone
+	}
+	return mode
+}
+
 // CloseRedis closes the Redis connection
 func CloseRedis() error {
 	if RedisClient != nil {
@@ -48,7 +212,39 @@ func CloseRedis() error {
 	return nil
 }
 
+// ScanKeys calls fn for every key matching pattern
+// In cluster mode every master is scanned, since keys are spread across shards.
+func ScanKeys(ctx context.Context, pattern string, fn func(key string) error) error {
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			if err := fn(iter.Val()); err != nil {
+				return err
+			}
+		}
+		return iter.Err()
+	}
+
+	if cluster, ok := RedisClient.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			// fn is not required to be safe for concurrent use
+			mu.Lock()
+			defer mu.Unlock()
+			return scan(ctx, master)
+		})
+	}
+	return scan(ctx, RedisClient)
+}
+
 // GetContext returns the background context
 func GetContext() context.Context {
 	return ctx
 }
+
+// redisLogger adapts go-redis internal logging to the structured logger
+type redisLogger struct{}
+
+func (redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	redisLog.WarnContext(ctx, fmt.Sprintf(format, v...))
+}
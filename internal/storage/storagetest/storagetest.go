@@ -0,0 +1,24 @@
+// Package storagetest provides an in-process Redis for tests of packages using storage
+package storagetest
+
+import (
+	"BAP_Sandbox/internal/storage"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// UseRedis points storage.RedisClient at an in-process Redis for the rest of the test
+// The previous client is restored when the test ends.
+func UseRedis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	previous := storage.RedisClient
+	storage.RedisClient = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		storage.RedisClient.Close()
+		storage.RedisClient = previous
+	})
+	return server
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TransactionTTL is how long a transaction record is kept after its last update
+const TransactionTTL = 24 * time.Hour
+
+// makeTransactionKey creates the Redis key for a transaction record
+// Format: Txn#{tenant}#{transaction_id}, or Txn#{transaction_id} for the default tenant
+func makeTransactionKey(tenant, transactionID string) string {
+	if tenant != "" {
+		return fmt.Sprintf("Txn#%s#%s", tenant, transactionID)
+	}
+	return fmt.Sprintf("Txn#%s", transactionID)
+}
+
+// ErrTransactionOwned is returned when a client uses a transaction owned by another client
+var ErrTransactionOwned = errors.New("transaction belongs to another client")
+
+// touchTransaction claims a transaction for its client and records fields on it
+// KEYS[1] = transaction hash
+// ARGV[1] = client ID, ARGV[2] = tenant, ARGV[3] = now, ARGV[4] = TTL in seconds,
+// ARGV[5...] = field, value pairs
+// Returns the owning client if it differs from ARGV[1], without changing the record.
+var touchTransaction = redis.NewScript(`
+local owner = redis.call('HGET', KEYS[1], 'client_id')
+if ARGV[1] ~= '' and owner and owner ~= '' and owner ~= ARGV[1] then
+	return owner
+end
+if ARGV[1] ~= '' then
+	redis.call('HSETNX', KEYS[1], 'client_id', ARGV[1])
+end
+if ARGV[2] ~= '' then
+	redis.call('HSETNX', KEYS[1], 'tenant', ARGV[2])
+end
+redis.call('HSETNX', KEYS[1], 'created_at', ARGV[3])
+redis.call('HSET', KEYS[1], 'updated_at', ARGV[3])
+for i = 5, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return ''
+`)
+
+// TouchTransaction records fields on a tenant's transaction and refreshes its TTL
+// The first client to use a transaction owns it: client_id and tenant are written once,
+// and requests from other clients fail with ErrTransactionOwned without changing the
+// record. Requests without a client ID, when authentication is disabled, may use any
+// transaction. created_at is set on first use; updated_at is set on every call.
+func TouchTransaction(ctx context.Context, tenant, transactionID, clientID string, fields map[string]string) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	args := make([]interface{}, 0, 4+2*len(fields))
+	args = append(args, clientID, tenant, now, int64(TransactionTTL.Seconds()))
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+
+	owner, err := touchTransaction.Run(ctx, RedisClient, []string{makeTransactionKey(tenant, transactionID)}, args...).Text()
+	if err != nil {
+		return err
+	}
+	if owner != "" {
+		return ErrTransactionOwned
+	}
+	return nil
+}
+
+// GetTransaction returns the fields recorded for a tenant's transaction, or redis.Nil if none exist
+func GetTransaction(ctx context.Context, tenant, transactionID string) (map[string]string, error) {
+	fields, err := RedisClient.HGetAll(ctx, makeTransactionKey(tenant, transactionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, redis.Nil
+	}
+	return fields, nil
+}
+
+// CheckTransactionOwner returns ErrTransactionOwned if another client owns a tenant's transaction
+// It changes nothing, so requests can be refused before they are checked and recorded;
+// TouchTransaction enforces ownership again atomically when the request is recorded.
+func CheckTransactionOwner(ctx context.Context, tenant, transactionID, clientID string) error {
+	if clientID == "" {
+		return nil
+	}
+	owner, err := RedisClient.HGet(ctx, makeTransactionKey(tenant, transactionID), "client_id").Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if owner != "" && owner != clientID {
+		return ErrTransactionOwned
+	}
+	return nil
+}
+
+// transactionStateRetries bounds the attempts to update a state changed concurrently
+const transactionStateRetries = 5
+
+// UpdateTransactionState applies next to the transaction's lifecycle state and stores the result
+// next receives the current state, "" if none is recorded, and returns the new state. The
+// update is retried if the state changes concurrently, so next may be called more than once.
+// It returns the state before and after the update.
+func UpdateTransactionState(ctx context.Context, tenant, transactionID string, next func(current string) string) (from, to string, err error) {
+	key := makeTransactionKey(tenant, transactionID)
+	update := func(tx *redis.Tx) error {
+		current, err := tx.HGet(ctx, key, "state").Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		from, to = current, next(current)
+		if to == from {
+			return nil
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSetNX(ctx, key, "created_at", now)
+			pipe.HSet(ctx, key, "state", to, "state_updated_at", now, "updated_at", now)
+			pipe.Expire(ctx, key, TransactionTTL)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < transactionStateRetries; attempt++ {
+		err = RedisClient.Watch(ctx, update, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return from, to, err
+		}
+	}
+	return from, to, err
+}
+
+// Transaction event types, in the order they usually occur
+const (
+	EventRequest   = "request"
+	EventOnix      = "onix"
+	EventCache     = "cache"
+	EventCallback  = "callback"
+	EventResponse  = "response"
+	EventCancelled = "cancelled"
+	EventState     = "state"
+	EventViolation = "violation"
+)
+
+// transactionEventLimit caps the timeline length; older events are dropped first
+const transactionEventLimit = 200
+
+// TransactionEvent is one entry in a transaction's timeline
+type TransactionEvent struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Action     string    `json:"action,omitempty"`
+	MessageID  string    `json:"message_id,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	DurationMs float64   `json:"duration_ms,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// makeTransactionEventsKey creates the Redis key for a transaction's timeline
+// Format: TxnEvents#{tenant}#{transaction_id}, or TxnEvents#{transaction_id} for the default tenant
+func makeTransactionEventsKey(tenant, transactionID string) string {
+	if tenant != "" {
+		return fmt.Sprintf("TxnEvents#%s#%s", tenant, transactionID)
+	}
+	return fmt.Sprintf("TxnEvents#%s", transactionID)
+}
+
+// AppendTransactionEvent adds an event to a tenant's transaction timeline and refreshes its TTL
+func AppendTransactionEvent(ctx context.Context, tenant, transactionID string, event TransactionEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := makeTransactionEventsKey(tenant, transactionID)
+	_, err = RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, data)
+		pipe.LTrim(ctx, key, -transactionEventLimit, -1)
+		pipe.Expire(ctx, key, TransactionTTL)
+		return nil
+	})
+	return err
+}
+
+// GetTransactionEvents returns a tenant's transaction timeline, oldest first
+// Only events after since are returned when it is not zero.
+func GetTransactionEvents(ctx context.Context, tenant, transactionID string, since time.Time) ([]TransactionEvent, error) {
+	entries, err := RedisClient.LRange(ctx, makeTransactionEventsKey(tenant, transactionID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]TransactionEvent, 0, len(entries))
+	for _, entry := range entries {
+		var event TransactionEvent
+		if err := json.Unmarshal([]byte(entry), &event); err != nil {
+			continue
+		}
+		if since.IsZero() || event.Time.After(since) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,116 @@
+package storage_test
+
+import (
+	"BAP_Sandbox/internal/storage"
+	"BAP_Sandbox/internal/storage/storagetest"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTouchTransactionOwnership(t *testing.T) {
+	storagetest.UseRedis(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		tenant   string
+		clientID string
+		action   string
+		wantErr  error
+		// owner is the client_id expected on the tenant's record afterwards
+		owner string
+	}{
+		{"first client claims the transaction", "", "a", "search", nil, "a"},
+		{"owner continues", "", "a", "select", nil, "a"},
+		{"another client is refused", "", "b", "init", storage.ErrTransactionOwned, "a"},
+		{"unauthenticated request is allowed", "", "", "init", nil, "a"},
+		{"same ID in another tenant is separate", "acme", "b", "search", nil, "b"},
+		{"owner in another tenant is refused", "acme", "a", "select", storage.ErrTransactionOwned, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storage.TouchTransaction(ctx, tt.tenant, "txn-1", tt.clientID, map[string]string{"last_action": tt.action})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TouchTransaction = %v, want %v", err, tt.wantErr)
+			}
+			fields, err := storage.GetTransaction(ctx, tt.tenant, "txn-1")
+			if err != nil {
+				t.Fatalf("GetTransaction: %v", err)
+			}
+			if fields["client_id"] != tt.owner {
+				t.Errorf("client_id = %q, want %q", fields["client_id"], tt.owner)
+			}
+			if tt.wantErr != nil && fields["last_action"] == tt.action {
+				t.Errorf("refused request changed last_action to %q", tt.action)
+			}
+			if tt.wantErr == nil && fields["last_action"] != tt.action {
+				t.Errorf("last_action = %q, want %q", fields["last_action"], tt.action)
+			}
+			if fields["tenant"] != tt.tenant {
+				t.Errorf("tenant = %q, want %q", fields["tenant"], tt.tenant)
+			}
+		})
+	}
+}
+
+func TestTransactionKeys(t *testing.T) {
+	server := storagetest.UseRedis(t)
+	ctx := context.Background()
+
+	if err := storage.TouchTransaction(ctx, "", "txn-1", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.TouchTransaction(ctx, "acme", "txn-1", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.AppendTransactionEvent(ctx, "acme", "txn-1", storage.TransactionEvent{Type: storage.EventRequest}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"Txn#txn-1", "Txn#acme#txn-1", "TxnEvents#acme#txn-1"} {
+		if !server.Exists(key) {
+			t.Errorf("key %s does not exist", key)
+		}
+		if ttl := server.TTL(key); ttl != storage.TransactionTTL {
+			t.Errorf("TTL of %s = %s, want %s", key, ttl, storage.TransactionTTL)
+		}
+	}
+	if server.Exists("TxnEvents#txn-1") {
+		t.Error("event for tenant acme was recorded on the default tenant's timeline")
+	}
+}
+
+func TestCheckTransactionOwner(t *testing.T) {
+	storagetest.UseRedis(t)
+	ctx := context.Background()
+	if err := storage.TouchTransaction(ctx, "", "txn-1", "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.TouchTransaction(ctx, "", "txn-2", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		tenant        string
+		transactionID string
+		clientID      string
+		want          error
+	}{
+		{"owner", "", "txn-1", "a", nil},
+		{"another client", "", "txn-1", "b", storage.ErrTransactionOwned},
+		{"unauthenticated request", "", "txn-1", "", nil},
+		{"unowned transaction", "", "txn-2", "b", nil},
+		{"unknown transaction", "", "txn-3", "b", nil},
+		{"same ID in another tenant", "acme", "txn-1", "b", nil},
+	}
+	for _, tt := range tests {
+		if err := storage.CheckTransactionOwner(ctx, tt.tenant, tt.transactionID, tt.clientID); !errors.Is(err, tt.want) {
+			t.Errorf("%s: CheckTransactionOwner = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if fields, _ := storage.GetTransaction(ctx, "", "txn-3"); fields != nil {
+		t.Errorf("CheckTransactionOwner created a record: %v", fields)
+	}
+}
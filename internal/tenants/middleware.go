@@ -0,0 +1,134 @@
+package tenants
+
+import (
+	"BAP_Sandbox/internal/auth"
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"context"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Tenant selectors, in the order they are tried
+const (
+	SelectorPath    = "path"
+	SelectorHeader  = "header"
+	SelectorBapID   = "bap_id"
+	SelectorDefault = "default"
+)
+
+// Resolve selects the tenant for a request
+// The tenant is taken from a "{tenant}/" prefix on the route in the "*" parameter,
+// then the tenant header, then context.bap_id in the body, then the default tenant.
+// It returns nil if no tenant matches.
+func Resolve(c *fiber.Ctx) (tenant *Tenant, selector string) {
+	r := current()
+
+	if name, _ := SplitRoute(c.Params("*")); name != "" {
+		return r.tenants[name], SelectorPath
+	}
+
+	if name := c.Get(r.header); name != "" && len(r.tenants) > 0 {
+		// An explicit but unknown tenant is rejected rather than falling through to the default
+		return r.tenants[name], SelectorHeader
+	}
+
+	if len(r.byBapID) > 0 {
+		var body struct {
+			Context struct {
+				BapID string `json:"bap_id"`
+			} `json:"context"`
+		}
+		if json.Unmarshal(c.Body(), &body) == nil {
+			if tenant, ok := r.byBapID[body.Context.BapID]; ok {
+				return tenant, SelectorBapID
+			}
+		}
+	}
+
+	return r.defaultTenant, SelectorDefault
+}
+
+// Middleware resolves the tenant and attaches it to the request context
+// Authenticated clients must be allowed by the tenant. Requests that select no
+// tenant are rejected when no default tenant is configured.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		tenant, selector := Resolve(c)
+		if tenant == nil {
+			tenantLog.WarnContext(ctx, "No tenant matches request", "selector", selector, "path", c.Path())
+			metrics.TenantRejections.WithLabelValues("unknown_tenant").Inc()
+			return reject(c, fiber.StatusNotFound, "CONTEXT-ERROR", "UNKNOWN_TENANT", "No tenant matches this request")
+		}
+
+		if clientID := auth.ClientID(ctx); clientID != "" && !tenant.Allows(clientID) {
+			tenantLog.WarnContext(ctx, "Client not allowed for tenant", "tenant", tenant.Name)
+			metrics.TenantRejections.WithLabelValues("client_not_allowed").Inc()
+			return reject(c, fiber.StatusForbidden, "POLICY-ERROR", "FORBIDDEN", "Client is not allowed to use this tenant")
+		}
+
+		metrics.TenantRequests.WithLabelValues(tenant.Label(), selector).Inc()
+		ctx = WithTenant(ctx, tenant)
+		if tenant.Name != "" {
+			ctx = logging.WithFields(ctx, "tenant", tenant.Name)
+		}
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// Action returns the route in the "*" parameter without its tenant prefix
+// The result aliases the Fiber request buffer and must be copied if retained.
+func Action(c *fiber.Ctx) string {
+	_, action := SplitRoute(c.Params("*"))
+	return action
+}
+
+// Label returns the tenant name for use as a metrics label
+func (t *Tenant) Label() string {
+	if t.Name == "" {
+		return "default"
+	}
+	return t.Name
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext returns the request's tenant, falling back to the default tenant
+func FromContext(ctx context.Context) *Tenant {
+	if tenant, ok := ctx.Value(tenantKey{}).(*Tenant); ok {
+		return tenant
+	}
+	return Default()
+}
+
+// Name returns the request's tenant name, or "" for the implicit default tenant
+func Name(ctx context.Context) string {
+	if tenant := FromContext(ctx); tenant != nil {
+		return tenant.Name
+	}
+	return ""
+}
+
+// reject writes the Beckn NACK returned when tenant selection fails
+func reject(c *fiber.Ctx, status int, errorType, code, message string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"message": fiber.Map{
+			"ack": fiber.Map{
+				"status": "NACK",
+			},
+		},
+		"error": fiber.Map{
+			"type":    errorType,
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,315 @@
+package tenants
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/transformers"
+	"BAP_Sandbox/internal/upstream"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var tenantLog = logging.For("tenants")
+
+// validName restricts tenant names to characters that are safe in URL paths and Redis keys
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Tenant is a BAP subscriber served by its own ONIX adapter
+type Tenant struct {
+	// Name identifies the tenant in paths, headers, Redis keys and logs; empty for the implicit default tenant
+	Name string `yaml:"-"`
+	// BapIDs are the subscriber IDs matched against context.bap_id
+	BapIDs []string `yaml:"bap_ids"`
+	// OnixURL and OnixURLs list the tenant's ONIX replicas; requests are balanced across them
+	OnixURL  string   `yaml:"onix_url"`
+	OnixURLs []string `yaml:"onix_urls"`
+	// Balancing is round_robin (default) or least_pending
+	Balancing string `yaml:"balancing"`
+	// Routes sends individual actions to their own ONIX replicas
+	Routes map[string]RouteUpstream `yaml:"routes"`
+	// OnixHeaders are added to every request sent to the tenant's ONIX, e.g. credentials
+	// ${VAR} references are expanded from the environment so secrets stay out of the file.
+	OnixHeaders map[string]string `yaml:"onix_headers"`
+	// MappingsPath loads tenant-specific mappings; the global mappings are used when empty
+	MappingsPath string `yaml:"mappings_path"`
+	// AllowedClients restricts the authenticated client IDs allowed to use the tenant; empty allows all
+	AllowedClients []string `yaml:"allowed_clients"`
+
+	pool        *upstream.Pool
+	routePools  map[string]*upstream.Pool
+	transformer *transformers.Transformer
+}
+
+// RouteUpstream overrides the ONIX replicas for one action
+type RouteUpstream struct {
+	OnixURLs  []string `yaml:"onix_urls"`
+	Balancing string   `yaml:"balancing"`
+}
+
+// File is the tenants file format
+type File struct {
+	// Header selects the tenant by name (default: X-Tenant-ID)
+	Header string `yaml:"header"`
+	// DefaultTenant serves requests that select no tenant; they are rejected when empty
+	DefaultTenant string             `yaml:"default_tenant"`
+	Tenants       map[string]*Tenant `yaml:"tenants"`
+}
+
+// Registry holds the configured tenants
+type Registry struct {
+	header        string
+	tenants       map[string]*Tenant
+	byBapID       map[string]*Tenant
+	defaultTenant *Tenant
+}
+
+// Options configures the tenant registry
+type Options struct {
+	// Path is the tenants file; a missing file or an empty tenant list serves every request with the default ONIX
+	Path string
+	// DefaultOnixURLs are the ONIX replicas used when no tenants are configured
+	DefaultOnixURLs []string
+	// DefaultBalancing is the balancing strategy for DefaultOnixURLs
+	DefaultBalancing string
+}
+
+var (
+	registry   = &Registry{tenants: map[string]*Tenant{}, byBapID: map[string]*Tenant{}}
+	registryMu sync.RWMutex
+)
+
+// Init loads the tenants file and prepares each tenant's breaker and transformer
+func Init(opts Options) error {
+	var file File
+	if opts.Path != "" {
+		data, err := os.ReadFile(opts.Path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			tenantLog.Info("Tenants file not found", "path", opts.Path)
+		case err != nil:
+			return fmt.Errorf("failed to read tenants: %w", err)
+		default:
+			decoder := yaml.NewDecoder(bytes.NewReader(data))
+			decoder.KnownFields(true)
+			if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("failed to parse tenants YAML: %w", err)
+			}
+		}
+	}
+
+	loaded, err := newRegistry(file, opts)
+	if err != nil {
+		return err
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = loaded
+	return nil
+}
+
+// newRegistry validates the tenants file and builds the registry
+func newRegistry(file File, opts Options) (*Registry, error) {
+	r := &Registry{
+		header:  file.Header,
+		tenants: make(map[string]*Tenant, len(file.Tenants)),
+		byBapID: map[string]*Tenant{},
+	}
+	if r.header == "" {
+		r.header = "X-Tenant-ID"
+	}
+
+	// Without tenants every request is served by the default ONIX with the global mappings
+	if len(file.Tenants) == 0 {
+		pool, err := upstream.NewPool(opts.DefaultOnixURLs, opts.DefaultBalancing)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default ONIX: %w", err)
+		}
+		tenantLog.Info("No tenants configured, forwarding every request to the default ONIX", "onix_urls", pool.Name())
+		r.defaultTenant = &Tenant{pool: pool}
+		return r, nil
+	}
+
+	for name, tenant := range file.Tenants {
+		if tenant == nil {
+			return nil, fmt.Errorf("tenant %q has no configuration", name)
+		}
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid tenant name %q", name)
+		}
+		tenant.Name = name
+		if err := tenant.buildPools(); err != nil {
+			return nil, err
+		}
+		for header, value := range tenant.OnixHeaders {
+			tenant.OnixHeaders[header] = os.ExpandEnv(value)
+		}
+
+		if tenant.MappingsPath != "" {
+			loader := transformers.NewLoader(tenant.MappingsPath)
+			if err := loader.Load(); err != nil {
+				return nil, fmt.Errorf("failed to load mappings for tenant %q: %w", name, err)
+			}
+			tenant.transformer = transformers.NewTransformer(loader)
+		}
+
+		for _, bapID := range tenant.BapIDs {
+			if other, ok := r.byBapID[bapID]; ok {
+				return nil, fmt.Errorf("bap_id %q is claimed by tenants %q and %q", bapID, other.Name, name)
+			}
+			r.byBapID[bapID] = tenant
+		}
+		r.tenants[name] = tenant
+	}
+
+	if file.DefaultTenant != "" {
+		tenant, ok := r.tenants[file.DefaultTenant]
+		if !ok {
+			return nil, fmt.Errorf("default_tenant %q is not defined", file.DefaultTenant)
+		}
+		r.defaultTenant = tenant
+	}
+
+	tenantLog.Info("Loaded tenants", "tenants", len(r.tenants), "default_tenant", file.DefaultTenant)
+	return r, nil
+}
+
+// current returns the active registry
+func current() *Registry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry
+}
+
+// Lookup returns the tenant with the given name
+func Lookup(name string) (*Tenant, bool) {
+	tenant, ok := current().tenants[name]
+	return tenant, ok
+}
+
+// All returns every tenant sorted by name, or only the default tenant when none are configured
+func All() []*Tenant {
+	r := current()
+	if len(r.tenants) == 0 {
+		return []*Tenant{r.defaultTenant}
+	}
+	list := make([]*Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		list = append(list, tenant)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Default returns the tenant serving requests that select no tenant, or nil if they are rejected
+func Default() *Tenant {
+	return current().defaultTenant
+}
+
+// SplitRoute splits a "{tenant}/{action}" route into its tenant and action
+// Routes without a known tenant prefix are returned unchanged with an empty tenant.
+func SplitRoute(route string) (tenant, action string) {
+	name, rest, ok := strings.Cut(route, "/")
+	if !ok {
+		return "", route
+	}
+	if _, known := Lookup(name); !known {
+		return "", route
+	}
+	return name, rest
+}
+
+// buildPools creates the tenant's ONIX pool and any per-route pools
+func (t *Tenant) buildPools() error {
+	urls := t.OnixURLs
+	if t.OnixURL != "" {
+		urls = append([]string{t.OnixURL}, urls...)
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("tenant %q requires onix_url or onix_urls", t.Name)
+	}
+	pool, err := upstream.NewPool(urls, t.Balancing)
+	if err != nil {
+		return fmt.Errorf("tenant %q: %w", t.Name, err)
+	}
+	t.pool = pool
+
+	t.routePools = make(map[string]*upstream.Pool, len(t.Routes))
+	for action, route := range t.Routes {
+		balancing := route.Balancing
+		if balancing == "" {
+			balancing = t.Balancing
+		}
+		routePool, err := upstream.NewPool(route.OnixURLs, balancing)
+		if err != nil {
+			return fmt.Errorf("tenant %q route %q: %w", t.Name, action, err)
+		}
+		t.routePools[action] = routePool
+	}
+	return nil
+}
+
+// Pool returns the ONIX replicas serving an action
+func (t *Tenant) Pool(action string) *upstream.Pool {
+	if pool, ok := t.routePools[action]; ok {
+		return pool
+	}
+	return t.pool
+}
+
+// Pools returns the tenant's default pool followed by its per-route pools, sorted by action
+func (t *Tenant) Pools() []*upstream.Pool {
+	actions := make([]string, 0, len(t.routePools))
+	for action := range t.routePools {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	pools := []*upstream.Pool{t.pool}
+	for _, action := range actions {
+		pools = append(pools, t.routePools[action])
+	}
+	return pools
+}
+
+// Transformer returns the tenant's transformer, falling back to the global one
+func (t *Tenant) Transformer() (*transformers.Transformer, error) {
+	if t.transformer != nil {
+		return t.transformer, nil
+	}
+	return transformers.GetTransformer()
+}
+
+// HasOwnMappings reports whether the tenant transforms with its own mappings instead of the global ones
+func (t *Tenant) HasOwnMappings() bool {
+	return t.transformer != nil
+}
+
+// ApplyHeaders sets the tenant's ONIX headers on an outgoing request
+func (t *Tenant) ApplyHeaders(headers http.Header) {
+	for key, value := range t.OnixHeaders {
+		headers.Set(key, value)
+	}
+}
+
+// Allows reports whether an authenticated client may use the tenant
+func (t *Tenant) Allows(clientID string) bool {
+	if len(t.AllowedClients) == 0 {
+		return true
+	}
+	for _, allowed := range t.AllowedClients {
+		if allowed == clientID {
+			return true
+		}
+	}
+	return false
+}
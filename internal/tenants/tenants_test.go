@@ -0,0 +1,197 @@
+package tenants
+
+import (
+	"BAP_Sandbox/internal/auth"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// tenantsFile configures three tenants, selected by path, header or bap_id
+const tenantsFile = `header: X-Tenant
+default_tenant: retail
+tenants:
+  retail:
+    bap_ids: [bap.retail]
+    onix_url: http://onix-retail:8081
+  mobility:
+    bap_ids: [bap.mobility, bap.rides]
+    onix_urls: [http://onix-mobility-1:8081, http://onix-mobility-2:8081]
+    routes:
+      confirm:
+        onix_urls: [http://onix-mobility-confirm:8081]
+    onix_headers:
+      Authorization: Bearer ${TEST_MOBILITY_TOKEN}
+    mappings_path: ../../config/mappings.yaml
+  private:
+    onix_url: http://onix-private:8081
+    allowed_clients: [alpha]
+`
+
+// useTenants loads a tenants file for the rest of the test
+func useTenants(t *testing.T, data string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(Options{Path: path}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() { Init(Options{DefaultOnixURLs: []string{"http://localhost:8081"}}) })
+}
+
+// tenantApp returns an app answering /api/* with the selected tenant and route
+// The X-Test-Client header stands in for an authenticated client.
+func tenantApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/api/*", func(c *fiber.Ctx) error {
+		if client := c.Get("X-Test-Client"); client != "" {
+			c.SetUserContext(auth.WithIdentity(c.UserContext(), &auth.Identity{ClientID: client}))
+		}
+		return c.Next()
+	}, Middleware(), func(c *fiber.Ctx) error {
+		return c.SendString(Name(c.UserContext()) + " " + Action(c))
+	})
+	return app
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Setenv("TEST_MOBILITY_TOKEN", "secret")
+	useTenants(t, tenantsFile)
+	app := tenantApp()
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		bapID   string
+		status  int
+		// want is "{tenant} {action}" for accepted requests
+		want string
+	}{
+		{"path prefix", "/api/mobility/search", nil, "", http.StatusOK, "mobility search"},
+		{"path prefix wins over the header", "/api/mobility/search", map[string]string{"X-Tenant": "retail"}, "", http.StatusOK, "mobility search"},
+		{"path prefix wins over bap_id", "/api/mobility/search", nil, "bap.retail", http.StatusOK, "mobility search"},
+		{"unknown prefix is part of the route", "/api/unknown/search", nil, "", http.StatusOK, "retail unknown/search"},
+		{"header", "/api/search", map[string]string{"X-Tenant": "mobility"}, "", http.StatusOK, "mobility search"},
+		{"header wins over bap_id", "/api/search", map[string]string{"X-Tenant": "mobility"}, "bap.retail", http.StatusOK, "mobility search"},
+		{"unknown header tenant is rejected", "/api/search", map[string]string{"X-Tenant": "unknown"}, "bap.retail", http.StatusNotFound, ""},
+		{"bap_id", "/api/search", nil, "bap.rides", http.StatusOK, "mobility search"},
+		{"unknown bap_id uses the default", "/api/search", nil, "bap.other", http.StatusOK, "retail search"},
+		{"default", "/api/search", nil, "", http.StatusOK, "retail search"},
+		{"allowed client", "/api/private/search", map[string]string{"X-Test-Client": "alpha"}, "", http.StatusOK, "private search"},
+		{"client not allowed", "/api/private/search", map[string]string{"X-Test-Client": "beta"}, "", http.StatusForbidden, ""},
+		{"client not allowed by header", "/api/search", map[string]string{"X-Test-Client": "beta", "X-Tenant": "private"}, "", http.StatusForbidden, ""},
+		{"tenant without allowed clients", "/api/mobility/search", map[string]string{"X-Test-Client": "beta"}, "", http.StatusOK, "mobility search"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"context":{"action":"search","bap_id":"` + tt.bapID + `"}}`
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", resp.StatusCode, tt.status, data)
+			}
+			if tt.want != "" && string(data) != tt.want {
+				t.Errorf("selected %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoDefaultTenant(t *testing.T) {
+	useTenants(t, strings.Replace(tenantsFile, "default_tenant: retail\n", "", 1))
+	app := tenantApp()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/search", strings.NewReader(`{"context":{"bap_id":"bap.other"}}`))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestTenantConfiguration(t *testing.T) {
+	t.Setenv("TEST_MOBILITY_TOKEN", "secret")
+	useTenants(t, tenantsFile)
+
+	mobility, ok := Lookup("mobility")
+	if !ok {
+		t.Fatal("tenant mobility is not loaded")
+	}
+	retail, _ := Lookup("retail")
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"default pool", mobility.Pool("search").Name(), "http://onix-mobility-1:8081,http://onix-mobility-2:8081"},
+		{"route pool", mobility.Pool("confirm").Name(), "http://onix-mobility-confirm:8081"},
+		{"other tenant's pool", retail.Pool("confirm").Name(), "http://onix-retail:8081"},
+		{"default tenant", Default().Name, "retail"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	headers := http.Header{}
+	mobility.ApplyHeaders(headers)
+	if got := headers.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("mobility ONIX Authorization = %q, want the expanded token", got)
+	}
+	headers = http.Header{}
+	retail.ApplyHeaders(headers)
+	if len(headers) != 0 {
+		t.Errorf("retail ONIX headers = %v, want none", headers)
+	}
+
+	if !mobility.HasOwnMappings() || retail.HasOwnMappings() {
+		t.Errorf("HasOwnMappings = %v (mobility), %v (retail), want only mobility", mobility.HasOwnMappings(), retail.HasOwnMappings())
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	t.Cleanup(func() { Init(Options{DefaultOnixURLs: []string{"http://localhost:8081"}}) })
+
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"bap_id claimed twice", "tenants:\n  a:\n    bap_ids: [bap]\n    onix_url: http://a:1\n  b:\n    bap_ids: [bap]\n    onix_url: http://b:1\n"},
+		{"unknown default tenant", "default_tenant: c\ntenants:\n  a:\n    onix_url: http://a:1\n"},
+		{"tenant without ONIX", "tenants:\n  a:\n    bap_ids: [bap]\n"},
+		{"invalid tenant name", "tenants:\n  a/b:\n    onix_url: http://a:1\n"},
+		{"unknown key", "tenants:\n  a:\n    onix: http://a:1\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "tenants.yaml")
+		if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Init(Options{Path: path}); err == nil {
+			t.Errorf("%s: Init accepted the file", tt.name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "BAP_Sandbox"
+
+var tracingLog = logging.For("tracing")
+
+// Supported span exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Options configures the tracer provider
+type Options struct {
+	Exporter    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// ShutdownFunc flushes and stops the tracer provider
+type ShutdownFunc func(ctx context.Context) error
+
+// propagator serializes trace context using W3C traceparent/tracestate headers
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init configures the global tracer provider and W3C trace context propagation
+// With the "none" exporter no spans are recorded, but trace context sent by
+// clients is still propagated to ONIX.
+func Init(opts Options) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case "", ExporterNone:
+		tracingLog.Info("Span export disabled")
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		// Endpoint, headers and TLS are configured through the standard OTEL_EXPORTER_OTLP_* variables
+		exporter, err = otlptracehttp.New(context.Background())
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s span exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	tracingLog.Info("Exporting spans", "exporter", opts.Exporter, "service", opts.ServiceName, "sample_ratio", opts.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the application tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// ExtractFiber returns a context carrying any trace context sent with the incoming request
+func ExtractFiber(c *fiber.Ctx) context.Context {
+	carrier := propagation.MapCarrier{}
+	for _, key := range propagator.Fields() {
+		if value := c.Get(key); value != "" {
+			carrier.Set(key, utils.CopyString(value))
+		}
+	}
+	return propagator.Extract(c.UserContext(), carrier)
+}
+
+// InjectHTTP writes the trace context from ctx into outgoing HTTP headers
+// Any trace headers copied from the original request are replaced
+func InjectHTTP(ctx context.Context, header http.Header) {
+	for _, key := range propagator.Fields() {
+		header.Del(key)
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Serialize encodes the span context in ctx as a W3C traceparent value
+// It returns an empty string when ctx carries no valid span
+func Serialize(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Deserialize decodes a W3C traceparent value into a span context
+func Deserialize(traceParent string) trace.SpanContext {
+	if traceParent == "" {
+		return trace.SpanContext{}
+	}
+	carrier := propagation.MapCarrier{"traceparent": traceParent}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(ctx)
+}
+
+// LinkTo adds a link from the span in ctx to the span identified by traceParent
+func LinkTo(ctx context.Context, traceParent string, attrs ...attribute.KeyValue) {
+	spanContext := Deserialize(traceParent)
+	if !spanContext.IsValid() {
+		return
+	}
+	trace.SpanFromContext(ctx).AddLink(trace.Link{SpanContext: spanContext, Attributes: attrs})
+}
@@ -0,0 +1,229 @@
+package transformers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrorKind classifies the stage at which a transformation failed
+type ErrorKind string
+
+const (
+	ErrorKindTemplateNotFound ErrorKind = "template_not_found"
+	ErrorKindInvalidInput     ErrorKind = "invalid_input"
+	ErrorKindCompileFailed    ErrorKind = "compile_failed"
+	ErrorKindEvaluationFailed ErrorKind = "evaluation_failed"
+	ErrorKindInvalidOutput    ErrorKind = "invalid_output"
+
+	// errorKindDefault is used for errors that are not a TransformError
+	errorKindDefault ErrorKind = "default"
+)
+
+// ErrorCode is a Beckn error definition from the error code catalog
+type ErrorCode struct {
+	Type       string `yaml:"type"`
+	Code       string `yaml:"code"`
+	Message    string `yaml:"message"`
+	HTTPStatus int    `yaml:"http_status"`
+}
+
+// ErrorCatalog maps transformation error kinds to Beckn error definitions
+type ErrorCatalog struct {
+	Errors map[ErrorKind]ErrorCode `yaml:"errors"`
+}
+
+var (
+	errorCatalog = defaultErrorCatalog()
+	debugErrors  bool
+	errorMu      sync.RWMutex
+)
+
+// defaultErrorCatalog returns the built-in error definitions used when no catalog file overrides them
+func defaultErrorCatalog() *ErrorCatalog {
+	return &ErrorCatalog{
+		Errors: map[ErrorKind]ErrorCode{
+			ErrorKindTemplateNotFound: {
+				Type:       "INTERNAL-ERROR",
+				Code:       "MAPPING_NOT_FOUND",
+				Message:    "No transformation mapping is configured for this route",
+				HTTPStatus: http.StatusInternalServerError,
+			},
+			ErrorKindInvalidInput: {
+				Type:       "INTERNAL-ERROR",
+				Code:       "MAPPING_INVALID_INPUT",
+				Message:    "Payload could not be parsed for transformation",
+				HTTPStatus: http.StatusBadGateway,
+			},
+			ErrorKindCompileFailed: {
+				Type:       "INTERNAL-ERROR",
+				Code:       "MAPPING_TEMPLATE_INVALID",
+				Message:    "Transformation mapping is invalid",
+				HTTPStatus: http.StatusInternalServerError,
+			},
+			ErrorKindEvaluationFailed: {
+				Type:       "INTERNAL-ERROR",
+				Code:       "MAPPING_EVALUATION_FAILED",
+				Message:    "Payload could not be transformed",
+				HTTPStatus: http.StatusInternalServerError,
+			},
+			ErrorKindInvalidOutput: {
+				Type:       "INTERNAL-ERROR",
+				Code:       "MAPPING_OUTPUT_INVALID",
+				Message:    "Transformation produced an invalid payload",
+				HTTPStatus: http.StatusInternalServerError,
+			},
+			errorKindDefault: {
+				Type:       "INTERNAL-ERROR",
+				Code:       "MAPPING_ERROR",
+				Message:    "Failed to transform payload",
+				HTTPStatus: http.StatusInternalServerError,
+			},
+		},
+	}
+}
+
+// InitErrorResponses loads the error code catalog and sets whether internal
+// error details are included in responses. Entries in the catalog file
+// override the built-in defaults; a missing file keeps the defaults.
+func InitErrorResponses(catalogPath string, debug bool) error {
+	catalog := defaultErrorCatalog()
+
+	if catalogPath != "" {
+		data, err := os.ReadFile(catalogPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			transformerLog.Info("Error catalog not found, using built-in error codes", "path", catalogPath)
+		case err != nil:
+			return fmt.Errorf("failed to read error catalog: %w", err)
+		default:
+			fileCatalog, err := parseErrorCatalog(data)
+			if err != nil {
+				return fmt.Errorf("invalid error catalog %s: %w", catalogPath, err)
+			}
+			for kind, code := range fileCatalog.Errors {
+				catalog.Errors[kind] = code
+			}
+			transformerLog.Info("Loaded error codes", "count", len(fileCatalog.Errors), "path", catalogPath)
+		}
+	}
+
+	errorMu.Lock()
+	defer errorMu.Unlock()
+	errorCatalog = catalog
+	debugErrors = debug
+	return nil
+}
+
+// parseErrorCatalog decodes and validates an error catalog file
+// Unknown keys and kinds and entries without a type, code or message are rejected,
+// since they would produce NACKs with empty error fields. A missing HTTP status
+// defaults to 500.
+func parseErrorCatalog(data []byte) (*ErrorCatalog, error) {
+	var catalog ErrorCatalog
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&catalog); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse error catalog YAML: %w", err)
+	}
+
+	known := defaultErrorCatalog().Errors
+	kinds := make([]string, 0, len(catalog.Errors))
+	for kind := range catalog.Errors {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+
+	var errs []error
+	for _, name := range kinds {
+		kind := ErrorKind(name)
+		code := catalog.Errors[kind]
+		if _, ok := known[kind]; !ok {
+			errs = append(errs, fmt.Errorf("errors.%s: unknown error kind", kind))
+			continue
+		}
+		if code.Type == "" || code.Code == "" || code.Message == "" {
+			errs = append(errs, fmt.Errorf("errors.%s: type, code and message are required", kind))
+		}
+		switch {
+		case code.HTTPStatus == 0:
+			code.HTTPStatus = http.StatusInternalServerError
+		case code.HTTPStatus < 400 || code.HTTPStatus > 599:
+			errs = append(errs, fmt.Errorf("errors.%s: http_status must be between 400 and 599, got %d", kind, code.HTTPStatus))
+		}
+		catalog.Errors[kind] = code
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &catalog, nil
+}
+
+// lookupErrorCode returns the catalog entry for a kind, falling back to the default entry
+func lookupErrorCode(kind ErrorKind) ErrorCode {
+	errorMu.RLock()
+	defer errorMu.RUnlock()
+
+	if code, ok := errorCatalog.Errors[kind]; ok {
+		return code
+	}
+	return errorCatalog.Errors[errorKindDefault]
+}
+
+// CreateMappingErrorResponse creates a Beckn NACK response for mapping errors
+// It returns the HTTP status code together with the response body
+func CreateMappingErrorResponse(route string, err error) (int, map[string]interface{}) {
+	transformerLog.Debug("Creating mapping error response", "route", route, "error", err)
+
+	kind := errorKindDefault
+	var transformErr *TransformError
+	if errors.As(err, &transformErr) {
+		kind = transformErr.Kind
+	}
+	code := lookupErrorCode(kind)
+
+	errorBody := map[string]interface{}{
+		"type":    code.Type,
+		"code":    code.Code,
+		"path":    route,
+		"message": code.Message,
+	}
+
+	errorMu.RLock()
+	debug := debugErrors
+	errorMu.RUnlock()
+
+	// Internal details are only exposed in debug mode
+	if debug {
+		details := map[string]interface{}{
+			"route": route,
+			"kind":  kind,
+		}
+		if transformErr != nil {
+			details["direction"] = transformErr.Direction
+			details["details"] = transformErr.Message
+			if transformErr.Err != nil {
+				details["error"] = transformErr.Err.Error()
+			}
+		} else {
+			details["error"] = err.Error()
+		}
+		errorBody["details"] = details
+	}
+
+	return code.HTTPStatus, map[string]interface{}{
+		"message": map[string]interface{}{
+			"ack": map[string]interface{}{
+				"status": "NACK",
+			},
+		},
+		"error": errorBody,
+	}
+}
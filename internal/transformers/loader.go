@@ -2,9 +2,10 @@ package transformers
 
 import (
 	"fmt"
-	"log"
 	"os"
+	"sort"
 
+	"github.com/blues/jsonata-go"
 	"gopkg.in/yaml.v3"
 )
 
@@ -42,7 +43,7 @@ func NewLoader(configPath string) *Loader {
 
 // Load reads and parses the mapping configuration file
 func (l *Loader) Load() error {
-	log.Printf("[Transformer] Loading mappings from: %s", l.configPath)
+	transformerLog.Info("Loading mappings", "path", l.configPath)
 
 	// Read the YAML file
 	data, err := os.ReadFile(l.configPath)
@@ -50,6 +51,11 @@ func (l *Loader) Load() error {
 		return fmt.Errorf("failed to read mappings file: %w", err)
 	}
 
+	return l.LoadBytes(data)
+}
+
+// LoadBytes parses and validates mapping configuration from raw YAML
+func (l *Loader) LoadBytes(data []byte) error {
 	// Parse YAML into config structure
 	var config MappingConfig
 	if err := yaml.Unmarshal(data, &config); err != nil {
@@ -61,15 +67,59 @@ func (l *Loader) Load() error {
 		return fmt.Errorf("no mappings found in configuration file")
 	}
 
+	// Validate that every template compiles
+	if err := validateTemplates(&config); err != nil {
+		return err
+	}
+
 	l.config = &config
-	log.Printf("[Transformer] Successfully loaded %d route mappings", len(config.Mappings))
+	transformerLog.Info("Loaded route mappings", "count", len(config.Mappings), "routes", sortedRoutes(&config))
+
+	return nil
+}
+
+// validateTemplates compiles every JSONata template to catch syntax errors early
+func validateTemplates(config *MappingConfig) error {
+	for _, route := range sortedRoutes(config) {
+		transform := config.Mappings[route]
+		if transform.Forward == "" && transform.Reverse == "" {
+			return fmt.Errorf("mapping for route %s defines neither forward nor reverse template", route)
+		}
+		if transform.Forward != "" {
+			if _, err := jsonata.Compile(transform.Forward); err != nil {
+				return fmt.Errorf("invalid forward template for route %s: %w", route, err)
+			}
+		}
+		if transform.Reverse != "" {
+			if _, err := jsonata.Compile(transform.Reverse); err != nil {
+				return fmt.Errorf("invalid reverse template for route %s: %w", route, err)
+			}
+		}
+	}
+	return nil
+}
 
-	// Log available routes
+// sortedRoutes returns the configured route names in alphabetical order
+func sortedRoutes(config *MappingConfig) []string {
+	routes := make([]string, 0, len(config.Mappings))
 	for route := range config.Mappings {
-		log.Printf("[Transformer] Available mapping for route: %s", route)
+		routes = append(routes, route)
 	}
+	sort.Strings(routes)
+	return routes
+}
 
-	return nil
+// GetConfigPath returns the path the mappings are loaded from
+func (l *Loader) GetConfigPath() string {
+	return l.configPath
+}
+
+// GetRoutes returns all routes with a mapping, sorted by name
+func (l *Loader) GetRoutes() []string {
+	if l.config == nil {
+		return nil
+	}
+	return sortedRoutes(l.config)
 }
 
 // GetConfig returns the loaded configuration
@@ -2,7 +2,8 @@ package transformers
 
 import (
 	"fmt"
-	"log"
+	"os"
+	"path/filepath"
 	"sync"
 )
 
@@ -10,12 +11,18 @@ var (
 	instance     *Transformer
 	instanceOnce sync.Once
 	instanceErr  error
+	instanceMu   sync.RWMutex
+	mappingsFile string
 )
 
 // InitTransformer initializes the global transformer instance
 func InitTransformer(mappingsPath string) error {
 	instanceOnce.Do(func() {
-		log.Printf("[Transformer] Initializing transformer with mappings: %s", mappingsPath)
+		transformerLog.Info("Initializing transformer", "mappings_path", mappingsPath)
+
+		instanceMu.Lock()
+		defer instanceMu.Unlock()
+		mappingsFile = mappingsPath
 
 		// Create loader
 		loader := NewLoader(mappingsPath)
@@ -23,30 +30,85 @@ func InitTransformer(mappingsPath string) error {
 		// Load mappings
 		if err := loader.Load(); err != nil {
 			instanceErr = fmt.Errorf("failed to load mappings: %w", err)
-			log.Printf("[Transformer] Error: %v", instanceErr)
+			transformerLog.Error("Failed to initialize transformer", "error", instanceErr)
 			return
 		}
 
 		// Create transformer
 		instance = NewTransformer(loader)
-		log.Printf("[Transformer] Transformer initialized successfully")
+		transformerLog.Info("Transformer initialized")
 	})
 
+	instanceMu.RLock()
+	defer instanceMu.RUnlock()
 	return instanceErr
 }
 
 // GetTransformer returns the global transformer instance
 func GetTransformer() (*Transformer, error) {
-	if instance == nil {
-		return nil, fmt.Errorf("transformer not initialized, call InitTransformer first")
-	}
+	instanceMu.RLock()
+	defer instanceMu.RUnlock()
+
 	if instanceErr != nil {
 		return nil, instanceErr
 	}
+	if instance == nil {
+		return nil, fmt.Errorf("transformer not initialized, call InitTransformer first")
+	}
 	return instance, nil
 }
 
 // IsInitialized checks if the transformer has been initialized
 func IsInitialized() bool {
+	instanceMu.RLock()
+	defer instanceMu.RUnlock()
 	return instance != nil && instanceErr == nil
 }
+
+// UpdateMappings validates a new mapping set, persists it to the mappings file
+// and swaps the global transformer instance. The running transformer is left
+// untouched if validation or persistence fails.
+func UpdateMappings(data []byte) (*Transformer, error) {
+	instanceMu.Lock()
+	defer instanceMu.Unlock()
+
+	if mappingsFile == "" {
+		return nil, fmt.Errorf("transformer not initialized, call InitTransformer first")
+	}
+
+	loader := NewLoader(mappingsFile)
+	if err := loader.LoadBytes(data); err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(mappingsFile, data); err != nil {
+		return nil, fmt.Errorf("failed to persist mappings: %w", err)
+	}
+
+	instance = NewTransformer(loader)
+	instanceErr = nil
+	transformerLog.Info("Mappings updated and persisted", "path", mappingsFile)
+	return instance, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
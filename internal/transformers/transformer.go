@@ -1,17 +1,24 @@
 package transformers
 
 import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
+	"time"
 
 	"github.com/blues/jsonata-go"
 )
 
+var transformerLog = logging.For("transformer")
+
 // TransformError represents an error that occurred during transformation
 type TransformError struct {
 	Route     string
 	Direction string
+	Kind      ErrorKind
 	Message   string
 	Err       error
 }
@@ -20,6 +27,11 @@ func (e *TransformError) Error() string {
 	return fmt.Sprintf("transformation error for route '%s' (%s): %s", e.Route, e.Direction, e.Message)
 }
 
+// Unwrap returns the underlying error
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
 // Transformer handles JSON transformations using JSONata
 type Transformer struct {
 	loader *Loader
@@ -32,9 +44,34 @@ func NewTransformer(loader *Loader) *Transformer {
 	}
 }
 
-// Transform applies the transformation to the input data
-func (t *Transformer) Transform(route string, direction TransformDirection, inputJSON []byte) ([]byte, error) {
-	log.Printf("[Transformer] Transforming %s request for route: %s", direction, route)
+// Transform applies the transformation to the input data and records transform metrics
+func (t *Transformer) Transform(route string, direction TransformDirection, inputJSON []byte) (output []byte, err error) {
+	start := time.Now()
+	defer func() {
+		// Copy the route since callers may pass strings backed by reused request buffers
+		routeLabel := "unknown"
+		if t.loader.HasMapping(route) {
+			routeLabel = strings.Clone(route)
+		}
+		directionLabel := strings.Clone(string(direction))
+		metrics.TransformDuration.WithLabelValues(routeLabel, directionLabel).Observe(time.Since(start).Seconds())
+		var transformErr *TransformError
+		if errors.As(err, &transformErr) {
+			metrics.TransformErrors.WithLabelValues(routeLabel, directionLabel, string(transformErr.Kind)).Inc()
+		}
+	}()
+	return t.transform(route, direction, inputJSON)
+}
+
+// DryRun applies the transformation without recording metrics, for admin test transforms
+func (t *Transformer) DryRun(route string, direction TransformDirection, inputJSON []byte) ([]byte, error) {
+	return t.transform(route, direction, inputJSON)
+}
+
+// transform applies the transformation to the input data
+func (t *Transformer) transform(route string, direction TransformDirection, inputJSON []byte) ([]byte, error) {
+	transformerLog.Debug("Transforming payload", "route", route, "direction", direction)
+	start := time.Now()
 
 	// Get the transformation template
 	template, err := t.loader.GetTransformTemplate(route, direction)
@@ -42,6 +79,7 @@ func (t *Transformer) Transform(route string, direction TransformDirection, inpu
 		return nil, &TransformError{
 			Route:     route,
 			Direction: string(direction),
+			Kind:      ErrorKindTemplateNotFound,
 			Message:   "template not found",
 			Err:       err,
 		}
@@ -53,51 +91,49 @@ func (t *Transformer) Transform(route string, direction TransformDirection, inpu
 		return nil, &TransformError{
 			Route:     route,
 			Direction: string(direction),
+			Kind:      ErrorKindInvalidInput,
 			Message:   "failed to parse input JSON",
 			Err:       err,
 		}
 	}
 
-	log.Printf("[Transformer] Input data parsed successfully")
-
 	// Compile JSONata expression
 	expr, err := jsonata.Compile(template)
 	if err != nil {
 		return nil, &TransformError{
 			Route:     route,
 			Direction: string(direction),
+			Kind:      ErrorKindCompileFailed,
 			Message:   "failed to compile transformation template",
 			Err:       err,
 		}
 	}
 
-	log.Printf("[Transformer] JSONata expression compiled successfully")
-
 	// Evaluate the expression
 	result, err := expr.Eval(inputData)
 	if err != nil {
 		return nil, &TransformError{
 			Route:     route,
 			Direction: string(direction),
+			Kind:      ErrorKindEvaluationFailed,
 			Message:   "failed to evaluate transformation",
 			Err:       err,
 		}
 	}
 
-	log.Printf("[Transformer] Transformation evaluated successfully")
-
 	// Marshal result back to JSON
 	outputJSON, err := json.Marshal(result)
 	if err != nil {
 		return nil, &TransformError{
 			Route:     route,
 			Direction: string(direction),
+			Kind:      ErrorKindInvalidOutput,
 			Message:   "failed to marshal output JSON",
 			Err:       err,
 		}
 	}
 
-	log.Printf("[Transformer] Transformation completed successfully for route: %s", route)
+	transformerLog.Debug("Transformation completed", "route", route, "direction", direction, "duration", time.Since(start))
 	return outputJSON, nil
 }
 
@@ -116,29 +152,9 @@ func (t *Transformer) HasMapping(route string) bool {
 	return t.loader.HasMapping(route)
 }
 
-// CreateMappingErrorResponse creates a standardized error response for mapping errors
-func CreateMappingErrorResponse(route string, err error) map[string]interface{} {
-	log.Printf("[Transformer] Creating mapping error response for route: %s, error: %v", route, err)
-
-	errorResponse := map[string]interface{}{
-		"mappingError": map[string]interface{}{
-			"route":   route,
-			"message": "Failed to transform response",
-		},
-	}
-
-	// Add detailed error information if available
-	if transformErr, ok := err.(*TransformError); ok {
-		errorResponse["mappingError"].(map[string]interface{})["direction"] = transformErr.Direction
-		errorResponse["mappingError"].(map[string]interface{})["details"] = transformErr.Message
-		if transformErr.Err != nil {
-			errorResponse["mappingError"].(map[string]interface{})["error"] = transformErr.Err.Error()
-		}
-	} else {
-		errorResponse["mappingError"].(map[string]interface{})["error"] = err.Error()
-	}
-
-	return errorResponse
+// GetLoader returns the loader backing this transformer
+func (t *Transformer) GetLoader() *Loader {
+	return t.loader
 }
 
 // ValidateJSON validates that the input is valid JSON
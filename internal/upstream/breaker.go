@@ -0,0 +1,273 @@
+package upstream
+
+import (
+	"BAP_Sandbox/internal/logging"
+	"BAP_Sandbox/internal/metrics"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var upstreamLog = logging.For("upstream")
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+// String returns the state name used in logs, metrics and health output
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen is returned by Allow while the breaker rejects requests
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerSettings configures a circuit breaker
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit; 0 disables the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probe requests are let through
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of concurrent probe requests allowed while half-open,
+	// and the number of successful probes needed to close the circuit again
+	HalfOpenProbes int
+}
+
+// Breaker is a consecutive-failure circuit breaker for a single upstream
+type Breaker struct {
+	name     string
+	settings BreakerSettings
+
+	mu         sync.Mutex
+	state      State
+	failures   int
+	probes     int
+	successes  int
+	openedAt   time.Time
+	generation uint64
+}
+
+// BreakerStatus is a point-in-time view of a breaker
+type BreakerStatus struct {
+	Name                string  `json:"name"`
+	State               string  `json:"state"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	RetryAfterSeconds   float64 `json:"retry_after_seconds,omitempty"`
+}
+
+// NewBreaker creates a closed breaker for the named upstream
+func NewBreaker(name string, settings BreakerSettings) *Breaker {
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenProbes <= 0 {
+		settings.HalfOpenProbes = 1
+	}
+	b := &Breaker{name: name, settings: settings}
+	metrics.CircuitState.WithLabelValues(name).Set(float64(StateClosed))
+	return b
+}
+
+// Name returns the upstream name
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// Allow reports whether a request may be sent to the upstream. On success the
+// caller must invoke done exactly once with the outcome of the request.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	if b.settings.FailureThreshold <= 0 {
+		return func(bool) {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refreshState(time.Now())
+	switch b.state {
+	case StateOpen:
+		metrics.CircuitRejections.WithLabelValues(b.name).Inc()
+		return nil, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probes >= b.settings.HalfOpenProbes {
+			metrics.CircuitRejections.WithLabelValues(b.name).Inc()
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() { b.record(generation, success) })
+	}, nil
+}
+
+// RetryAfter returns how long until the open circuit lets a probe through
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateOpen {
+		return 0
+	}
+	remaining := b.settings.OpenTimeout - time.Since(b.openedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Status returns the current state of the breaker
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.refreshState(now)
+	status := BreakerStatus{
+		Name:                b.name,
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == StateOpen {
+		status.RetryAfterSeconds = (b.settings.OpenTimeout - now.Sub(b.openedAt)).Seconds()
+	}
+	return status
+}
+
+// record applies the outcome of a request admitted in the given generation
+// Outcomes from an earlier generation are ignored, since the state has moved on
+func (b *Breaker) record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.setState(StateOpen, time.Now())
+		}
+	case StateHalfOpen:
+		b.probes--
+		if !success {
+			b.failures++
+			b.setState(StateOpen, time.Now())
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenProbes {
+			b.setState(StateClosed, time.Now())
+		}
+	}
+}
+
+// refreshState moves an open breaker to half-open once the open timeout has passed
+func (b *Breaker) refreshState(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+// setState transitions the breaker and starts a new generation
+func (b *Breaker) setState(state State, now time.Time) {
+	previous := b.state
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.failures = 0
+	}
+
+	metrics.CircuitState.WithLabelValues(b.name).Set(float64(state))
+	metrics.CircuitTransitions.WithLabelValues(b.name, state.String()).Inc()
+
+	if state == StateOpen {
+		upstreamLog.Warn("Circuit breaker opened", "upstream", b.name, "from", previous.String(),
+			"consecutive_failures", b.failures, "open_timeout", b.settings.OpenTimeout)
+	} else {
+		upstreamLog.Info("Circuit breaker state changed", "upstream", b.name, "from", previous.String(), "to", state.String())
+	}
+}
+
+// IsFailure reports whether an upstream outcome counts as a failure for the breaker
+// Transport errors and 5xx responses are failures; other responses show the upstream is reachable
+func IsFailure(status int, err error) bool {
+	return err != nil || status >= http.StatusInternalServerError
+}
+
+var (
+	breakers        = map[string]*Breaker{}
+	breakerSettings BreakerSettings
+	breakersMu      sync.Mutex
+)
+
+// InitBreakers sets the settings used for breakers created by GetBreaker
+func InitBreakers(settings BreakerSettings) {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	breakerSettings = settings
+	upstreamLog.Info("Circuit breakers configured",
+		"failure_threshold", settings.FailureThreshold,
+		"open_timeout", settings.OpenTimeout,
+		"half_open_probes", settings.HalfOpenProbes,
+	)
+}
+
+// GetBreaker returns the breaker for the named upstream, creating it on first use
+func GetBreaker(name string) *Breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	if b, ok := breakers[name]; ok {
+		return b
+	}
+	b := NewBreaker(name, breakerSettings)
+	breakers[name] = b
+	return b
+}
+
+// Breakers returns the status of every registered breaker, sorted by name
+func Breakers() []BreakerStatus {
+	breakersMu.Lock()
+	list := make([]*Breaker, 0, len(breakers))
+	for _, b := range breakers {
+		list = append(list, b)
+	}
+	breakersMu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(list))
+	for _, b := range list {
+		statuses = append(statuses, b.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
@@ -0,0 +1,183 @@
+package upstream
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// breakerStep is one request outcome applied to a breaker, with the state expected after it
+type breakerStep struct {
+	op   string
+	want State
+}
+
+// apply runs a step against the breaker
+// ok and fail admit a request and report its outcome, reject expects the request to be
+// refused, and expire moves the open timeout into the past.
+func (s breakerStep) apply(t *testing.T, b *Breaker) {
+	t.Helper()
+	switch s.op {
+	case "ok", "fail":
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatalf("%s: Allow() = %v, want admitted", s.op, err)
+		}
+		done(s.op == "ok")
+	case "reject":
+		if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("reject: Allow() = %v, want %v", err, ErrCircuitOpen)
+		}
+	case "expire":
+		b.mu.Lock()
+		b.openedAt = b.openedAt.Add(-b.settings.OpenTimeout)
+		b.mu.Unlock()
+	default:
+		t.Fatalf("unknown op %q", s.op)
+	}
+}
+
+func TestBreakerStateMachine(t *testing.T) {
+	settings := BreakerSettings{FailureThreshold: 3, OpenTimeout: time.Minute, HalfOpenProbes: 2}
+
+	tests := []struct {
+		name     string
+		settings BreakerSettings
+		steps    []breakerStep
+	}{
+		{
+			name:     "opens after consecutive failures",
+			settings: settings,
+			steps: []breakerStep{
+				{"fail", StateClosed},
+				{"fail", StateClosed},
+				{"fail", StateOpen},
+				{"reject", StateOpen},
+			},
+		},
+		{
+			name:     "success resets the failure count",
+			settings: settings,
+			steps: []breakerStep{
+				{"fail", StateClosed},
+				{"fail", StateClosed},
+				{"ok", StateClosed},
+				{"fail", StateClosed},
+				{"fail", StateClosed},
+				{"fail", StateOpen},
+			},
+		},
+		{
+			name:     "half-open closes after enough successful probes",
+			settings: settings,
+			steps: []breakerStep{
+				{"fail", StateClosed},
+				{"fail", StateClosed},
+				{"fail", StateOpen},
+				{"expire", StateHalfOpen},
+				{"ok", StateHalfOpen},
+				{"ok", StateClosed},
+				{"fail", StateClosed},
+			},
+		},
+		{
+			name:     "failed probe reopens the circuit",
+			settings: settings,
+			steps: []breakerStep{
+				{"fail", StateClosed},
+				{"fail", StateClosed},
+				{"fail", StateOpen},
+				{"expire", StateHalfOpen},
+				{"ok", StateHalfOpen},
+				{"fail", StateOpen},
+				{"reject", StateOpen},
+			},
+		},
+		{
+			name:     "disabled breaker never opens",
+			settings: BreakerSettings{},
+			steps: []breakerStep{
+				{"fail", StateClosed},
+				{"fail", StateClosed},
+				{"fail", StateClosed},
+				{"ok", StateClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker("test", tt.settings)
+			for i, step := range tt.steps {
+				step.apply(t, b)
+				b.mu.Lock()
+				b.refreshState(time.Now())
+				got := b.state
+				b.mu.Unlock()
+				if got != step.want {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, step.op, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenLimitsProbes(t *testing.T) {
+	b := NewBreaker("test", BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenProbes: 2})
+	breakerStep{"fail", StateOpen}.apply(t, b)
+	breakerStep{"expire", StateHalfOpen}.apply(t, b)
+
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatalf("first probe: %v", err)
+	}
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("second probe: %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("third probe: Allow() = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// A finished probe frees its slot
+	first(true)
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("probe after a slot was freed: %v", err)
+	}
+}
+
+func TestBreakerIgnoresStaleOutcomes(t *testing.T) {
+	b := NewBreaker("test", BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	stale, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	breakerStep{"fail", StateOpen}.apply(t, b)
+	breakerStep{"expire", StateHalfOpen}.apply(t, b)
+
+	// A request admitted before the circuit opened must not close it
+	stale(true)
+	if status := b.Status(); status.State != StateHalfOpen.String() {
+		t.Fatalf("state = %s, want %s", status.State, StateHalfOpen)
+	}
+}
+
+func TestIsFailure(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+		want   bool
+	}{
+		{200, nil, false},
+		{404, nil, false},
+		{429, nil, false},
+		{500, nil, true},
+		{503, nil, true},
+		{0, errors.New("connection refused"), true},
+	}
+	for _, tt := range tests {
+		if got := IsFailure(tt.status, tt.err); got != tt.want {
+			t.Errorf("IsFailure(%d, %v) = %v, want %v", tt.status, tt.err, got, tt.want)
+		}
+	}
+}
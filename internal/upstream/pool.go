@@ -0,0 +1,297 @@
+package upstream
+
+import (
+	"BAP_Sandbox/internal/metrics"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancing strategies for spreading requests across a pool's endpoints
+const (
+	BalanceRoundRobin   = "round_robin"
+	BalanceLeastPending = "least_pending"
+)
+
+// Endpoint is a single ONIX instance
+// Endpoints are shared by URL, so every pool containing the URL sees the same
+// breaker, in-flight count and health.
+type Endpoint struct {
+	url     string
+	breaker *Breaker
+	pending atomic.Int64
+	healthy atomic.Bool
+}
+
+// EndpointStatus is a point-in-time view of an endpoint
+type EndpointStatus struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	Pending int64  `json:"pending"`
+	Circuit string `json:"circuit"`
+}
+
+// URL returns the endpoint's base URL
+func (e *Endpoint) URL() string {
+	return e.url
+}
+
+// Breaker returns the circuit breaker guarding the endpoint
+func (e *Endpoint) Breaker() *Breaker {
+	return e.breaker
+}
+
+// Healthy reports whether the last active health check succeeded
+func (e *Endpoint) Healthy() bool {
+	return e.healthy.Load()
+}
+
+// Begin counts a request in flight to the endpoint until the returned function is called
+func (e *Endpoint) Begin() (end func()) {
+	e.pending.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { e.pending.Add(-1) })
+	}
+}
+
+// Status returns the current state of the endpoint
+func (e *Endpoint) Status() EndpointStatus {
+	return EndpointStatus{
+		URL:     e.url,
+		Healthy: e.Healthy(),
+		Pending: e.pending.Load(),
+		Circuit: e.breaker.Status().State,
+	}
+}
+
+// setHealthy records the outcome of a health check, logging changes
+func (e *Endpoint) setHealthy(healthy bool, reason string) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	metrics.UpstreamEndpointUp.WithLabelValues(e.url).Set(value)
+
+	if e.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		upstreamLog.Info("ONIX endpoint healthy", "endpoint", e.url)
+	} else {
+		upstreamLog.Warn("ONIX endpoint unhealthy", "endpoint", e.url, "reason", reason)
+	}
+}
+
+// Pool balances requests across the ONIX endpoints serving a tenant or route
+type Pool struct {
+	name      string
+	strategy  string
+	endpoints []*Endpoint
+	next      atomic.Uint64
+}
+
+var (
+	endpoints   = map[string]*Endpoint{}
+	endpointsMu sync.Mutex
+)
+
+// getEndpoint returns the endpoint for a URL, creating it on first use
+func getEndpoint(url string) *Endpoint {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+
+	if e, ok := endpoints[url]; ok {
+		return e
+	}
+	e := &Endpoint{url: url, breaker: GetBreaker(url)}
+	e.healthy.Store(true)
+	metrics.UpstreamEndpointUp.WithLabelValues(url).Set(1)
+	endpoints[url] = e
+	return e
+}
+
+// NewPool creates a pool over the given ONIX base URLs
+// The strategy defaults to round robin.
+func NewPool(urls []string, strategy string) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("an upstream pool needs at least one URL")
+	}
+	switch strategy {
+	case "":
+		strategy = BalanceRoundRobin
+	case BalanceRoundRobin, BalanceLeastPending:
+	default:
+		return nil, fmt.Errorf("unknown balancing strategy %q (supported: %s, %s)", strategy, BalanceRoundRobin, BalanceLeastPending)
+	}
+
+	p := &Pool{strategy: strategy}
+	seen := map[string]bool{}
+	for _, url := range urls {
+		url = strings.TrimRight(strings.TrimSpace(url), "/")
+		if url == "" || seen[url] {
+			continue
+		}
+		seen[url] = true
+		p.endpoints = append(p.endpoints, getEndpoint(url))
+	}
+	if len(p.endpoints) == 0 {
+		return nil, fmt.Errorf("an upstream pool needs at least one URL")
+	}
+
+	names := make([]string, len(p.endpoints))
+	for i, e := range p.endpoints {
+		names[i] = e.url
+	}
+	p.name = strings.Join(names, ",")
+	return p, nil
+}
+
+// Name identifies the pool in logs by its endpoint URLs
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Endpoints returns the pool's endpoints in configuration order
+func (p *Pool) Endpoints() []*Endpoint {
+	return p.endpoints
+}
+
+// Candidates returns the endpoints in the order a request should try them
+// Healthy endpoints come first, ordered by the balancing strategy; unhealthy
+// endpoints follow as a last resort, in case the health check is stale.
+func (p *Pool) Candidates() []*Endpoint {
+	// Rotate the starting endpoint on every request, so ties are spread across the pool
+	n := len(p.endpoints)
+	start := int((p.next.Add(1) - 1) % uint64(n))
+	ordered := make([]*Endpoint, 0, n)
+	for i := 0; i < n; i++ {
+		ordered = append(ordered, p.endpoints[(start+i)%n])
+	}
+
+	if p.strategy == BalanceLeastPending {
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].pending.Load() < ordered[j].pending.Load()
+		})
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Healthy() && !ordered[j].Healthy()
+	})
+	return ordered
+}
+
+// RetryAfter returns how long until any endpoint's open circuit lets a probe through
+func (p *Pool) RetryAfter() time.Duration {
+	var shortest time.Duration
+	for i, e := range p.endpoints {
+		if wait := e.breaker.RetryAfter(); i == 0 || wait < shortest {
+			shortest = wait
+		}
+	}
+	return shortest
+}
+
+// HealthCheckSettings configures active ONIX health checks
+type HealthCheckSettings struct {
+	// Interval between checks; 0 disables active health checks
+	Interval time.Duration
+	// Path is appended to each endpoint URL for the probe
+	Path string
+	// Timeout bounds each probe
+	Timeout time.Duration
+}
+
+// StartHealthChecks probes every endpoint periodically until ctx is cancelled
+// Any response below 500 marks an endpoint healthy.
+func StartHealthChecks(ctx context.Context, settings HealthCheckSettings) {
+	if settings.Interval <= 0 {
+		upstreamLog.Info("Active ONIX health checks disabled")
+		return
+	}
+	if settings.Timeout <= 0 {
+		settings.Timeout = 2 * time.Second
+	}
+	client := &http.Client{Timeout: settings.Timeout}
+	upstreamLog.Info("Active ONIX health checks enabled", "interval", settings.Interval, "path", settings.Path)
+
+	go func() {
+		ticker := time.NewTicker(settings.Interval)
+		defer ticker.Stop()
+		for {
+			checkEndpoints(ctx, client, settings.Path)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkEndpoints probes every registered endpoint concurrently
+func checkEndpoints(ctx context.Context, client *http.Client, path string) {
+	endpointsMu.Lock()
+	list := make([]*Endpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		list = append(list, e)
+	}
+	endpointsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range list {
+		wg.Add(1)
+		go func(e *Endpoint) {
+			defer wg.Done()
+			healthy, reason := probe(ctx, client, e.url+"/"+strings.TrimLeft(path, "/"))
+			if ctx.Err() != nil {
+				// Shutting down; the probe was cancelled, not failed
+				return
+			}
+			e.setHealthy(healthy, reason)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// probe sends a GET to url, returning whether it succeeded and why not
+func probe(ctx context.Context, client *http.Client, url string) (bool, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err.Error()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return false, fmt.Sprintf("probe returned HTTP %d", resp.StatusCode)
+	}
+	return true, ""
+}
+
+// EndpointStatuses returns the status of every registered endpoint, sorted by URL
+func EndpointStatuses() []EndpointStatus {
+	endpointsMu.Lock()
+	list := make([]*Endpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		list = append(list, e)
+	}
+	endpointsMu.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(list))
+	for _, e := range list {
+		statuses = append(statuses, e.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses
+}
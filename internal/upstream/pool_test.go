@@ -0,0 +1,142 @@
+package upstream
+
+import (
+	"slices"
+	"testing"
+)
+
+// urls returns the URLs of the endpoints in order
+func urls(endpoints []*Endpoint) []string {
+	result := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		result[i] = e.URL()
+	}
+	return result
+}
+
+func TestNewPool(t *testing.T) {
+	tests := []struct {
+		name     string
+		urls     []string
+		strategy string
+		want     []string
+		wantErr  bool
+	}{
+		{"round robin by default", []string{"http://new-a:1", "http://new-b:1"}, "", []string{"http://new-a:1", "http://new-b:1"}, false},
+		{"duplicates, slashes and blanks", []string{"http://new-a:1/", " http://new-a:1", "", "http://new-b:1//"}, BalanceLeastPending,
+			[]string{"http://new-a:1", "http://new-b:1"}, false},
+		{"no URLs", nil, "", nil, true},
+		{"only blanks", []string{" ", ""}, "", nil, true},
+		{"unknown strategy", []string{"http://new-a:1"}, "random", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := NewPool(tt.urls, tt.strategy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPool = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := urls(pool.Endpoints()); !slices.Equal(got, tt.want) {
+				t.Errorf("Endpoints = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Pools share endpoints by URL
+	a, _ := NewPool([]string{"http://shared:1"}, "")
+	b, _ := NewPool([]string{"http://shared:1/", "http://shared:2"}, "")
+	if a.Endpoints()[0] != b.Endpoints()[0] {
+		t.Error("pools with the same URL do not share its endpoint")
+	}
+}
+
+func TestCandidatesRoundRobin(t *testing.T) {
+	pool, err := NewPool([]string{"http://rr-a:1", "http://rr-b:1", "http://rr-c:1"}, BalanceRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"http://rr-a:1", "http://rr-b:1", "http://rr-c:1"},
+		{"http://rr-b:1", "http://rr-c:1", "http://rr-a:1"},
+		{"http://rr-c:1", "http://rr-a:1", "http://rr-b:1"},
+		{"http://rr-a:1", "http://rr-b:1", "http://rr-c:1"},
+	}
+	for i, order := range want {
+		if got := urls(pool.Candidates()); !slices.Equal(got, order) {
+			t.Errorf("request %d: Candidates = %v, want %v", i, got, order)
+		}
+	}
+}
+
+func TestCandidatesOrdering(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		pending  []int
+		healthy  []bool
+		want     []string
+	}{
+		{"least pending first", BalanceLeastPending, []int{3, 0, 1}, []bool{true, true, true},
+			[]string{"b", "c", "a"}},
+		{"least pending ties keep the rotation", BalanceLeastPending, []int{1, 1, 0}, []bool{true, true, true},
+			[]string{"c", "a", "b"}},
+		{"round robin ignores pending", BalanceRoundRobin, []int{3, 0, 1}, []bool{true, true, true},
+			[]string{"a", "b", "c"}},
+		{"unhealthy last", BalanceRoundRobin, []int{0, 0, 0}, []bool{false, true, true},
+			[]string{"b", "c", "a"}},
+		{"unhealthy last despite fewer pending", BalanceLeastPending, []int{0, 2, 1}, []bool{false, true, true},
+			[]string{"c", "b", "a"}},
+		{"all unhealthy keeps the order", BalanceLeastPending, []int{2, 0, 1}, []bool{false, false, false},
+			[]string{"b", "c", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Unique URLs, since endpoints and their state are shared by URL
+			names := []string{"a", "b", "c"}
+			poolURLs := make([]string, len(names))
+			for i, name := range names {
+				poolURLs[i] = "http://" + name + ".order/" + t.Name()
+			}
+			pool, err := NewPool(poolURLs, tt.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, e := range pool.Endpoints() {
+				for range tt.pending[i] {
+					t.Cleanup(e.Begin())
+				}
+				e.setHealthy(tt.healthy[i], "test")
+				t.Cleanup(func() { e.setHealthy(true, "") })
+			}
+
+			got := pool.Candidates()
+			for i, name := range tt.want {
+				if got[i] != pool.Endpoints()[slices.Index(names, name)] {
+					t.Fatalf("Candidates = %v, want %v", urls(got), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEndpointBegin(t *testing.T) {
+	pool, err := NewPool([]string{"http://begin:1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := pool.Endpoints()[0]
+
+	end := e.Begin()
+	e.Begin()()
+	if pending := e.Status().Pending; pending != 1 {
+		t.Fatalf("Pending = %d, want 1", pending)
+	}
+	end()
+	end()
+	if pending := e.Status().Pending; pending != 0 {
+		t.Errorf("Pending after ending twice = %d, want 0", pending)
+	}
+}
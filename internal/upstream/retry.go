@@ -0,0 +1,189 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RetryPolicy controls how requests to an upstream are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int `yaml:"max_attempts"`
+	// InitialBackoff is the wait before the first retry
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	// MaxBackoff caps the wait between attempts
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Multiplier grows the backoff after each attempt
+	Multiplier float64 `yaml:"multiplier"`
+	// Jitter randomizes each backoff by up to this fraction in either direction
+	Jitter float64 `yaml:"jitter"`
+	// RetryableStatuses are the upstream HTTP statuses that may be retried
+	RetryableStatuses []int `yaml:"retryable_statuses"`
+	// Deadline bounds the time spent on all attempts; callers may shorten it further
+	Deadline time.Duration `yaml:"deadline"`
+	// Idempotent marks routes whose requests can safely be repeated after
+	// ONIX may have received them; defaults to true for search and discover only
+	Idempotent *bool `yaml:"idempotent"`
+}
+
+// RetryPolicies is the retry policy file format
+type RetryPolicies struct {
+	Default RetryPolicy            `yaml:"default"`
+	Routes  map[string]RetryPolicy `yaml:"routes"`
+}
+
+var (
+	retryPolicies = defaultRetryPolicies()
+	retryMu       sync.RWMutex
+)
+
+// defaultRetryPolicies returns the built-in policy used when no policy file overrides it
+func defaultRetryPolicies() *RetryPolicies {
+	return &RetryPolicies{
+		Default: RetryPolicy{
+			MaxAttempts:       3,
+			InitialBackoff:    100 * time.Millisecond,
+			MaxBackoff:        2 * time.Second,
+			Multiplier:        2,
+			Jitter:            0.2,
+			RetryableStatuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+			Deadline:          25 * time.Second,
+		},
+		Routes: map[string]RetryPolicy{},
+	}
+}
+
+// InitRetryPolicies loads per-route retry policies from a YAML file
+// Values in the file override the built-in defaults; a missing file keeps the defaults.
+func InitRetryPolicies(path string) error {
+	policies := defaultRetryPolicies()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			upstreamLog.Info("Retry policy file not found, using built-in retry policy", "path", path)
+		case err != nil:
+			return fmt.Errorf("failed to read retry policies: %w", err)
+		default:
+			var filePolicies RetryPolicies
+			decoder := yaml.NewDecoder(bytes.NewReader(data))
+			decoder.KnownFields(true)
+			if err := decoder.Decode(&filePolicies); err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("failed to parse retry policies YAML: %w", err)
+			}
+			policies.Default = filePolicies.Default.mergeOnto(policies.Default)
+			for route, policy := range filePolicies.Routes {
+				policies.Routes[route] = policy
+			}
+			upstreamLog.Info("Loaded retry policies", "routes", len(filePolicies.Routes), "path", path)
+		}
+	}
+
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryPolicies = policies
+	return nil
+}
+
+// RetryPolicyFor returns the effective retry policy for a route
+func RetryPolicyFor(route string) RetryPolicy {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+
+	policy := retryPolicies.Default
+	if routePolicy, ok := retryPolicies.Routes[route]; ok {
+		policy = routePolicy.mergeOnto(policy)
+	}
+	if policy.Idempotent == nil {
+		idempotent := route == "search" || route == "discover"
+		policy.Idempotent = &idempotent
+	}
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return policy
+}
+
+// mergeOnto returns base with every field set in p overriding it
+func (p RetryPolicy) mergeOnto(base RetryPolicy) RetryPolicy {
+	if p.MaxAttempts != 0 {
+		base.MaxAttempts = p.MaxAttempts
+	}
+	if p.InitialBackoff != 0 {
+		base.InitialBackoff = p.InitialBackoff
+	}
+	if p.MaxBackoff != 0 {
+		base.MaxBackoff = p.MaxBackoff
+	}
+	if p.Multiplier != 0 {
+		base.Multiplier = p.Multiplier
+	}
+	if p.Jitter != 0 {
+		base.Jitter = p.Jitter
+	}
+	if p.RetryableStatuses != nil {
+		base.RetryableStatuses = p.RetryableStatuses
+	}
+	if p.Deadline != 0 {
+		base.Deadline = p.Deadline
+	}
+	if p.Idempotent != nil {
+		base.Idempotent = p.Idempotent
+	}
+	return base
+}
+
+// ShouldRetry reports whether an attempt with the given outcome may be retried
+// Non-idempotent routes are only retried when ONIX cannot have processed the
+// request: the connection was never established, or ONIX answered 503.
+func (p RetryPolicy) ShouldRetry(status int, err error) bool {
+	idempotent := p.Idempotent != nil && *p.Idempotent
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+			return false
+		}
+		return idempotent || isDialError(err)
+	}
+
+	if !slices.Contains(p.RetryableStatuses, status) {
+		return false
+	}
+	return idempotent || status == http.StatusServiceUnavailable
+}
+
+// Backoff returns the wait before the given retry (1 for the first retry), with jitter applied
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+// isDialError reports whether err happened while connecting, before the request was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,127 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	idempotent, nonIdempotent := true, false
+	statuses := []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+	tests := []struct {
+		name       string
+		idempotent *bool
+		status     int
+		err        error
+		want       bool
+	}{
+		{"idempotent retryable status", &idempotent, http.StatusBadGateway, nil, true},
+		{"idempotent unlisted status", &idempotent, http.StatusInternalServerError, nil, false},
+		{"idempotent success", &idempotent, http.StatusOK, nil, false},
+		{"idempotent read error", &idempotent, 0, readErr, true},
+		{"idempotent dial error", &idempotent, 0, dialErr, true},
+		{"non-idempotent 503", &nonIdempotent, http.StatusServiceUnavailable, nil, true},
+		{"non-idempotent 502", &nonIdempotent, http.StatusBadGateway, nil, false},
+		{"non-idempotent 504", &nonIdempotent, http.StatusGatewayTimeout, nil, false},
+		{"non-idempotent dial error", &nonIdempotent, 0, dialErr, true},
+		{"non-idempotent wrapped dial error", &nonIdempotent, 0, fmt.Errorf("post: %w", dialErr), true},
+		{"non-idempotent read error", &nonIdempotent, 0, readErr, false},
+		{"unset idempotency is not idempotent", nil, http.StatusBadGateway, nil, false},
+		{"cancelled", &idempotent, 0, context.Canceled, false},
+		{"circuit open", &idempotent, 0, ErrCircuitOpen, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := RetryPolicy{RetryableStatuses: statuses, Idempotent: tt.idempotent}
+			if got := policy.ShouldRetry(tt.status, tt.err); got != tt.want {
+				t.Errorf("ShouldRetry(%d, %v) = %v, want %v", tt.status, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.retry); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.retry, got, tt.want)
+		}
+	}
+
+	policy.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		got := policy.Backoff(1)
+		if got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Fatalf("Backoff(1) with 20%% jitter = %s, want within 80ms-120ms", got)
+		}
+	}
+}
+
+func TestRetryPolicyFor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.yaml")
+	data := `default:
+  max_attempts: 4
+routes:
+  search:
+    max_attempts: 2
+  confirm:
+    idempotent: true
+  init:
+    max_attempts: -1
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitRetryPolicies(path); err != nil {
+		t.Fatalf("InitRetryPolicies: %v", err)
+	}
+	t.Cleanup(func() { InitRetryPolicies("") })
+
+	tests := []struct {
+		route          string
+		maxAttempts    int
+		idempotent     bool
+		initialBackoff time.Duration
+	}{
+		{"search", 2, true, 100 * time.Millisecond},
+		{"discover", 4, true, 100 * time.Millisecond},
+		{"select", 4, false, 100 * time.Millisecond},
+		{"confirm", 4, true, 100 * time.Millisecond},
+		{"init", 1, false, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		policy := RetryPolicyFor(tt.route)
+		if policy.MaxAttempts != tt.maxAttempts {
+			t.Errorf("%s: MaxAttempts = %d, want %d", tt.route, policy.MaxAttempts, tt.maxAttempts)
+		}
+		if *policy.Idempotent != tt.idempotent {
+			t.Errorf("%s: Idempotent = %v, want %v", tt.route, *policy.Idempotent, tt.idempotent)
+		}
+		if policy.InitialBackoff != tt.initialBackoff {
+			t.Errorf("%s: InitialBackoff = %s, want %s", tt.route, policy.InitialBackoff, tt.initialBackoff)
+		}
+	}
+}